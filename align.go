@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Ширина источника при Options.Align по умолчанию
+const defaultSourceWidth = 40
+
+// levelColumn — ширина колонки уровня при Options.Align, общая для копий
+// обработчика. Пересчитывается после RegisterLevel: уровни могут быть
+// зарегистрированы после создания обработчика
+type levelColumn struct {
+	cache atomic.Pointer[levelColumnCache]
+}
+
+type levelColumnCache struct {
+	levels *map[slog.Level]levelInfo
+	width  int
+}
+
+// levelColumnWidth возвращает ширину колонки уровня для текущего набора уровней
+func (h *handlerTextColor) levelColumnWidth() int {
+	registered := levels.Load()
+	if c := h.levelColumn.cache.Load(); c != nil && c.levels == registered {
+		return c.width
+	}
+
+	width := h.maxLevelWidth(registered)
+	h.levelColumn.cache.Store(&levelColumnCache{levels: registered, width: width})
+	return width
+}
+
+// maxLevelWidth возвращает ширину самого широкого уровня в выводе
+// обработчика: стандартных и зарегистрированных RegisterLevel, с метками
+// и символами уровня
+func (h *handlerTextColor) maxLevelWidth(registered *map[slog.Level]levelInfo) int {
+	all := []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+	if registered != nil {
+		for l := range *registered {
+			all = append(all, l)
+		}
+	}
+
+	buf := newBuffer()
+	defer buf.Free()
+
+	width := 0
+	for _, l := range all {
+		*buf = (*buf)[:0]
+		h.appendLevel(buf, l, h.levelFormat)
+		width = max(width, DisplayWidth(string(*buf)))
+	}
+
+	return width
+}
+
+// alignColumn дополняет пробелами или обрезает вывод от start до width
+// колонок. ANSI-последовательности в ширине не учитываются. После обрезки
+// дописывается reset: закрывающий сброс цвета обрезается вместе с текстом
+func alignColumn(buf *buffer, start, width int, reset string) {
+	seg := string((*buf)[start:])
+
+	w := DisplayWidth(seg)
+	if w > width {
+		*buf = append((*buf)[:start], TruncateWidth(seg, width)...)
+		w = DisplayWidth(string((*buf)[start:]))
+		buf.WriteString(reset)
+	}
+
+	buf.WriteString(strings.Repeat(" ", width-w))
+}
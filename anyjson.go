@@ -0,0 +1,18 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// compactJSON возвращает v в компактном JSON без экранирования HTML,
+// false — если v не кодируется (каналы, функции, циклы)
+func compactJSON(v any) (string, bool) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", false
+	}
+	return string(bytes.TrimSuffix(b.Bytes(), []byte("\n"))), true
+}
@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// BatchBuilder собирает несколько записей, чтобы вывести их одним блоком.
+// Обработчики пакета пишут блок в writer под одной блокировкой, поэтому
+// записи других горутин не попадают внутрь блока.
+//
+//	logger.Batch(ctx).
+//		Info("request done", "status", 200).
+//		Log(queryCtx, slog.LevelWarn, "slow query").
+//		Emit()
+type BatchBuilder struct {
+	ctx     context.Context
+	logger  *slog.Logger
+	entries []batchEntry
+}
+
+type batchEntry struct {
+	ctx context.Context
+	rec slog.Record
+}
+
+// batchHandler реализуется обработчиками, умеющими выводить блок записей атомарно
+type batchHandler interface {
+	handleBatch(entries []batchEntry) error
+}
+
+// Batch начинает блок записей для логера по умолчанию
+func Batch(ctx context.Context) *BatchBuilder {
+	return &BatchBuilder{ctx: ctx, logger: slog.Default()}
+}
+
+func (b *BatchBuilder) Debug(msg string, args ...any) *BatchBuilder {
+	return b.add(b.ctx, slog.LevelDebug, msg, args)
+}
+
+func (b *BatchBuilder) Info(msg string, args ...any) *BatchBuilder {
+	return b.add(b.ctx, slog.LevelInfo, msg, args)
+}
+
+func (b *BatchBuilder) Warn(msg string, args ...any) *BatchBuilder {
+	return b.add(b.ctx, slog.LevelWarn, msg, args)
+}
+
+func (b *BatchBuilder) Error(msg string, args ...any) *BatchBuilder {
+	return b.add(b.ctx, slog.LevelError, msg, args)
+}
+
+// Log добавляет запись со своим контекстом, например с SQL из gorm
+func (b *BatchBuilder) Log(ctx context.Context, level slog.Level, msg string, args ...any) *BatchBuilder {
+	return b.add(ctx, level, msg, args)
+}
+
+func (b *BatchBuilder) add(ctx context.Context, level slog.Level, msg string, args []any) *BatchBuilder {
+	if !b.logger.Enabled(ctx, level) {
+		return b
+	}
+
+	var pcs [1]uintptr
+	if sourceCapture(b.logger.Handler()) {
+		runtime.Callers(3, pcs[:])
+	}
+
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+
+	b.entries = append(b.entries, batchEntry{ctx: ctx, rec: r})
+	return b
+}
+
+// Emit выводит собранные записи. Если обработчик не поддерживает блоки,
+// записи выводятся по одной.
+func (b *BatchBuilder) Emit() error {
+	entries := b.entries
+	b.entries = nil
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return handleEntries(b.logger.Handler(), entries)
+}
+
+// handleEntries выводит блок обработчиком h: одним блоком, если h
+// поддерживает блоки, иначе по одной записи
+func handleEntries(h slog.Handler, entries []batchEntry) error {
+	if bh, ok := h.(batchHandler); ok {
+		return bh.handleBatch(entries)
+	}
+
+	for _, e := range entries {
+		if err := h.Handle(e.ctx, e.rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *handlerTextColor) handleBatch(entries []batchEntry) error {
+	buf := newBuffer()
+	defer buf.Free()
+
+	delta := h.timeMode != TimeClock
+	if delta {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+	}
+
+	for _, e := range entries {
+		ok := h.pre.process(e.ctx, &e.rec, h.loggerName)
+		if ok && h.focus != nil && h.focus.hidden(e.ctx) {
+			ok = false
+		}
+		h.renderNotices(buf)
+		if ok {
+			start := len(*buf)
+			h.render(e.ctx, e.rec, buf)
+			if h.pre.sizes != nil {
+				h.measureDev(buf, start, e.rec.PC)
+			}
+		}
+	}
+
+	if len(*buf) == 0 {
+		return nil
+	}
+
+	if !delta {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+	}
+
+	if h.focus != nil && h.focus.hold(*buf) {
+		return nil
+	}
+
+	_, err := h.w.Write(*buf)
+	if err == nil && batchNeedsFlush(h.flushLevel, entries) {
+		err = flushWriter(h.w)
+	}
+	return err
+}
+
+func (h *HandlerMiddleware) handleBatch(entries []batchEntry) error {
+	prepared := make([]batchEntry, 0, len(entries))
+	for _, e := range entries {
+		rec, ok := h.prepare(e.ctx, e.rec)
+		for _, n := range h.pre.notices() {
+			prepared = append(prepared, batchEntry{ctx: context.Background(), rec: n})
+		}
+		if ok {
+			prepared = append(prepared, batchEntry{ctx: e.ctx, rec: rec})
+		}
+	}
+
+	if bh, ok := h.next.(batchHandler); ok {
+		return bh.handleBatch(prepared)
+	}
+
+	h.batchMu.Lock()
+	defer h.batchMu.Unlock()
+
+	for _, e := range prepared {
+		if err := h.next.Handle(e.ctx, e.rec); err != nil {
+			return err
+		}
+	}
+
+	if batchNeedsFlush(h.flushLevel, prepared) {
+		return h.flush()
+	}
+
+	return nil
+}
+
+// batchNeedsFlush сообщает, что в блоке есть запись уровня FlushLevel и выше
+func batchNeedsFlush(level slog.Leveler, entries []batchEntry) bool {
+	if level == nil {
+		return false
+	}
+
+	for _, e := range entries {
+		if e.rec.Level >= level.Level() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,369 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Уровни zap и logrus без аналогов в slog
+const (
+	levelTrace = slog.Level(-8)
+	levelPanic = slog.Level(12)
+)
+
+// FromLogrusLevel возвращает уровень slog для уровня logrus
+// (uint32(logrus.InfoLevel)): Trace — DEBUG-4, Panic и Fatal — ERROR+4
+func FromLogrusLevel(level uint32) slog.Level {
+	switch level {
+	case 0, 1: // PanicLevel, FatalLevel
+		return levelPanic
+	case 2:
+		return slog.LevelError
+	case 3:
+		return slog.LevelWarn
+	case 4:
+		return slog.LevelInfo
+	case 5:
+		return slog.LevelDebug
+	}
+	return levelTrace
+}
+
+// bridgeLevel разбирает название уровня zap или logrus ("warning", "DPANIC"),
+// затем — в формате ParseLevel
+func bridgeLevel(s string) (slog.Level, bool) {
+	switch strings.ToLower(s) {
+	case "trace":
+		return levelTrace, true
+	case "debug":
+		return slog.LevelDebug, true
+	case "info", "":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	case "dpanic":
+		return slog.LevelError + 2, true
+	case "panic", "fatal":
+		return levelPanic, true
+	}
+
+	level, err := ParseLevel(s)
+	return level, err == nil
+}
+
+// ZapConfig — поля zap.Config, которые переносятся в Options. Теги
+// совпадают с zap: существующий JSON или YAML конфиг zap разбирается в
+// ZapConfig без изменений
+type ZapConfig struct {
+	Level             string         `json:"level" yaml:"level"`
+	Development       bool           `json:"development" yaml:"development"`
+	DisableCaller     bool           `json:"disableCaller" yaml:"disableCaller"`
+	DisableStacktrace bool           `json:"disableStacktrace" yaml:"disableStacktrace"`
+	Encoding          string         `json:"encoding" yaml:"encoding"`
+	OutputPaths       []string       `json:"outputPaths" yaml:"outputPaths"`
+	InitialFields     map[string]any `json:"initialFields" yaml:"initialFields"`
+}
+
+// FromZapConfig создает логер по конфигурации zap: Development или
+// Encoding "console" — dev логер, иначе JSON; Level — минимальный уровень
+// (по умолчанию info), DisableCaller отключает Source, первый из
+// OutputPaths ("stdout", "stderr" или файл, по умолчанию stderr, как в
+// zap) — вывод, InitialFields — атрибуты логера. Стек ошибок выводится,
+// если не задан DisableStacktrace. Возвращенная функция закрывает
+// открытый файл вывода, как cleanup у zap.Open:
+//
+//	l, closeLog, err := logger.FromZapConfig(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	defer closeLog()
+func FromZapConfig(cfg ZapConfig) (*slog.Logger, func() error, error) {
+	level, ok := bridgeLevel(cfg.Level)
+	if !ok {
+		return nil, nil, fmt.Errorf("logger: unknown zap level %q", cfg.Level)
+	}
+
+	opt := Options{
+		W:          os.Stderr,
+		Level:      level,
+		Source:     !cfg.DisableCaller,
+		ErrorStack: !cfg.DisableStacktrace,
+	}
+
+	closeFn := func() error { return nil }
+	if len(cfg.OutputPaths) > 0 {
+		switch p := cfg.OutputPaths[0]; p {
+		case "stdout":
+			opt.W = os.Stdout
+		case "stderr":
+		default:
+			f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+			if err != nil {
+				return nil, nil, err
+			}
+			opt.W = f
+			closeFn = f.Close
+		}
+	}
+
+	var (
+		l   *slog.Logger
+		err error
+	)
+	switch cfg.Encoding {
+	case "console":
+		l, err = NewDevLogger(opt)
+	case "json":
+		l, err = NewLogger(opt)
+	case "":
+		if cfg.Development {
+			l, err = NewDevLogger(opt)
+		} else {
+			l, err = NewLogger(opt)
+		}
+	default:
+		err = fmt.Errorf("logger: unknown zap encoding %q", cfg.Encoding)
+	}
+	if err != nil {
+		closeFn()
+		return nil, nil, err
+	}
+
+	if len(cfg.InitialFields) > 0 {
+		args := make([]any, 0, 2*len(cfg.InitialFields))
+		for _, k := range slices.Sorted(maps.Keys(cfg.InitialFields)) {
+			args = append(args, k, cfg.InitialFields[k])
+		}
+		l = l.With(args...)
+	}
+
+	return l, closeFn, nil
+}
+
+// BridgeFormat — ключи JSON строк стороннего логера
+type BridgeFormat struct {
+	TimeKey    string
+	LevelKey   string
+	MessageKey string
+	// Место вызова "dir/file.go:42", выводится источником записи
+	CallerKey string
+}
+
+var (
+	// Ключи JSON кодировщика zap.NewProductionConfig
+	ZapFormat = BridgeFormat{TimeKey: "ts", LevelKey: "level", MessageKey: "msg", CallerKey: "caller"}
+	// Ключи logrus.JSONFormatter
+	LogrusFormat = BridgeFormat{TimeKey: "time", LevelKey: "level", MessageKey: "msg", CallerKey: "file"}
+)
+
+// NewBridgeWriter возвращает io.Writer, который разбирает JSON строки
+// стороннего логера и передает их записями обработчику h. Так вызовы zap и
+// logrus выводятся обработчиками этого пакета, пока код переходит на slog:
+//
+//	h := logger.NewDevHandler(logger.Options{})
+//	logrus.SetFormatter(&logrus.JSONFormatter{})
+//	logrus.SetOutput(logger.NewBridgeWriter(h, logger.LogrusFormat))
+//
+//	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+//		zapcore.AddSync(logger.NewBridgeWriter(h, logger.ZapFormat)), zap.DebugLevel)
+//
+// Остальные поля строки становятся атрибутами в порядке строки, строки
+// не в JSON выводятся сообщением уровня Info.
+func NewBridgeWriter(h slog.Handler, f BridgeFormat) io.Writer {
+	return &bridgeWriter{h: h, f: f}
+}
+
+// Максимальная длина строки NewBridgeWriter: более длинная строка без
+// перевода строки выводится частями, а не копится в памяти
+const maxBridgeLine = 64 << 10
+
+type bridgeWriter struct {
+	h slog.Handler
+	f BridgeFormat
+
+	mu   sync.Mutex
+	tail []byte
+}
+
+func (w *bridgeWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data := p
+	if len(w.tail) > 0 {
+		data = append(w.tail, p...)
+		w.tail = nil
+	}
+
+	var errs []error
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimSpace(data[:i]); len(line) > 0 {
+			errs = append(errs, w.handle(line))
+		}
+		data = data[i+1:]
+	}
+
+	// неполная строка дописывается следующим Write
+	if len(data) > maxBridgeLine {
+		errs = append(errs, w.handle(data))
+		data = nil
+	}
+	w.tail = append(w.tail, data...)
+
+	return len(p), errors.Join(errs...)
+}
+
+func (w *bridgeWriter) handle(line []byte) error {
+	ctx := context.Background()
+
+	r, src, ok := w.parse(line)
+	if !ok {
+		r = slog.NewRecord(time.Now(), slog.LevelInfo, string(line), 0)
+	}
+
+	if !w.h.Enabled(ctx, r.Level) {
+		return nil
+	}
+
+	if src.File != "" {
+		ctx = context.WithValue(ctx, Source, src)
+	}
+
+	return w.h.Handle(ctx, r)
+}
+
+// parse разбирает строку, сохраняя порядок полей
+func (w *bridgeWriter) parse(line []byte) (slog.Record, slog.Source, bool) {
+	var (
+		r     slog.Record
+		src   slog.Source
+		attrs []slog.Attr
+	)
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return r, src, false
+	}
+
+	var (
+		ts    time.Time
+		level = slog.LevelInfo
+		msg   string
+	)
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return r, src, false
+		}
+		key, _ := t.(string)
+
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return r, src, false
+		}
+
+		switch key {
+		case w.f.TimeKey:
+			ts = bridgeTime(v)
+		case w.f.LevelKey:
+			if s, ok := v.(string); ok {
+				if l, ok := bridgeLevel(s); ok {
+					level = l
+				}
+			}
+		case w.f.MessageKey:
+			msg = fmt.Sprint(v)
+		case w.f.CallerKey:
+			if s, ok := v.(string); ok {
+				src = bridgeCaller(s)
+			}
+		default:
+			attrs = append(attrs, bridgeAttr(key, v))
+		}
+	}
+
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	r = slog.NewRecord(ts, level, msg, 0)
+	r.AddAttrs(attrs...)
+
+	return r, src, true
+}
+
+// bridgeTime разбирает время zap (секунды Unix с дробной частью) или
+// строку RFC 3339 (zap ISO8601, logrus)
+func bridgeTime(v any) time.Time {
+	switch t := v.(type) {
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return time.Time{}
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9))
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700"} {
+			if ts, err := time.Parse(layout, t); err == nil {
+				return ts
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// bridgeCaller разбирает место вызова "dir/file.go:42"
+func bridgeCaller(s string) slog.Source {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return slog.Source{File: s}
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return slog.Source{File: s}
+	}
+	return slog.Source{File: s[:i], Line: n}
+}
+
+// bridgeAttr преобразует поле JSON: числа — в int64 или float64,
+// объекты — в группы
+func bridgeAttr(key string, v any) slog.Attr {
+	switch t := v.(type) {
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return slog.Int64(key, n)
+		}
+		f, _ := t.Float64()
+		return slog.Float64(key, f)
+	case map[string]any:
+		members := make([]slog.Attr, 0, len(t))
+		for _, k := range slices.Sorted(maps.Keys(t)) {
+			members = append(members, bridgeAttr(k, t[k]))
+		}
+		return slog.Attr{Key: key, Value: slog.GroupValue(members...)}
+	}
+	return slog.Any(key, v)
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// Число отслеживаемых запросов, после которого счетчики сбрасываются:
+// контексты без отмены не должны копить память
+const budgetMaxRequests = 10000
+
+// requestBudget ограничивает число и объем записей одного request ID,
+// см. Options.RequestBudget
+type requestBudget struct {
+	key     string
+	records int
+	bytes   int
+	notify  func(slog.Record)
+
+	mu    sync.Mutex
+	spent map[string]*budgetSpent
+}
+
+type budgetSpent struct {
+	records    int
+	bytes      int
+	suppressed int
+}
+
+func newRequestBudget(opt Options, notify func(slog.Record)) *requestBudget {
+	return &requestBudget{
+		key:     cmp.Or(opt.RequestIDKey, "request_id"),
+		records: opt.RequestBudget,
+		bytes:   opt.RequestBudgetBytes,
+		notify:  notify,
+		spent:   make(map[string]*budgetSpent),
+	}
+}
+
+// allow учитывает запись в бюджете ее запроса. Записи сверх бюджета
+// считаются; об их числе пишется одна запись после отмены контекста
+// запроса (завершения HTTP запроса)
+func (b *requestBudget) allow(ctx context.Context, r *slog.Record) bool {
+	id := core.RequestID(ctx.Value(b.key))
+	if id == "" {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.spent[id]
+	if !ok {
+		if len(b.spent) >= budgetMaxRequests {
+			for id, s := range b.spent {
+				b.report(id, s)
+			}
+			clear(b.spent)
+		}
+
+		s = &budgetSpent{}
+		b.spent[id] = s
+		context.AfterFunc(ctx, func() { b.done(id, s) })
+	}
+
+	if s.suppressed > 0 || b.records > 0 && s.records >= b.records || b.bytes > 0 && s.bytes >= b.bytes {
+		s.suppressed++
+		return false
+	}
+
+	s.records++
+	s.bytes += recordSize(r)
+
+	return true
+}
+
+// done забывает запрос и сообщает о подавленных записях
+func (b *requestBudget) done(id string, s *budgetSpent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.spent[id] != s {
+		return
+	}
+	delete(b.spent, id)
+
+	b.report(id, s)
+}
+
+func (b *requestBudget) report(id string, s *budgetSpent) {
+	if s.suppressed == 0 {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelWarn, "log budget exceeded", 0)
+	r.AddAttrs(
+		slog.String(b.key, id),
+		slog.Int("suppressed", s.suppressed),
+		slog.Int("records", s.records),
+		slog.Int("bytes", s.bytes),
+	)
+	b.notify(r)
+}
+
+// recordSize — примерный объем записи: сообщение, ключи и значения атрибутов
+func recordSize(r *slog.Record) int {
+	n := len(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		n += len(a.Key) + len(a.Value.String()) + 2
+		return true
+	})
+	return n
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"cmp"
+	"log/slog"
+	"math"
+	"slices"
+)
+
+// Точность чисел с плавающей точкой в каноническом режиме по умолчанию
+const defaultCanonicalPrecision = 6
+
+// canonicalPrecision возвращает точность канонического режима или -1,
+// если режим выключен
+func canonicalPrecision(opt Options) int {
+	if !opt.Canonical {
+		return -1
+	}
+	if opt.CanonicalPrecision == 0 {
+		return defaultCanonicalPrecision
+	}
+	return opt.CanonicalPrecision
+}
+
+// canonicalRecord возвращает запись с атрибутами в каноническом виде для
+// golden-файлов: ключи отсортированы (и внутри групп), числа с плавающей
+// точкой округлены до precision знаков, длительности выведены строкой
+// time.Duration.String вместо наносекунд
+func canonicalRecord(r slog.Record, precision int) slog.Record {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	r2 := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r2.AddAttrs(canonicalAttrs(attrs, precision)...)
+	return r2
+}
+
+func canonicalAttrs(attrs []slog.Attr, precision int) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = canonicalValue(a.Value.Resolve(), precision)
+		out = append(out, a)
+	}
+
+	slices.SortStableFunc(out, func(a, b slog.Attr) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	return out
+}
+
+func canonicalValue(v slog.Value, precision int) slog.Value {
+	switch v.Kind() {
+	case slog.KindFloat64:
+		p := math.Pow10(precision)
+		return slog.Float64Value(math.Round(v.Float64()*p) / p)
+	case slog.KindDuration:
+		return slog.StringValue(v.Duration().String())
+	case slog.KindGroup:
+		return slog.GroupValue(canonicalAttrs(v.Group(), precision)...)
+	}
+
+	return v
+}
@@ -0,0 +1,179 @@
+package logger
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Ключ, под которым выводятся атрибуты с новыми ключами после превышения
+// порога различных ключей
+const CardinalityOverflowKey = "high_cardinality_key"
+
+// cardinality считает различные строковые значения каждого ключа атрибутов
+// и различные ключи. После превышения порога значения ключа хэшируются или
+// обрезаются, а о ключе один раз пишется предупреждение.
+type cardinality struct {
+	mu sync.Mutex
+	// порог значений одного ключа и различных сообщений, 0 — без ограничения
+	limit int
+	// порог различных ключей, 0 — без ограничения
+	keyLimit int
+	truncate int
+	keys     map[string]*keyCardinality
+	messages keyCardinality
+	// предупреждение о переполнении ключей уже выведено
+	keysOver bool
+}
+
+type keyCardinality struct {
+	values map[string]struct{}
+	over   bool
+}
+
+func newCardinality(limit, keyLimit, truncate int) *cardinality {
+	return &cardinality{
+		limit:    limit,
+		keyLimit: keyLimit,
+		truncate: truncate,
+		keys:     make(map[string]*keyCardinality),
+		messages: keyCardinality{values: make(map[string]struct{})},
+	}
+}
+
+// guard заменяет значения ключей с превышенным порогом. Для ключей,
+// впервые превысивших порог, возвращает записи-предупреждения.
+func (c *cardinality) guard(r *slog.Record) []slog.Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		warnings []slog.Record
+		changed  bool
+		attrs    = make([]slog.Attr, 0, r.NumAttrs())
+	)
+
+	// сообщение не изменяется, о росте числа различных сообщений
+	// (UUID в тексте) только предупреждаем
+	if c.message(r.Message) {
+		warnings = append(warnings, c.warning("high cardinality message", slog.MessageKey, c.limit))
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		a2, ch, w := c.attr("", a)
+		changed = changed || ch
+		attrs = append(attrs, a2)
+		warnings = append(warnings, w...)
+		return true
+	})
+
+	if changed {
+		r2 := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		r2.AddAttrs(attrs...)
+		*r = r2
+	}
+
+	return warnings
+}
+
+// message учитывает сообщение и сообщает о первом превышении порога
+func (c *cardinality) message(msg string) bool {
+	k := &c.messages
+	if c.limit == 0 || k.over {
+		return false
+	}
+	if _, ok := k.values[msg]; ok {
+		return false
+	}
+	if len(k.values) < c.limit {
+		k.values[msg] = struct{}{}
+		return false
+	}
+
+	k.over = true
+	k.values = nil
+	return true
+}
+
+// attr учитывает атрибут и возвращает его замену. changed — атрибут
+// заменен: slog.Value.Equal паникует на срезах и map в KindAny
+func (c *cardinality) attr(prefix string, a slog.Attr) (_ slog.Attr, changed bool, _ []slog.Record) {
+	key := prefix + a.Key
+
+	if a.Value.Kind() == slog.KindGroup {
+		var warnings []slog.Record
+		group := a.Value.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			var (
+				ch bool
+				w  []slog.Record
+			)
+			attrs[i], ch, w = c.attr(key+".", ga)
+			changed = changed || ch
+			warnings = append(warnings, w...)
+		}
+		if !changed {
+			return a, false, warnings
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}, true, warnings
+	}
+
+	k, ok := c.keys[key]
+	if !ok {
+		if c.keyLimit > 0 && len(c.keys) >= c.keyLimit {
+			// ключ сам по себе уникален (email, UUID): переносим его в значение
+			a = slog.String(CardinalityOverflowKey, c.shorten(key+"="+a.Value.String()))
+			if c.keysOver {
+				return a, true, nil
+			}
+			c.keysOver = true
+			return a, true, []slog.Record{c.warning("high cardinality attribute keys", key, c.keyLimit)}
+		}
+
+		k = &keyCardinality{values: make(map[string]struct{})}
+		c.keys[key] = k
+	}
+
+	if c.limit == 0 || a.Value.Kind() != slog.KindString {
+		return a, false, nil
+	}
+
+	if k.over {
+		return slog.String(a.Key, c.shorten(a.Value.String())), true, nil
+	}
+
+	v := a.Value.String()
+	if _, ok := k.values[v]; ok {
+		return a, false, nil
+	}
+	if len(k.values) < c.limit {
+		k.values[v] = struct{}{}
+		return a, false, nil
+	}
+
+	// порог превышен: набор значений больше не нужен
+	k.over = true
+	k.values = nil
+
+	return slog.String(a.Key, c.shorten(v)), true, []slog.Record{c.warning("high cardinality attribute", key, c.limit)}
+}
+
+// shorten обрезает значение до truncate символов или заменяет его хэшем
+func (c *cardinality) shorten(v string) string {
+	if c.truncate > 0 {
+		return truncateSQL(v, c.truncate)
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(v))
+	return fmt.Sprintf("h:%016x", h.Sum64())
+}
+
+func (c *cardinality) warning(msg, key string, limit int) slog.Record {
+	r := slog.NewRecord(time.Now(), slog.LevelWarn, msg, 0)
+	r.AddAttrs(slog.String("key", key), slog.Int("limit", limit))
+	return r
+}
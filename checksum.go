@@ -0,0 +1,228 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Префикс строк с контрольной суммой
+const checksumPrefix = "#chain sha256:"
+
+var (
+	ErrChecksumClosed   = errors.New("logger: checksum writer is closed")
+	ErrChecksumMismatch = errors.New("logger: checksum mismatch")
+	// Строки после последней контрольной суммы: запись оборвалась
+	// или строки дописаны не через ChecksumWriter
+	ErrChecksumTail = errors.New("logger: lines without checksum")
+)
+
+// ChecksumWriter дописывает к выводу логера цепочку контрольных сумм для
+// локальных файлов, где нужна простая защита от правки задним числом.
+// Хэш каждой строки вычисляется от хэша предыдущей и самой строки, после
+// каждых Block строк (и при Flush, Close) пишется строка
+//
+//	#chain sha256:<hex> lines=<n>
+//
+// Изменение, удаление или вставка строки ломает цепочку до конца файла,
+// см. VerifyChecksums. Это не защита от злоумышленника, который может
+// пересчитать всю цепочку: для нее нужна подпись или внешнее хранилище.
+//
+// После ошибки записи в w все последующие вызовы возвращают эту ошибку.
+type ChecksumWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	block   int
+	hash    [sha256.Size]byte
+	partial []byte
+	lines   int
+	err     error
+}
+
+// NewChecksumWriter создает writer с контрольной суммой после каждых
+// block строк (0 или 1 — после каждой строки). prev — последний хэш
+// цепочки из VerifyChecksums при дописывании в существующий файл,
+// пусто для нового файла.
+func NewChecksumWriter(w io.Writer, block int, prev string) (*ChecksumWriter, error) {
+	cw := &ChecksumWriter{w: w, block: max(block, 1)}
+
+	if prev != "" {
+		b, err := hex.DecodeString(prev)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("logger: invalid previous checksum %q", prev)
+		}
+		copy(cw.hash[:], b)
+	}
+
+	return cw, nil
+}
+
+func (cw *ChecksumWriter) Write(p []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			cw.partial = append(cw.partial, p...)
+			break
+		}
+
+		line := p[:i+1]
+		p = p[i+1:]
+
+		if len(cw.partial) > 0 {
+			line = append(cw.partial, line...)
+			cw.partial = cw.partial[:0]
+		}
+
+		if err := cw.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+// writeLine пишет строку с переводом строки и продолжает цепочку
+func (cw *ChecksumWriter) writeLine(line []byte) error {
+	if _, err := cw.w.Write(line); err != nil {
+		cw.err = err
+		return err
+	}
+
+	cw.hash = chainHash(cw.hash, line)
+	cw.lines++
+
+	if cw.lines >= cw.block {
+		return cw.writeTrailer()
+	}
+
+	return nil
+}
+
+func (cw *ChecksumWriter) writeTrailer() error {
+	if cw.lines == 0 {
+		return nil
+	}
+
+	trailer := checksumPrefix + hex.EncodeToString(cw.hash[:]) + " lines=" + strconv.Itoa(cw.lines) + "\n"
+	if _, err := io.WriteString(cw.w, trailer); err != nil {
+		cw.err = err
+		return err
+	}
+
+	cw.lines = 0
+	return nil
+}
+
+// Flush пишет контрольную сумму незавершенного блока и вызывает Sync/Flush у w.
+// Строка без перевода строки остается в буфере
+func (cw *ChecksumWriter) Flush() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.err != nil {
+		return cw.err
+	}
+
+	if err := cw.writeTrailer(); err != nil {
+		return err
+	}
+
+	return flushWriter(cw.w)
+}
+
+// Close дописывает незавершенную строку и контрольную сумму. w не закрывается.
+func (cw *ChecksumWriter) Close() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.err != nil {
+		return cw.err
+	}
+
+	var err error
+	if len(cw.partial) > 0 {
+		err = cw.writeLine(append(cw.partial, '\n'))
+		cw.partial = nil
+	}
+	if err == nil {
+		err = cw.writeTrailer()
+	}
+	if err == nil {
+		err = flushWriter(cw.w)
+	}
+
+	cw.err = ErrChecksumClosed
+	return err
+}
+
+// VerifyChecksums проверяет цепочку контрольных сумм, записанную
+// ChecksumWriter, и возвращает последний хэш для продолжения файла.
+// Ошибка содержит номер первой строки, на которой цепочка нарушена.
+func VerifyChecksums(r io.Reader) (string, error) {
+	var (
+		hash     [sha256.Size]byte
+		verified [sha256.Size]byte
+		pending  int
+		lineNo   int
+	)
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			lineNo++
+
+			if rest, ok := strings.CutPrefix(string(line), checksumPrefix); ok {
+				sum, lines, _ := strings.Cut(strings.TrimSuffix(rest, "\n"), " lines=")
+				if sum != hex.EncodeToString(hash[:]) || lines != strconv.Itoa(pending) {
+					return "", fmt.Errorf("%w at line %d", ErrChecksumMismatch, lineNo)
+				}
+
+				verified, pending = hash, 0
+			} else {
+				hash = chainHash(hash, line)
+				pending++
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	last := hex.EncodeToString(verified[:])
+	if pending > 0 {
+		return last, fmt.Errorf("%w: %d lines after line %d", ErrChecksumTail, pending, lineNo-pending)
+	}
+
+	return last, nil
+}
+
+// chainHash вычисляет хэш строки line, следующей за строкой с хэшем prev
+func chainHash(prev [sha256.Size]byte, line []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(prev[:])
+	h.Write(line)
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
@@ -1,96 +1,509 @@
 package logger
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"encoding"
 	"fmt"
 	"io"
 	"log/slog"
-	"path"
-	"path/filepath"
 	"reflect"
-	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
 )
 
 const (
-	// ANSI-коды для цветов
-	Reset        = "\u001b[0m"
-	Red          = "\u001b[31m"
-	Faint        = "\u001b[90m"
-	Green        = "\u001b[32m"
-	Yellow       = "\u001b[33m"
-	YellowBack   = "\u001b[43m"
-	Blue         = "\u001b[34m"
-	Magenta      = "\u001b[35m"
-	Cyan         = "\u001b[36m"
-	BrightGreen  = "\u001b[92m"
+	// Сброс цветов и начертания
+	Reset = "\u001b[0m"
+
+	// ANSI-коды для цветов.
+	//
+	// Deprecated: используйте Style, например Style{Fg: ColorRed}.String().
+	Red = "\u001b[31m"
+	// Deprecated: используйте Style{Fg: ColorBrightBlack}.
+	Faint = "\u001b[90m"
+	// Deprecated: используйте Style{Fg: ColorGreen}.
+	Green = "\u001b[32m"
+	// Deprecated: используйте Style{Fg: ColorYellow}.
+	Yellow = "\u001b[33m"
+	// Deprecated: используйте Style{Bg: ColorYellow}.
+	YellowBack = "\u001b[43m"
+	// Deprecated: используйте Style{Fg: ColorBlue}.
+	Blue = "\u001b[34m"
+	// Deprecated: используйте Style{Fg: ColorMagenta}.
+	Magenta = "\u001b[35m"
+	// Deprecated: используйте Style{Fg: ColorCyan}.
+	Cyan = "\u001b[36m"
+	// Deprecated: используйте Style{Fg: ColorBrightGreen}.
+	BrightGreen = "\u001b[92m"
+	// Deprecated: используйте Style{Fg: ColorBrightYellow}.
 	BrightYellow = "\u001b[93m"
 
 	ansiEsc = '\u001b'
+
+	// Отступ атрибутов в режиме Multiline
+	multilineIndent = "    "
 )
 
 type Options struct {
-	AddCxtAttr    []string
+	// Минимальный уровень записей, по умолчанию slog.LevelDebug. С
+	// *slog.LevelVar уровень dev обработчика и JSON логера меняется во время
+	// работы без пересоздания логеров, например из админского эндпоинта.
+	// Тот же LevelVar можно передать в gormslog.GormOptions.LevelVar
+	Level      slog.Leveler
+	AddCxtAttr []string
+	// Типизированные атрибуты контекста, см. CtxKey
+	CtxExtractors []CtxExtractor
+	// Группа JSON, в которую HandlerMiddleware добавляет значения контекста,
+	// источник, SQL и статистику рантайма. Пусто — корень записи: значения
+	// не попадают в группы WithGroup логера
+	InjectedGroup string
 	W             io.Writer
 	Source        bool
+	// Минимальный уровень, с которого источник определяется по стеку, например
+	// slog.LevelWarn. Источник из контекста (gorm) выводится на любом уровне.
+	// nil — на всех уровнях
+	SourceLevel slog.Leveler
+	// Определение источника по PC вместо runtime.CallersFrames
+	SourceResolver SourceResolver
+	// Вид пути к файлу источника, по умолчанию последний каталог и файл
+	SourcePath    SourcePathMode
 	SlowThreshold time.Duration
+	// Пороги медленного запроса для отдельных классов запросов, см. SlowRule
+	SlowRules []SlowRule
+	// Общие с gorm логером пороги медленных запросов, важнее SlowThreshold
+	// и SlowRules
+	Slow *SlowConfig
+
+	// Ключи числовых атрибутов, для которых выводится разница с предыдущей записью
+	DeltaKeys []string
+
+	// Списки IN длиннее порога сворачиваются при выводе SQL в терминал
+	InListThreshold int
+	// Максимальная длина SQL, длиннее обрезается: в JSON — в байтах,
+	// в терминале — в колонках с учетом широких символов. 0 — без ограничения.
+	// К обрезанному SQL добавляется sql_hash полного запроса
+	MaxSQLLength int
+
+	// Правила изменения уровня записей перед выводом
+	LevelRules []LevelRule
+	// Минимальные уровни для отдельных логеров и пакетов, см. LevelRoute
+	LevelRoutes []LevelRoute
+
+	// Помечать первое появление каждого вида записи, повторы выводить бледнее
+	FirstOccurrence bool
+	// Период, после которого отпечатки забываются. 0 — на все время работы процесса
+	FirstOccurrenceWindow time.Duration
+
+	// Источник времени записей вместо time.Now: время симуляции в тестах,
+	// замороженное время в снимках вывода
+	Clock func() time.Time
+	// Время записей в выводе не уменьшается, даже если системные часы
+	// переведены назад (NTP): такие записи получают время предыдущей
+	MonotonicTime bool
+
+	// Канонический вывод для golden-файлов и снимков: атрибуты записи
+	// отсортированы по ключу, числа с плавающей точкой округлены до
+	// CanonicalPrecision знаков, длительности выводятся строкой ("1.5s").
+	// Атрибуты WithAttrs выводятся в порядке добавления. Время записи
+	// фиксируется через Clock
+	Canonical bool
+	// Знаков после точки в каноническом режиме, по умолчанию 6
+	CanonicalPrecision int
+
+	// Формат времени (макет Go), по умолчанию time.TimeOnly в терминале
+	// и RFC 3339 с наносекундами в JSON
+	TimeFormat string
+	// Выводить время с предыдущей записи ("+12ms") вместо времени по часам
+	// или вместе с ним, см. TimeMode
+	TimeMode TimeMode
+	// Часовой пояс времени записей, например time.UTC или time.Local для
+	// логов контейнера с часами в UTC. По умолчанию время выводится как есть
+	TimeLocation *time.Location
+
+	// Обработка некорректного UTF-8, по умолчанию замена на U+FFFD
+	InvalidUTF8 UTF8Mode
+
+	// Целевая скорость вывода в записях в секунду: при превышении часть записей
+	// ниже Error отбрасывается, доля подстраивается каждую секунду. 0 — без сэмплирования
+	SampleRate int
+	// Период записи "log sampling" с текущей долей, по умолчанию 10 секунд
+	SampleReportInterval time.Duration
+	// Вызывается при изменении загрузки сэмплера, см. Pressure
+	OnPressure func(pressure float64)
+
+	// Порог различных строковых значений одного ключа атрибутов и различных
+	// сообщений. После превышения значения ключа хэшируются, о ключе пишется
+	// предупреждение. 0 — без ограничения
+	CardinalityLimit int
+	// Обрезать значения до заданной длины вместо хэширования
+	CardinalityTruncate int
+
+	// Порог различных ключей атрибутов. Атрибуты с новыми ключами сверх
+	// порога выводятся значением CardinalityOverflowKey. 0 — без ограничения
+	CardinalityKeyLimit int
+
+	// Максимум записей одного запроса (по request ID из контекста). Записи
+	// сверх бюджета отбрасываются, после отмены контекста запроса выводится
+	// одна запись "log budget exceeded" с их числом. Защищает общий вывод
+	// от циклов повторов внутри запроса. 0 — без ограничения
+	RequestBudget int
+	// Максимальный объем записей одного запроса в байтах: сообщения, ключи
+	// и значения атрибутов. 0 — без ограничения
+	RequestBudgetBytes int
+	// Ключ контекста с request ID, по умолчанию "request_id"
+	RequestIDKey string
+
+	// Строгий режим для разработки: о значениях атрибутов, которые нельзя
+	// осмысленно вывести (каналы, функции, структуры без экспортируемых полей),
+	// пишется предупреждение с местом вызова
+	Strict bool
+
+	// Считать объем записей по местам вызова, см. RecordSizeStats
+	SizeStats bool
+	// Записям больше порога в байтах добавляется атрибут record_bytes
+	// (при SizeStats). 0 — не добавлять
+	LargeRecord int
+
+	// Добавлять к записям Error и выше число горутин и объем кучи
+	RuntimeStats bool
+
+	// После записей этого уровня и выше у W вызывается Flush или Sync,
+	// например для CompressWriter или bufio.Writer. nil — не сбрасывать
+	FlushLevel slog.Leveler
+
+	// Собирать статистику для итоговой сводки, которую выводит Shutdown
+	Summary bool
+	// Периодический отчет о запросах и ошибках в webhook, см. DigestConfig
+	Digest *DigestConfig
+
+	// Выводить в терминал под цепочкой причин ошибки стек из ее метода
+	// StackTrace() (github.com/pkg/errors и совместимые пакеты)
+	ErrorStack bool
+
+	// Не определять источник записей ради пропускной способности: Source,
+	// SourceLevel и источник из контекста не действуют. Если обработчик
+	// установлен в slog.Default, gorm логер, Logf и Span не вызывают
+	// runtime.Callers (BatchBuilder — с логером этого обработчика), см.
+	// gormslog.GormOptions.DisableSource
+	DisableSource bool
+
+	// Строковые значения длиннее порога (или многострочные) выводятся в терминал
+	// первой строкой с пометкой "… (+12 lines, 4.2KB)", полностью — с контекстом
+	// Expand(ctx). 0 — не сворачивать
+	FoldValues int
+	// Строковые значения атрибутов длиннее порога в байтах выводятся в
+	// терминал обрезанными с пометкой "… (+N bytes)": JSON в атрибутах не
+	// занимает экран. Свернутые FoldValues значения не обрезаются, с
+	// контекстом Expand(ctx) значения выводятся полностью. 0 — не обрезать
+	MaxValueLen int
+	// Обрезать по MaxValueLen и сообщение записи
+	TruncateMessage bool
+	// Выводить значения с методом String() через него, даже если они
+	// реализуют encoding.TextMarshaler (идентификаторы, перечисления)
+	PreferStringer bool
+	// Выводить значения структур, карт и срезов без TextMarshaler и String()
+	// компактным JSON вместо %+v: {"id":1,"tags":["a"]}
+	AnyAsJSON bool
+
+	// Фон терминала, под который подбираются цвета. По умолчанию определяется
+	// по COLORFGBG, запрос к терминалу — BackgroundQuery
+	Background Background
+	// Цвета элементов вывода. Незаданные поля берутся из палитры для Background
+	Theme *Theme
+	// Выводить уровень меткой на цветном фоне (" INFO ", " ERRO ")
+	LevelBadges bool
+	// Ширина уровня в колонках: длинные названия сокращаются ("ERRO"),
+	// короткие дополняются пробелами, чтобы сообщения шли одной колонкой.
+	// По умолчанию 4 при LevelBadges, иначе уровень выводится полностью
+	LevelWidth int
+	// Выводить уровень символом (✔, ⚠, ✖, ●) перед названием или вместо
+	// него. Если W не терминал с кодировкой UTF-8, уровень выводится текстом
+	Icons IconMode
+	// Ключ контекста (или PushAttrs), значение которого выводится меткой
+	// с постоянным для значения цветом фона: арендатор, воркер. Помогает
+	// различать перемешанные записи разных арендаторов без чтения ID
+	HueKey string
+	// Цвета отдельных атрибутов по ключу. Точное имя важнее шаблона,
+	// из шаблонов применяется первый совпавший
+	KeyColors []KeyColor
+	// Выводить цвета даже при NO_COLOR
+	ForceColor bool
+	// Выводить текст без цветов. Цвета также отключаются переменной окружения
+	// NO_COLOR и когда W не терминал (файл, пайп, буфер)
+	DisableColor bool
+	// Вывод не в W, а в строку (RenderRecord, LiveTail): проверка терминала не нужна
+	assumeTerminal bool
+
+	// Максимальное число выводимых в терминал элементов срезов чисел и
+	// time.Duration, остальные заменяются на "…+97". По умолчанию 10
+	SliceItems int
+
+	// Группы с таким числом атрибутов и больше выводятся в терминал с общим
+	// префиксом один раз: http.request.header{accept=*/* host=example.com}.
+	// JSON не меняется. 0 — не сворачивать
+	GroupCompactThreshold int
+	// Вид групп в терминале: префикс ключей (по умолчанию), блок в фигурных
+	// скобках или строки с отступом, см. GroupStyle
+	GroupStyle GroupStyle
+	// Оставлять из атрибутов с одинаковым полным ключом (WithAttrs, атрибуты
+	// контекста и записи) последний, как это делают многие хранилища логов.
+	// Одноименные группы объединяются. В терминале атрибуты WithAttrs при
+	// этом выводятся перед атрибутами записи
+	DedupAttrs bool
+
+	// Выравнивать уровень и источник по ширине, чтобы сообщения начинались
+	// с одной колонки. Ширина уровня — по самому широкому уровню
+	Align bool
+	// Ширина источника при Align, по умолчанию 40. Длинный источник
+	// обрезается с конца: сначала теряется имя функции
+	SourceWidth int
+
+	// Переключатели вывода для управления с клавиатуры: e, w, i, d —
+	// минимальный уровень, s — скрыть или показать SQL запросы, p — пауза
+	// вывода. Обработчик только готовит переключатели, stdin и терминал
+	// не трогает: чтение клавиш запускает StartKeyboard
+	KeyboardControl bool
+
+	// Выводить каждый атрибут на отдельной строке с отступом под сообщением,
+	// как консольный encoder zap для разработки. Для записей с большим
+	// числом атрибутов в узком терминале
+	Multiline bool
 }
 
 type handlerTextColor struct {
 	source      bool
-	timeFormat  string
-	level       slog.Leveler
-	attrsPrefix string
-	groupPrefix string
-	addCxtAttr  []string
-	groups      []string
-
-	slowThreshold time.Duration
-
-	mu sync.Mutex
+	sourceLevel slog.Leveler
+
+	sourceResolver SourceResolver
+	sourcePath     SourcePathMode
+	timeFormat     string
+	timeMode       TimeMode
+	timeLocation   *time.Location
+	level          slog.Leveler
+	attrsPrefix    string
+	groupPrefix    string
+	addCxtAttr     []string
+	extractors     []CtxExtractor
+	groups         []string
+	// Имя логера для LevelRoutes, см. LoggerKey
+	loggerName string
+
+	slow            SlowConfig
+	inListThreshold int
+	groupCompact    int
+	multiline       bool
+	foldValues      int
+	maxValueLen     int
+	truncateMessage bool
+	preferStringer  bool
+	anyAsJSON       bool
+	sliceItems      int
+	largeRecord     int
+	runtimeStats    bool
+	levelFormat     levelFormat
+	hueKey          string
+	canonical       int
+	flushLevel      slog.Leveler
+	maxSQLLength    int
+	invalidUTF8     UTF8Mode
+	theme           colorizer
+
+	groupStyle GroupStyle
+	// Уровень вложенности групп GroupIndent
+	indent int
+
+	// Атрибуты WithAttrs при DedupAttrs, вложенные в группы WithGroup.
+	// attrsPrefix при этом не заполняется
+	dedup     bool
+	withAttrs []slog.Attr
+
+	errorStack bool
+
+	disableSource bool
+
+	// Время предыдущей записи для TimeDelta, общее для копий, под mu
+	lastTime *time.Time
+	// Переключатели Options.KeyboardControl, общие для копий
+	focus *focus
+
+	// Колонки уровня и источника при Options.Align, nil и 0 — без выравнивания
+	levelColumn  *levelColumn
+	sourceColumn int
+
+	deltaKeys map[string]struct{}
+	deltas    *deltaCache
+
+	occurrences *occurrences
+
+	pre *preprocessor
+
+	mu *sync.Mutex
 	w  io.Writer
 }
 
 func NewDevHandler(opt Options) slog.Handler {
-	if opt.SlowThreshold == 0 {
-		opt.SlowThreshold = time.Second
+	opt.ApplyDefaults()
+
+	h := &handlerTextColor{
+		level:           slog.LevelDebug,
+		timeFormat:      opt.TimeFormat,
+		timeMode:        opt.TimeMode,
+		timeLocation:    opt.TimeLocation,
+		lastTime:        &time.Time{},
+		source:          opt.Source && !opt.DisableSource,
+		sourceLevel:     opt.SourceLevel,
+		sourceResolver:  opt.SourceResolver,
+		sourcePath:      opt.SourcePath,
+		slow:            opt.slowConfig(),
+		inListThreshold: opt.InListThreshold,
+		groupCompact:    opt.GroupCompactThreshold,
+		multiline:       opt.Multiline || opt.GroupStyle == GroupIndent,
+		groupStyle:      opt.GroupStyle,
+		dedup:           opt.DedupAttrs,
+		errorStack:      opt.ErrorStack,
+		disableSource:   opt.DisableSource,
+		foldValues:      opt.FoldValues,
+		maxValueLen:     opt.MaxValueLen,
+		truncateMessage: opt.TruncateMessage,
+		preferStringer:  opt.PreferStringer,
+		anyAsJSON:       opt.AnyAsJSON,
+		sliceItems:      opt.SliceItems,
+		largeRecord:     opt.LargeRecord,
+		runtimeStats:    opt.RuntimeStats,
+		levelFormat:     newLevelFormat(opt),
+		hueKey:          opt.HueKey,
+		canonical:       canonicalPrecision(opt),
+		flushLevel:      opt.FlushLevel,
+		maxSQLLength:    opt.MaxSQLLength,
+		invalidUTF8:     opt.InvalidUTF8,
+		theme:           newColorizer(opt),
+		addCxtAttr:      opt.AddCxtAttr,
+		extractors:      opt.CtxExtractors,
+		pre:             newPreprocessor(opt),
+		mu:              &sync.Mutex{},
+		w:               opt.W,
+	}
+
+	if opt.Level != nil {
+		h.level = opt.Level
+	}
+	h.pre.level = h.level
+
+	if opt.KeyboardControl && !opt.assumeTerminal {
+		h.initFocus()
+	}
+
+	if opt.Align {
+		h.levelColumn = &levelColumn{}
+		h.sourceColumn = cmp.Or(opt.SourceWidth, defaultSourceWidth)
+	}
+
+	if len(opt.DeltaKeys) > 0 {
+		h.deltaKeys = make(map[string]struct{}, len(opt.DeltaKeys))
+		for _, k := range opt.DeltaKeys {
+			h.deltaKeys[k] = struct{}{}
+		}
+		h.deltas = newDeltaCache(deltaCacheSize)
 	}
 
-	return &handlerTextColor{
-		level:         slog.LevelDebug,
-		timeFormat:    time.TimeOnly,
-		source:        opt.Source,
-		slowThreshold: opt.SlowThreshold,
-		addCxtAttr:    opt.AddCxtAttr,
-		w:             opt.W,
+	if opt.FirstOccurrence {
+		h.occurrences = newOccurrences(opt.FirstOccurrenceWindow)
 	}
+
+	return h
 }
 
+// clone копирует настройки обработчика. Мьютекс и кэши общие для всех копий,
+// так как они пишут в один и тот же writer. Срез groups обрезается по длине:
+// append в копии не должен писать в массив соседних копий того же родителя.
 func (h *handlerTextColor) clone() *handlerTextColor {
-	return &handlerTextColor{
-		attrsPrefix: h.attrsPrefix,
-		groupPrefix: h.groupPrefix,
-		groups:      h.groups,
-		w:           h.w,
-		level:       h.level,
-		timeFormat:  h.timeFormat,
-	}
+	h2 := *h
+	h2.groups = slices.Clip(h.groups)
+	return &h2
 }
 
 func (h *handlerTextColor) Enabled(ctx context.Context, level slog.Level) bool {
-	return true
+	return h.pre.enabled(ctx, level, h.loggerName)
 }
 
 func (h *handlerTextColor) Handle(ctx context.Context, r slog.Record) error {
+	ok := h.pre.process(ctx, &r, h.loggerName)
+	if ok && h.focus != nil && h.focus.hidden(ctx) {
+		ok = false
+	}
+
 	buf := newBuffer()
 	defer buf.Free()
 
+	// время с предыдущей записи считается в порядке вывода
+	delta := h.timeMode != TimeClock
+	if delta {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+	}
+
+	h.renderNotices(buf)
+	if ok {
+		start := len(*buf)
+		h.render(ctx, r, buf)
+		if h.pre.sizes != nil {
+			h.measureDev(buf, start, r.PC)
+		}
+	}
+	if len(*buf) == 0 {
+		return nil
+	}
+
+	if !delta {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+	}
+
+	if h.focus != nil && h.focus.hold(*buf) {
+		return nil
+	}
+
+	_, err := h.w.Write(*buf)
+	if err != nil && h.pre.stats != nil {
+		h.pre.stats.writeError()
+	}
+	if err == nil && h.flushLevel != nil && r.Level >= h.flushLevel.Level() {
+		err = flushWriter(h.w)
+	}
+	return err
+}
+
+// renderNotices выводит служебные записи preprocessor
+func (h *handlerTextColor) renderNotices(buf *buffer) {
+	for _, n := range h.pre.notices() {
+		h.render(context.Background(), n, buf)
+	}
+}
+
+// render дописывает запись в buf, завершая ее переводом строки
+func (h *handlerTextColor) render(ctx context.Context, r slog.Record, buf *buffer) {
+	if (h.foldValues > 0 || h.maxValueLen > 0) && isExpanded(ctx) {
+		h2 := *h
+		h2.foldValues = 0
+		h2.maxValueLen = 0
+		h = &h2
+	}
+
+	if h.canonical >= 0 {
+		r = canonicalRecord(r, h.canonical)
+	}
+
+	start := len(*buf)
+
 	// write time log
 	if !r.Time.IsZero() {
 		h.appendTime(buf, r.Time)
@@ -98,57 +511,128 @@ func (h *handlerTextColor) Handle(ctx context.Context, r slog.Record) error {
 	}
 
 	// write level
-	h.appendLevel(buf, r.Level)
+	levelStart := len(*buf)
+	h.appendLevel(buf, r.Level, h.levelFormat)
+	if h.levelColumn != nil {
+		alignColumn(buf, levelStart, h.levelColumnWidth(), h.theme.reset)
+	}
 	buf.WriteByte(' ')
 
+	// write hue badge
+	if h.hueKey != "" {
+		h.appendHueBadge(ctx, buf)
+	}
+
 	// write path and line call
-	fs := runtime.CallersFrames([]uintptr{r.PC})
-	f, _ := fs.Next()
-	if h.source && f.File != "" {
+	if h.source {
+		sourceStart := len(*buf)
 		if c, ok := ctx.Value(Source).(slog.Source); ok {
-			h.appendSource(buf, &c)
-		} else {
-			src := &slog.Source{
-				Function: f.Function,
-				File:     f.File,
-				Line:     f.Line,
+			h.appendSource(buf, &c, false)
+		} else if sourceLevelEnabled(h.sourceLevel, r.Level) {
+			if src, ok := resolveSource(h.sourceResolver, r.PC); ok {
+				h.appendSource(buf, &src, true)
+			}
+		}
+		if h.sourceColumn > 0 {
+			if len(*buf) > sourceStart {
+				*buf = (*buf)[:len(*buf)-1] // space after source
 			}
+			alignColumn(buf, sourceStart, h.sourceColumn, h.theme.reset)
+			buf.WriteByte(' ')
+		}
+	}
 
-			h.appendSource(buf, src)
+	// write first occurrence badge
+	repeat := false
+	if h.occurrences != nil {
+		if h.occurrences.first(recordFingerprint(ctx, r)) {
+			h.appendNewBadge(buf)
+		} else {
+			repeat = true
 		}
 	}
 
 	// write message
-	h.appendMessage(buf, r.Level, r.Message)
+	msg := r.Message
+	t, msgf := msgfFrom(ctx)
+	if msgf {
+		msg = t.text()
+	}
+	h.appendMessage(buf, r.Level, msg, repeat)
+
+	// groups of WithGroup enclose scope and record attributes as one block
+	var grouped []slog.Attr
+	appendRecordAttr := func(attr slog.Attr) {
+		if h.dedup || h.groupStyle != GroupDots && len(h.groups) > 0 {
+			grouped = append(grouped, attr)
+		} else {
+			h.appendAttr(buf, attr, h.groupPrefix, h.groups)
+		}
+	}
+
+	// write context scope attributes
+	for _, attr := range core.ScopeAttrs(ctx) {
+		appendRecordAttr(attr)
+	}
 
 	// write attributes
 	r.Attrs(func(attr slog.Attr) bool {
-		h.appendAttr(buf, attr, h.groupPrefix, h.groups)
+		if msgf {
+			if _, ok := t.names[attr.Key]; ok {
+				return true
+			}
+		}
+		appendRecordAttr(attr)
 		return true
 	})
+	switch {
+	case h.dedup:
+		attrs := grouped
+		if len(h.groups) > 0 && len(grouped) > 0 {
+			attrs = []slog.Attr{nestGroups(h.groups, grouped)}
+		}
+		h.appendScopedAttrs(buf, dedupAttrs(append(slices.Clip(h.withAttrs), attrs...)), 0)
+	case len(grouped) > 0:
+		h.appendAttr(buf, nestGroups(h.groups, grouped), "", nil)
+	}
+
+	// write runtime stats
+	if h.runtimeStats && r.Level >= slog.LevelError {
+		h.appendLineBreak(buf)
+		h.appendRuntimeStats(buf)
+	}
 
 	// write context values
 	h.AddValueCtx(ctx, buf)
 
 	// write handlerTextColor attributes
 	if len(h.attrsPrefix) > 0 {
+		if h.multiline {
+			// prefix starts with its own line break
+			*buf = bytes.TrimRight(*buf, " ")
+		}
 		buf.WriteString(h.attrsPrefix)
 		buf.WriteByte(' ')
 	}
 
+	// write causes of wrapped errors
+	h.appendErrorChains(ctx, buf, r)
+
 	// write sql
-	h.appendSql(ctx, r.Level, buf)
+	h.appendSql(ctx, r.Level, buf, repeat)
 
-	if len(*buf) == 0 {
-		return nil
+	if len(*buf) == start {
+		return
+	}
+	if h.multiline {
+		// no trailing spaces on attribute lines
+		*buf = bytes.TrimRight(*buf, " ")
+		if (*buf)[len(*buf)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		return
 	}
 	(*buf)[len(*buf)-1] = '\n' // replace last space with newline
-
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	_, err := h.w.Write(*buf)
-	return err
 }
 
 func (h *handlerTextColor) WithAttrs(attrs []slog.Attr) slog.Handler {
@@ -157,13 +641,26 @@ func (h *handlerTextColor) WithAttrs(attrs []slog.Attr) slog.Handler {
 	}
 
 	h2 := h.clone()
+	h2.loggerName = loggerName(attrs, h.groups, h.loggerName)
+
+	if h.dedup {
+		if len(h.groups) > 0 {
+			attrs = []slog.Attr{nestGroups(h.groups, attrs)}
+		}
+		h2.withAttrs = append(slices.Clip(h.withAttrs), attrs...)
+		return h2
+	}
 
 	buf := newBuffer()
 	defer buf.Free()
 
 	// write attributes to buffer
-	for _, attr := range attrs {
-		h.appendAttr(buf, attr, h.groupPrefix, h.groups)
+	if h.groupStyle != GroupDots && len(h.groups) > 0 {
+		h.appendAttr(buf, nestGroups(h.groups, attrs), "", nil)
+	} else {
+		for _, attr := range attrs {
+			h.appendAttr(buf, attr, h.groupPrefix, h.groups)
+		}
 	}
 	h2.attrsPrefix = h.attrsPrefix + string(*buf)
 	return h2
@@ -174,80 +671,181 @@ func (h *handlerTextColor) WithGroup(name string) slog.Handler {
 		return h
 	}
 	h2 := h.clone()
-	h2.groupPrefix += name + "."
+	if h.groupStyle == GroupDots {
+		h2.groupPrefix += name + "."
+	}
 	h2.groups = append(h2.groups, name)
 	return h2
 }
 
 func (h *handlerTextColor) AddValueCtx(ctx context.Context, buf *buffer) error {
+	if p, ok := ctx.Value(prefetchKey{}).(*prefetched); ok {
+		buf.Write(p.text(ctx, h))
+	} else {
+		h.appendCtxValues(ctx, buf)
+	}
+
+	buf.WriteByte(' ')
+
+	return nil
+}
+
+// appendCtxValues выводит значения AddCxtAttr и CtxExtractors
+func (h *handlerTextColor) appendCtxValues(ctx context.Context, buf *buffer) {
 	for _, v := range h.addCxtAttr {
 		if c := ctx.Value(v); c != nil {
+			h.appendLineBreak(buf)
 			h.appendCtxValue(buf, v, fmt.Sprintf("%v ", c))
 		}
 	}
 
-	buf.WriteByte(' ')
-
-	return nil
+	for _, e := range h.extractors {
+		if attr, ok := e.Extract(ctx); ok {
+			h.appendAttr(buf, attr, "", nil)
+		}
+	}
 }
 
 func (h *handlerTextColor) appendTime(buf *buffer, t time.Time) {
-	buf.WriteString(Faint)
-	*buf = t.AppendFormat(*buf, h.timeFormat)
-	buf.WriteString(Reset)
+	if h.timeMode != TimeDelta {
+		if h.timeLocation != nil {
+			t = t.In(h.timeLocation)
+		}
+		buf.WriteString(h.theme.Time)
+		*buf = t.AppendFormat(*buf, h.timeFormat)
+		buf.WriteString(h.theme.reset)
+	}
+
+	if h.timeMode != TimeClock {
+		if h.timeMode == TimeClockDelta {
+			buf.WriteByte(' ')
+		}
+		h.appendTimeDelta(buf, t)
+	}
 }
 
-func (h *handlerTextColor) appendLevel(buf *buffer, level slog.Level) {
-	colorLevel := Red
-	if level.Level() == slog.LevelInfo {
-		colorLevel = BrightGreen
-	} else if level.Level() == slog.LevelWarn {
-		colorLevel = BrightYellow
+// levelFormat — оформление уровня записи, см. Options.LevelBadges
+type levelFormat struct {
+	icons IconMode
+	badge bool
+	// 0 — без сокращения и выравнивания
+	width int
+}
+
+func newLevelFormat(opt Options) levelFormat {
+	f := levelFormat{icons: iconMode(opt), badge: opt.LevelBadges, width: opt.LevelWidth}
+	if f.badge && f.width == 0 {
+		f.width = defaultLevelWidth
+	}
+	return f
+}
+
+const defaultLevelWidth = 4
+
+func (h *handlerTextColor) appendLevel(buf *buffer, level slog.Level, f levelFormat) {
+	colorLevel, colorBadge := h.theme.LevelDebug, h.theme.LevelDebugBadge
+	switch l := level.Level(); {
+	case l == slog.LevelInfo:
+		colorLevel, colorBadge = h.theme.LevelInfo, h.theme.LevelInfoBadge
+	case l == slog.LevelWarn:
+		colorLevel, colorBadge = h.theme.LevelWarn, h.theme.LevelWarnBadge
+	case l >= slog.LevelError:
+		colorLevel, colorBadge = h.theme.LevelError, h.theme.LevelErrorBadge
+	}
+
+	name := level.String()
+	if info, ok := registeredLevel(level); ok {
+		name = info.name
+		if info.color != "" && h.theme.reset != "" {
+			colorLevel = info.color
+		}
+	}
+
+	if f.width > 0 {
+		// короткое сокращение без многоточия: "ERRO"
+		name = cutWidth(name, f.width)
+		name += strings.Repeat(" ", f.width-DisplayWidth(name))
+	}
+
+	switch f.icons {
+	case IconsPrefix:
+		name = levelIcon(level) + " " + name
+	case IconsOnly:
+		name = levelIcon(level)
+	}
+
+	if f.badge {
+		buf.WriteString(colorBadge)
+		buf.WriteByte(' ')
+		buf.WriteString(name)
+		buf.WriteByte(' ')
+		buf.WriteString(h.theme.reset)
+		return
 	}
 
 	buf.WriteString(colorLevel)
-	buf.WriteString(level.String())
-	buf.WriteString(Reset)
+	buf.WriteString(name)
+	buf.WriteString(h.theme.reset)
 }
 
-func (h *handlerTextColor) appendSource(buf *buffer, src *slog.Source) {
-	dir, file := filepath.Split(src.File)
+// appendSource пишет источник записи. resolved — источник от resolveSource:
+// путь от своего резолвера не сокращается, если SourcePath не задан явно.
+// Источники из контекста (gorm логер) сокращаются всегда
+func (h *handlerTextColor) appendSource(buf *buffer, src *slog.Source, resolved bool) {
+	// источник без файла, например GormInternal
+	if src.File != "" {
+		file := src.File
+		if !resolved || h.sourceResolver == nil || h.sourcePath != SourcePathTrimmed {
+			file = sourcePath(file, h.sourcePath)
+		}
 
-	buf.WriteString(Faint)
-	buf.WriteString(path.Join(filepath.Base(dir), file))
+		buf.WriteString(h.theme.Source)
+		buf.WriteString(file)
 
-	if src.Line != 0 {
-		buf.WriteByte(':')
-		buf.WriteString(strconv.Itoa(src.Line))
-		buf.WriteString(Reset)
-	}
+		if src.Line != 0 {
+			buf.WriteByte(':')
+			buf.WriteString(strconv.Itoa(src.Line))
+			buf.WriteString(h.theme.reset)
+		}
 
-	buf.WriteString(" ")
+		buf.WriteString(" ")
+	}
 
-	buf.WriteString(Blue)
+	buf.WriteString(h.theme.Function)
 	buf.WriteString(getFuncNameSlog(src.Function))
-	buf.WriteString(Reset)
+	buf.WriteString(h.theme.reset)
 
 	buf.WriteByte(' ')
 }
 
-func (h *handlerTextColor) appendMessage(buf *buffer, level slog.Level, msg string) {
+func (h *handlerTextColor) appendMessage(buf *buffer, level slog.Level, msg string, repeat bool) {
 	if msg == "" {
 		return
 	}
 
-	colorMsg := Cyan
+	colorMsg := h.theme.Message
 	if level == slog.LevelError {
-		colorMsg = Red
+		colorMsg = h.theme.ErrorMessage
+	}
+	if repeat {
+		colorMsg = h.theme.Faint
 	}
 
+	msg = sanitizeUTF8(msg, h.invalidUTF8)
+
 	buf.WriteString(colorMsg)
-	buf.WriteString(msg)
-	buf.WriteString(Reset)
+	if h.truncateMessage && h.maxValueLen > 0 && len(msg) > h.maxValueLen {
+		cut := h.appendTruncated(buf, msg, false)
+		buf.WriteString(h.theme.reset)
+		h.appendTruncatedNote(buf, len(msg)-cut)
+	} else {
+		buf.WriteString(msg)
+	}
+	buf.WriteString(h.theme.reset)
 	buf.WriteString(" ")
 }
 
-func (h *handlerTextColor) appendSql(ctx context.Context, level slog.Level, buf *buffer) {
+func (h *handlerTextColor) appendSql(ctx context.Context, level slog.Level, buf *buffer, repeat bool) {
 	sql := ctx.Value(Sql)
 	if sql == nil {
 		return
@@ -256,42 +854,77 @@ func (h *handlerTextColor) appendSql(ctx context.Context, level slog.Level, buf
 	buf.WriteString("\n")
 
 	if c, ok := ctx.Value(Duration).(time.Duration); ok {
-		colorDuration := Green
+		colorDuration := h.theme.Duration
 
-		if c > h.slowThreshold {
-			colorDuration = Red
+		sqlStr, _ := sql.(string)
+		if h.slow.IsSlow(sqlStr, c) {
+			colorDuration = h.theme.SlowDuration
 		}
 
-		duration := c.Seconds()
-		durStr := strconv.FormatFloat(duration, 'f', 4, 64)
+		durStr := badgeDuration(c)
+		if h.levelColumn != nil {
+			// fixed width badge with Align
+			durStr = strings.Repeat(" ", max(badgeWidth-DisplayWidth(durStr), 0)) + durStr
+		}
 
 		buf.WriteString(colorDuration)
-		buf.WriteString(fmt.Sprintf("[%v] ", durStr))
-		buf.WriteString(Reset)
+		buf.WriteString("[" + durStr + "] ")
+		buf.WriteString(h.theme.reset)
 	}
 
 	if c := ctx.Value(Rows); c != nil {
-		buf.WriteString(Yellow)
+		buf.WriteString(h.theme.Rows)
 		buf.WriteString(fmt.Sprintf("rows:%v ", c))
-		buf.WriteString(Reset)
+		buf.WriteString(h.theme.reset)
+	}
+
+	full := fmt.Sprint(sql)
+	sqlStr := sanitizeUTF8(full, h.invalidUTF8)
+	sqlStr = collapseInLists(sqlStr, h.inListThreshold)
+	sqlStr = TruncateWidth(sqlStr, h.maxSQLLength)
+
+	// write hash of the full statement
+	if hash, ok := fullSQLHash(ctx, full, sqlStr); ok {
+		h.appendCtxValue(buf, SqlHash, hash+" ")
 	}
 
-	colorSql := Magenta
+	colorSql := h.theme.SQL
 	if level == slog.LevelError {
-		colorSql = Red
+		colorSql = h.theme.ErrorMessage
+	}
+	if repeat {
+		colorSql = h.theme.Faint
 	}
 
-	buf.WriteString(colorSql)
-	buf.WriteString(fmt.Sprintf("%v ", sql))
-	buf.WriteString(Reset)
+	if h.theme.SQLKeyword != "" && colorSql == h.theme.SQL {
+		appendSQLKeywords(buf, sqlStr, colorSql, h.theme.SQLKeyword, h.theme.reset)
+	} else {
+		buf.WriteString(colorSql)
+		buf.WriteString(sqlStr)
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(h.theme.reset)
 
 	buf.WriteString("\n")
 }
 
+// appendDuration выводит длительность (Span и т.п.) цветом SQL запросов:
+// медленную — при превышении SlowThreshold
+func (h *handlerTextColor) appendDuration(buf *buffer, d time.Duration) {
+	color := h.theme.Duration
+	if h.slow.Threshold > 0 && d > h.slow.Threshold {
+		color = h.theme.SlowDuration
+	}
+
+	buf.WriteString(color)
+	buf.WriteString(d.String())
+	buf.WriteString(h.theme.reset)
+}
+
 func (h *handlerTextColor) appendCtxValue(buf *buffer, key, value string) {
-	buf.WriteString(Faint)
+	buf.WriteString(h.theme.Key)
 	buf.WriteString(key + "=")
-	buf.WriteString(Reset)
+	buf.WriteString(h.theme.reset)
 	buf.WriteString(value)
 }
 
@@ -305,37 +938,142 @@ func (h *handlerTextColor) appendAttr(buf *buffer, attr slog.Attr, groupsPrefix
 	switch attr.Value.Kind() {
 	case slog.KindAny:
 		if err, ok := attr.Value.Any().(logError); ok {
+			h.appendLineBreak(buf)
 			h.appendTintError(buf, err, attr.Key, groupsPrefix)
 			buf.WriteByte(' ')
 			return
 		}
 	case slog.KindGroup:
+		members := attr.Value.Group()
 		if attr.Key != "" {
 			groupsPrefix += attr.Key + "."
-			groups = append(groups, attr.Key)
+			groups = append(slices.Clip(groups), attr.Key)
+
+			switch {
+			case len(members) == 0:
+				return
+			case h.groupStyle == GroupBrackets:
+				h.appendLineBreak(buf)
+				h.appendGroupBlock(buf, attr.Key, "={", members, groups)
+				return
+			case h.groupStyle == GroupIndent:
+				h.appendIndentGroup(buf, attr.Key, members, groups)
+				return
+			case h.groupCompact > 0 && len(members) >= h.groupCompact:
+				h.appendLineBreak(buf)
+				h.appendGroupBlock(buf, strings.TrimSuffix(groupsPrefix, "."), "{", members, groups)
+				return
+			}
 		}
-		for _, groupAttr := range attr.Value.Group() {
+		for _, groupAttr := range members {
 			h.appendAttr(buf, groupAttr, groupsPrefix, groups)
 		}
 		return
 	}
 
-	h.appendKey(buf, attr.Key, groupsPrefix)
-	h.appendValue(buf, attr.Value, true)
+	h.appendLineBreak(buf)
+
+	// полный ключ: в свернутой группе groupsPrefix пустой
+	fullKey := attr.Key
+	if len(groups) > 0 && (h.theme.keys != nil || h.deltas != nil) {
+		fullKey = strings.Join(groups, ".") + "." + fullKey
+	}
+
+	if color, ok := h.theme.keys.match(fullKey, attr.Key); ok {
+		h.appendColoredAttr(buf, color, groupsPrefix+attr.Key, attr.Value)
+	} else if attr.Key == Duration && groupsPrefix == "" && attr.Value.Kind() == slog.KindDuration {
+		h.appendKey(buf, attr.Key, groupsPrefix)
+		h.appendDuration(buf, attr.Value.Duration())
+	} else {
+		h.appendKey(buf, attr.Key, groupsPrefix)
+		h.appendValue(buf, attr.Value, true)
+	}
+	if h.deltas != nil {
+		h.appendDelta(buf, fullKey, attr.Value)
+	}
+	buf.WriteByte(' ')
+}
+
+// appendGroupBlock выводит группу одним блоком в фигурных скобках: общий
+// префикс свернутой группы (http.request.header{accept=*/* host=example.com})
+// или ключ группы GroupBrackets (header={accept=*/* host=example.com})
+func (h *handlerTextColor) appendGroupBlock(buf *buffer, name, open string, members []slog.Attr, groups []string) {
+	buf.WriteString(h.theme.Key)
+	appendString(buf, name, false, true)
+	buf.WriteString(open)
+	buf.WriteString(h.theme.reset)
+
+	// ключи участников выводятся без префикса, в одну строку
+	if h.multiline {
+		h2 := *h
+		h2.multiline = false
+		h = &h2
+	}
+	start := len(*buf)
+	for _, m := range members {
+		h.appendAttr(buf, m, "", groups)
+	}
+	if len(*buf) > start && (*buf)[len(*buf)-1] == ' ' {
+		*buf = (*buf)[:len(*buf)-1]
+	}
+
+	buf.WriteString(h.theme.Key)
+	buf.WriteByte('}')
+	buf.WriteString(h.theme.reset)
+	buf.WriteByte(' ')
+}
+
+// appendLineBreak начинает новую строку атрибута в режиме Multiline,
+// вместо пробелов после предыдущего элемента
+func (h *handlerTextColor) appendLineBreak(buf *buffer) {
+	if !h.multiline {
+		return
+	}
+
+	*buf = bytes.TrimRight(*buf, " ")
+	buf.WriteByte('\n')
+	for range h.indent + 1 {
+		buf.WriteString(multilineIndent)
+	}
+}
+
+// appendIndentGroup выводит ключ группы GroupIndent отдельной строкой,
+// атрибуты группы — строками с отступом на уровень глубже
+func (h *handlerTextColor) appendIndentGroup(buf *buffer, key string, members []slog.Attr, groups []string) {
+	h.appendLineBreak(buf)
+	buf.WriteString(h.theme.Key)
+	appendString(buf, key, false, true)
+	buf.WriteByte(':')
+	buf.WriteString(h.theme.reset)
 	buf.WriteByte(' ')
+
+	h2 := *h
+	h2.indent++
+	for _, m := range members {
+		h2.appendAttr(buf, m, "", groups)
+	}
+}
+
+// nestGroups вкладывает attrs в группы WithGroup: a={b={attrs}}
+func nestGroups(groups []string, attrs []slog.Attr) slog.Attr {
+	attr := slog.Attr{Key: groups[len(groups)-1], Value: slog.GroupValue(attrs...)}
+	for i := len(groups) - 2; i >= 0; i-- {
+		attr = slog.Attr{Key: groups[i], Value: slog.GroupValue(attr)}
+	}
+	return attr
 }
 
 func (h *handlerTextColor) appendKey(buf *buffer, key, groups string) {
-	buf.WriteString(Faint)
+	buf.WriteString(h.theme.Key)
 	appendString(buf, groups+key, false, true)
 	buf.WriteByte('=')
-	buf.WriteString(Reset)
+	buf.WriteString(h.theme.reset)
 }
 
 func (h *handlerTextColor) appendValue(buf *buffer, v slog.Value, quote bool) {
 	switch v.Kind() {
 	case slog.KindString:
-		appendString(buf, v.String(), quote, true)
+		h.appendStringValue(buf, v.String(), quote)
 	case slog.KindInt64:
 		*buf = strconv.AppendInt(*buf, v.Int64(), 10)
 	case slog.KindUint64:
@@ -369,28 +1107,47 @@ func (h *handlerTextColor) appendValue(buf *buffer, v slog.Value, quote bool) {
 
 		switch cv := v.Any().(type) {
 		case slog.Level:
-			h.appendLevel(buf, cv)
+			h.appendLevel(buf, cv, levelFormat{})
 		case encoding.TextMarshaler:
+			if s, ok := cv.(fmt.Stringer); ok && h.preferStringer {
+				h.appendStringValue(buf, s.String(), quote)
+				break
+			}
 			data, err := cv.MarshalText()
 			if err != nil {
 				break
 			}
-			appendString(buf, string(data), quote, true)
+			h.appendStringValue(buf, string(data), quote)
 		case *slog.Source:
-			h.appendSource(buf, cv)
+			h.appendSource(buf, cv, false)
+		case error:
+			// стек %+v выводит appendErrorChains
+			h.appendStringValue(buf, cv.Error(), quote)
+		case fmt.Stringer:
+			h.appendStringValue(buf, cv.String(), quote)
 		default:
-			appendString(buf, fmt.Sprintf("%+v", cv), quote, true)
+			if h.appendNumericSlice(buf, cv) {
+				break
+			}
+			if h.anyAsJSON {
+				if js, ok := compactJSON(cv); ok {
+					// JSON is written unquoted to stay copy-pasteable
+					h.appendStringValue(buf, js, false)
+					break
+				}
+			}
+			h.appendStringValue(buf, fmt.Sprintf("%+v", cv), quote)
 		}
 	}
 }
 
 func (h *handlerTextColor) appendTintError(buf *buffer, err logError, attrKey, groupsPrefix string) {
-	buf.WriteString(Blue)
+	buf.WriteString(h.theme.ErrorKey)
 	appendString(buf, groupsPrefix+attrKey, true, true)
 	buf.WriteByte('=')
-	buf.WriteString(Faint)
-	appendString(buf, err.Error(), true, true)
-	buf.WriteString(Reset)
+	buf.WriteString(h.theme.Faint)
+	appendString(buf, sanitizeUTF8(err.Error(), h.invalidUTF8), true, true)
+	buf.WriteString(h.theme.reset)
 }
 
 func appendString(buf *buffer, s string, quote, color bool) {
@@ -464,6 +1221,21 @@ func needsQuoting(s string) bool {
 
 type logError struct{ error }
 
+// Unwrap открывает errors.Is, errors.As и классы ошибок Digest для значения Err
+func (e logError) Unwrap() error { return e.error }
+
+// Err возвращает атрибут error с ошибкой err. Dev обработчик выделяет его:
+// ключ цветом ErrorKey, значение — Faint, с цепочкой причин под записью.
+// Другие обработчики выводят err.Error():
+//
+//	slog.Error("payment failed", logger.Err(err), "order", id)
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Any("error", nil)
+	}
+	return slog.Any("error", logError{err})
+}
+
 var safeSet = [utf8.RuneSelf]bool{
 	' ':      true,
 	'!':      true,
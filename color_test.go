@@ -0,0 +1,1693 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestDeltaKeys(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, DeltaKeys: []string{"queue_depth"}}))
+
+	log.Info("queue", "queue_depth", 105)
+	log.Info("queue", "queue_depth", 120)
+	log.Info("queue", "queue_depth", 100)
+
+	lines := strings.Split(strings.TrimSpace(stripANSI(buf.String())), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got: %d", len(lines))
+	}
+
+	if strings.Contains(lines[0], "(") {
+		t.Errorf("First record should not contain delta: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "queue_depth=120 (+15)") {
+		t.Errorf("Expected delta +15, got: %s", lines[1])
+	}
+	if !strings.Contains(lines[2], "queue_depth=100 (-20)") {
+		t.Errorf("Expected delta -20, got: %s", lines[2])
+	}
+}
+
+func TestCollapseInLists(t *testing.T) {
+	tests := []struct {
+		sql, want string
+	}{
+		{"SELECT * FROM t WHERE id IN (1,2,3,4)", "SELECT * FROM t WHERE id IN (… 4 values …)"},
+		{"SELECT * FROM t WHERE id IN (1,2)", "SELECT * FROM t WHERE id IN (1,2)"},
+		{"SELECT * FROM t WHERE name IN ('a,b','c','d','e') AND x = 1", "SELECT * FROM t WHERE name IN (… 4 values …) AND x = 1"},
+		{"SELECT * FROM t WHERE id IN (SELECT id FROM u WHERE a IN (1,2,3,4))", "SELECT * FROM t WHERE id IN (SELECT id FROM u WHERE a IN (… 4 values …))"},
+		{"SELECT * FROM t WHERE login = 'IN (1,2,3,4)'", "SELECT * FROM t WHERE login = 'IN (1,2,3,4)'"},
+		{"SELECT * FROM t WHERE (a,b) IN ((1,2),(3,4),(5,6),(7,8))", "SELECT * FROM t WHERE (a,b) IN (… 4 values …)"},
+	}
+
+	for _, tt := range tests {
+		if got := collapseInLists(tt.sql, 3); got != tt.want {
+			t.Errorf("collapseInLists(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestLevelRules(t *testing.T) {
+	refused := regexp.MustCompile("connection refused")
+
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, LevelRules: []LevelRule{
+		{ErrMessage: refused, After: time.Hour, Level: slog.LevelError},
+		{ErrMessage: refused, Level: slog.LevelWarn},
+		{Attr: "component", AttrValue: "healthcheck", Level: slog.LevelDebug},
+	}}))
+
+	log.Error("dial failed", "error", errors.New("dial tcp: connection refused"))
+	log.Info("ping", "component", "healthcheck")
+	log.Info("ping", "component", "api")
+
+	lines := strings.Split(strings.TrimSpace(stripANSI(buf.String())), "\n")
+	for i, want := range []string{"WARN", "DEBUG", "INFO"} {
+		if !strings.Contains(lines[i], " "+want+" ") {
+			t.Errorf("Line %d: expected level %s, got: %s", i, want, lines[i])
+		}
+	}
+}
+
+func TestSqlFingerprint(t *testing.T) {
+	a := sqlFingerprint("SELECT * FROM users WHERE id = 10 AND name = 'john'  AND tag IN (1,2,3)")
+	b := sqlFingerprint("SELECT *  FROM users WHERE id = 7 AND name = 'it''s' AND tag IN (4)")
+
+	if a != b {
+		t.Errorf("Fingerprints should be equal:\n%s\n%s", a, b)
+	}
+	if want := "SELECT * FROM users WHERE id = ? AND name = ? AND tag IN (?)"; a != want {
+		t.Errorf("Expected %q, got: %q", want, a)
+	}
+
+	// комментарии корреляции и sqlcommenter не входят в отпечаток
+	c := sqlFingerprint("/* request_id=r1 */ SELECT * FROM users -- note\nWHERE id = 1 /*route='%2Fa'*/;")
+	if want := "SELECT * FROM users WHERE id = ? ;"; c != want {
+		t.Errorf("Expected comments stripped %q, got: %q", want, c)
+	}
+	if d := sqlFingerprint("SELECT '/* not a comment */' -- tail"); d != "SELECT ?" {
+		t.Errorf("Expected literal kept as placeholder, got: %q", d)
+	}
+}
+
+func TestFirstOccurrence(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, FirstOccurrence: true}))
+	theme := DefaultTheme()
+
+	query := func(sql string) string {
+		buf.Reset()
+		ctx := context.WithValue(context.Background(), Sql, sql)
+		ctx = context.WithValue(ctx, Rows, int64(1))
+		log.InfoContext(ctx, "")
+		return buf.String()
+	}
+
+	// первый запрос помечен, повтор с другими значениями — бледный, без метки
+	first := query("SELECT * FROM users WHERE id = 1")
+	if !strings.Contains(first, theme.Badge+" NEW ") || strings.Contains(first, theme.Faint+"SELECT") {
+		t.Errorf("Expected first query marked as new, got %q", first)
+	}
+	repeat := query("SELECT * FROM users WHERE id = 2")
+	if strings.Contains(repeat, " NEW ") || !strings.Contains(repeat, theme.Faint+"SELECT") {
+		t.Errorf("Expected repeated query faint and unmarked, got %q", repeat)
+	}
+	if other := query("SELECT * FROM orders"); !strings.Contains(other, " NEW ") {
+		t.Errorf("Expected different query marked as new, got %q", other)
+	}
+
+	// запись с тем же сообщением, но другим уровнем — новая
+	buf.Reset()
+	log.Info("started")
+	log.Info("started")
+	log.Warn("started")
+	if n := strings.Count(buf.String(), " NEW "); n != 2 {
+		t.Errorf("Expected 2 new badges, got %d in %q", n, buf.String())
+	}
+}
+
+func TestBatchIsContiguous(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf}))
+	slog.SetDefault(log)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Info("noise")
+		}()
+	}
+
+	err := Batch(context.Background()).
+		Info("summary").
+		Warn("slow query 1").
+		Warn("slow query 2").
+		Emit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	out := stripANSI(buf.String())
+	start := strings.Index(out, "summary")
+	end := strings.Index(out, "slow query 2")
+	if start < 0 || end < 0 || strings.Contains(out[start:end], "noise") {
+		t.Errorf("Batch records should be contiguous:\n%s", out)
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+	}{
+		{"abc", 3},
+		{"日本語", 6},
+		{"a😀b", 4},
+		{Red + "ok" + Reset, 2},
+		{"é", 1},
+	}
+
+	for _, tt := range tests {
+		if w := DisplayWidth(tt.s); w != tt.width {
+			t.Errorf("DisplayWidth(%q) = %d, want %d", tt.s, w, tt.width)
+		}
+	}
+
+	if s := TruncateWidth("日本語テキスト", 7); s != "日本語…" {
+		t.Errorf("Unexpected truncation: %q", s)
+	}
+}
+
+func FuzzSanitizeUTF8(f *testing.F) {
+	f.Add("valid строка")
+	f.Add("bad \xff\xfe bytes")
+	f.Add("truncated \xe6\x97")
+	f.Add("\x00\x1b[31m")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		for _, mode := range []UTF8Mode{UTF8Replace, UTF8Escape} {
+			out := sanitizeUTF8(s, mode)
+			if !utf8.ValidString(out) {
+				t.Fatalf("mode %d: invalid UTF-8 in output %q", mode, out)
+			}
+			if utf8.ValidString(s) && out != s {
+				t.Fatalf("mode %d: valid input changed: %q -> %q", mode, s, out)
+			}
+			if again := sanitizeUTF8(out, mode); again != out {
+				t.Fatalf("mode %d: not idempotent: %q -> %q", mode, out, again)
+			}
+		}
+
+		var buf bytes.Buffer
+		log := slog.New(NewDevHandler(Options{W: &buf, InvalidUTF8: UTF8Escape}))
+		log.Info(s, "value", s)
+		if !utf8.Valid(buf.Bytes()) {
+			t.Fatalf("Dev handler produced invalid UTF-8 for %q", s)
+		}
+	})
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	h := NewDevHandler(Options{W: &buf, SampleRate: 100, SampleReportInterval: 5 * time.Second}).(*handlerTextColor)
+	h.pre.sampler.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	logSecond := func(n int) int {
+		buf.Reset()
+		for i := 0; i < n; i++ {
+			h.Handle(ctx, slog.NewRecord(now, slog.LevelInfo, "storm", 0))
+			now = now.Add(time.Second / time.Duration(n))
+		}
+		return strings.Count(buf.String(), "storm")
+	}
+
+	// шторм: 5000 записей в секунду при цели 100
+	for i := 0; i < 6; i++ {
+		if got := logSecond(5000); got > 100 {
+			t.Errorf("Second %d: expected at most 100 lines, got %d", i, got)
+		}
+	}
+
+	if h.pre.sampler.ratio >= 0.1 {
+		t.Errorf("Expected sample ratio to tighten, got %f", h.pre.sampler.ratio)
+	}
+
+	// ошибки не отбрасываются
+	buf.Reset()
+	h.Handle(ctx, slog.NewRecord(now, slog.LevelError, "failure", 0))
+	if !strings.Contains(buf.String(), "failure") {
+		t.Error("Error records must never be sampled out")
+	}
+
+	// поток стих — доля возвращается к 1
+	for i := 0; i < 10; i++ {
+		logSecond(10)
+	}
+	if got := logSecond(10); got != 10 {
+		t.Errorf("Expected all 10 lines after storm, got %d", got)
+	}
+}
+
+func TestSamplingReport(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	h := NewDevHandler(Options{W: &buf, SampleRate: 10, SampleReportInterval: time.Second}).(*handlerTextColor)
+	h.pre.sampler.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		h.Handle(context.Background(), slog.NewRecord(now, slog.LevelInfo, "storm", 0))
+		now = now.Add(20 * time.Millisecond)
+	}
+
+	out := stripANSI(buf.String())
+	if !strings.Contains(out, "log sampling") || !strings.Contains(out, "sample_ratio=") {
+		t.Errorf("Expected periodic sampling report, got:\n%s", out)
+	}
+}
+
+func TestShutdownSummary(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, Summary: true}))
+
+	log.Info("start")
+	log.Warn("retry")
+	for i, d := range []time.Duration{5, 3000, 40, 1200, 7, 900, 2} {
+		ctx := context.WithValue(context.Background(), Sql, fmt.Sprintf("SELECT %d", i))
+		ctx = context.WithValue(ctx, Duration, d*time.Millisecond)
+		log.InfoContext(ctx, "")
+	}
+
+	buf.Reset()
+	if err := Shutdown(log); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stripANSI(buf.String())), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected header and 5 slowest queries, got:\n%s", buf.String())
+	}
+
+	for _, want := range []string{"log summary", "records.info=8", "records.warn=1", "slow_queries=2", "write_errors=0"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("Expected %q in summary header, got: %s", want, lines[0])
+		}
+	}
+
+	if !strings.HasSuffix(lines[1], "3s SELECT 1") || !strings.HasSuffix(lines[5], "7ms SELECT 4") {
+		t.Errorf("Expected slowest queries in descending order, got:\n%s", strings.Join(lines[1:], "\n"))
+	}
+}
+
+func TestGroupCompaction(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, GroupCompactThreshold: 3}))
+
+	log.Info("request",
+		slog.Group("http", slog.Group("request",
+			slog.String("method", "GET"),
+			slog.Group("header", slog.String("accept", "*/*"), slog.String("host", "example.com"), slog.Int("length", 0)),
+		)),
+		slog.Group("db", slog.String("name", "main")),
+	)
+
+	out := stripANSI(buf.String())
+	for _, want := range []string{
+		"http.request.method=GET ",
+		"http.request.header{accept=*/* host=example.com length=0} ",
+		"db.name=main",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got: %s", want, out)
+		}
+	}
+}
+
+func TestFoldValues(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, FoldValues: 20}))
+
+	body := "first line of body\n" + strings.Repeat("more text\n", 12)
+	log.Info("response", "body", body, "status", "ok")
+
+	out := stripANSI(buf.String())
+	if !strings.Contains(out, `body="first line of body" … (+12 lines, 139B) status=ok`) {
+		t.Errorf("Expected folded value, got: %q", out)
+	}
+
+	buf.Reset()
+	log.Info("response", "token", strings.Repeat("x", 5000))
+	if out := stripANSI(buf.String()); !strings.Contains(out, "token=xxxxxxxxxxxxxxxxxxx … (4.9KB)") {
+		t.Errorf("Expected truncated single-line value, got: %q", out)
+	}
+
+	// многострочное значение сворачивается и короче порога
+	buf.Reset()
+	log.Info("response", "body", "ok\ndone")
+	if out := stripANSI(buf.String()); !strings.Contains(out, `body=ok … (+1 lines, 7B)`) {
+		t.Errorf("Expected short multiline value folded, got: %q", out)
+	}
+
+	buf.Reset()
+	log.InfoContext(Expand(context.Background()), "response", "body", body)
+	if out := stripANSI(buf.String()); !strings.Contains(out, "more text") || strings.Contains(out, "lines,") {
+		t.Errorf("Expected full value with Expand, got: %q", out)
+	}
+}
+
+func TestMaxValueLen(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, MaxValueLen: 10, TruncateMessage: true}))
+
+	log.Info("short", "blob", `{"id":1,"name":"alice"}`, "status", "ok")
+	out := stripANSI(buf.String())
+	if !strings.Contains(out, `blob="{\"id\":1,\"n"… (+13 bytes) status=ok`) {
+		t.Errorf("Expected truncated value, got: %q", out)
+	}
+
+	// символы UTF-8 не разрезаются
+	buf.Reset()
+	log.Info("short", "name", "привет мир")
+	if out := stripANSI(buf.String()); !strings.Contains(out, "name=приве… (+9 bytes)") {
+		t.Errorf("Expected value cut on rune boundary, got: %q", out)
+	}
+
+	// сообщение обрезается только с TruncateMessage
+	buf.Reset()
+	log.Info("a very long message")
+	if out := stripANSI(buf.String()); !strings.Contains(out, "a very lon… (+9 bytes)") {
+		t.Errorf("Expected truncated message, got: %q", out)
+	}
+
+	buf.Reset()
+	log.InfoContext(Expand(context.Background()), "short", "blob", strings.Repeat("x", 30))
+	if out := stripANSI(buf.String()); !strings.Contains(out, strings.Repeat("x", 30)) || strings.Contains(out, "bytes)") {
+		t.Errorf("Expected full value with Expand, got: %q", out)
+	}
+}
+
+func TestDedupAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, DedupAttrs: true}))
+
+	log.With("user", 1, "env", "dev").Info("login", "user", 2)
+	if out := stripANSI(buf.String()); !strings.Contains(out, "login env=dev user=2 ") || strings.Count(out, "user=") != 1 {
+		t.Errorf("Expected last user attr only, got: %q", out)
+	}
+
+	// одноименные группы объединяются, повторы внутри групп удаляются
+	buf.Reset()
+	log.With(slog.Group("req", "id", "a", "path", "/")).WithGroup("req").Info("done", "id", "b")
+	if out := stripANSI(buf.String()); !strings.Contains(out, "done req.path=/ req.id=b ") || strings.Contains(out, "req.id=a") {
+		t.Errorf("Expected merged req group, got: %q", out)
+	}
+
+	buf.Reset()
+	log.WithGroup("http").With("status", 200).Info("req", "status", 500, "size", 10)
+	if out := stripANSI(buf.String()); !strings.Contains(out, "req http.status=500 http.size=10 ") {
+		t.Errorf("Expected dotted keys without duplicates, got: %q", out)
+	}
+}
+
+func TestErr(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true}))
+
+	log.Error("payment failed", Err(errors.New("card declined")), "order", 7)
+	out := buf.String()
+	if !strings.Contains(out, DefaultTheme().ErrorKey+"error="+DefaultTheme().Faint+`"card declined"`) {
+		t.Errorf("Expected styled error attr, got %q", out)
+	}
+
+	buf.Reset()
+	log.Error("payment failed", Err(nil))
+	if out := stripANSI(buf.String()); !strings.Contains(out, "error=<nil>") {
+		t.Errorf("Expected nil error, got %q", out)
+	}
+
+	var jsonBuf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&jsonBuf, nil)).Error("payment failed", Err(errors.New("card declined")))
+	if !strings.Contains(jsonBuf.String(), `"error":"card declined"`) {
+		t.Errorf("Expected error string in JSON, got %s", jsonBuf.String())
+	}
+
+	// значение атрибута раскрывается errors.Is и errors.As
+	err, _ := Err(fmt.Errorf("load: %w", &os.PathError{Op: "open", Path: "app.yaml", Err: os.ErrNotExist})).Value.Any().(error)
+	var target *os.PathError
+	if !errors.Is(err, os.ErrNotExist) || !errors.As(err, &target) {
+		t.Errorf("Expected Err value to unwrap to the wrapped error, got %v", err)
+	}
+}
+
+// stackError — ошибка со стеком в стиле github.com/pkg/errors
+type stackError struct {
+	msg   string
+	stack []uintptr
+}
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() []uintptr { return e.stack }
+
+func newStackError(msg string) error {
+	pcs := make([]uintptr, 8)
+	return &stackError{msg: msg, stack: pcs[:runtime.Callers(2, pcs)]}
+}
+
+func TestErrorChains(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf}))
+
+	pathErr := &fs.PathError{Op: "open", Path: "app.yaml", Err: fs.ErrNotExist}
+	log.Error("load failed", "err", fmt.Errorf("load config: %w", pathErr), "attempt", 1)
+
+	want := "ERROR load failed err=\"load config: open app.yaml: file does not exist\" attempt=1  " +
+		"\n    ↳ open app.yaml: file does not exist *fs.PathError " +
+		"\n      ↳ file does not exist *errors.errorString\n"
+	if out := stripANSI(buf.String()); !strings.HasSuffix(out, want) {
+		t.Errorf("Expected error chain %q, got %q", want, out)
+	}
+
+	// errors.Join выводит ветви на одном уровне
+	buf.Reset()
+	log.Error("cleanup", "err", errors.Join(errors.New("a"), errors.New("b")))
+	if out := stripANSI(buf.String()); !strings.Contains(out, "\n    ↳ a *errors.errorString \n    ↳ b *errors.errorString") {
+		t.Errorf("Expected joined errors, got %q", out)
+	}
+
+	// без оберток ошибка выводится одной строкой
+	buf.Reset()
+	log.Error("failed", "err", errors.New("boom"))
+	if out := stripANSI(buf.String()); strings.Count(out, "\n") != 1 {
+		t.Errorf("Expected single line, got %q", out)
+	}
+
+	// стек выводится только с ErrorStack
+	buf.Reset()
+	err := fmt.Errorf("handler: %w", newStackError("db down"))
+	log.Error("request", "err", err)
+	if out := stripANSI(buf.String()); strings.Contains(out, "at ") {
+		t.Errorf("Expected no stack without ErrorStack, got %q", out)
+	}
+
+	buf.Reset()
+	slog.New(NewDevHandler(Options{W: &buf, ErrorStack: true})).Error("request", "err", err)
+	if out := stripANSI(buf.String()); !regexp.MustCompile(`\n      at .*TestErrorChains \(\S*color_test\.go:\d+\)`).MatchString(out) {
+		t.Errorf("Expected stack frame of TestErrorChains, got %q", out)
+	}
+}
+
+func TestBadgeDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		850 * time.Nanosecond:                    "850ns",
+		12449 * time.Nanosecond:                  "12.4µs",
+		999600 * time.Nanosecond:                 "1.00ms",
+		1234567 * time.Nanosecond:                "1.23ms",
+		456 * time.Millisecond:                   "456ms",
+		2500 * time.Millisecond:                  "2.50s",
+		59960 * time.Millisecond:                 "1m00s",
+		2*time.Minute + 5*time.Second:            "2m05s",
+		time.Hour + 30*time.Minute + time.Second: "1h30m",
+	} {
+		if got := badgeDuration(d); got != want {
+			t.Errorf("badgeDuration(%v): expected %s, got %s", d, want, got)
+		}
+	}
+
+	// с Align бейдж фиксированной ширины
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, Align: true}))
+	for _, d := range []time.Duration{300 * time.Microsecond, 12 * time.Millisecond} {
+		ctx := context.WithValue(context.WithValue(context.Background(), Sql, "SELECT 1"), Duration, d)
+		log.InfoContext(ctx, "")
+	}
+	out := stripANSI(buf.String())
+	if !strings.Contains(out, "\n[ 300µs] SELECT 1") || !strings.Contains(out, "\n[12.0ms] SELECT 1") {
+		t.Errorf("Expected fixed width badges, got %q", out)
+	}
+}
+
+type orderID struct{ n int }
+
+func (id orderID) String() string { return "ord-" + strconv.Itoa(id.n) }
+
+type orderStatus int
+
+func (s orderStatus) String() string { return "paid" }
+
+func (s orderStatus) MarshalText() ([]byte, error) { return []byte("2"), nil }
+
+func TestStringer(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf}))
+
+	log.Info("order", "id", orderID{42}, "status", orderStatus(2), "ref", (*orderID)(nil))
+	if out := stripANSI(buf.String()); !strings.Contains(out, "id=ord-42 status=2 ref=<nil>") {
+		t.Errorf("Expected Stringer and TextMarshaler values, got %q", out)
+	}
+
+	buf.Reset()
+	log = slog.New(NewDevHandler(Options{W: &buf, PreferStringer: true}))
+	log.Info("order", "status", orderStatus(2))
+	if out := stripANSI(buf.String()); !strings.Contains(out, "status=paid") {
+		t.Errorf("Expected String() with PreferStringer, got %q", out)
+	}
+}
+
+func TestAnyAsJSON(t *testing.T) {
+	type item struct {
+		ID   int      `json:"id"`
+		Tags []string `json:"tags"`
+	}
+
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, AnyAsJSON: true}))
+
+	log.Info("cart", "item", item{ID: 1, Tags: []string{"a<b"}}, "meta", map[string]int{"b": 2, "a": 1}, "ids", []int{1, 2})
+	out := stripANSI(buf.String())
+	if !strings.Contains(out, `item={"id":1,"tags":["a<b"]} meta={"a":1,"b":2} ids=[1 2]`) {
+		t.Errorf("Expected compact JSON values, got %q", out)
+	}
+
+	// значения, которые не кодируются в JSON, выводятся как раньше
+	buf.Reset()
+	log.Info("cart", "ch", struct{ C chan int }{})
+	if out := stripANSI(buf.String()); !strings.Contains(out, "ch={C:<nil>}") {
+		t.Errorf("Expected %%+v fallback, got %q", out)
+	}
+}
+
+func TestRenderRecord(t *testing.T) {
+	r := slog.NewRecord(time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC), slog.LevelWarn, "disk almost full", 0)
+	r.AddAttrs(slog.Int("free_mb", 120))
+
+	ctx := context.WithValue(context.Background(), Sql, "SELECT 1")
+	out, err := RenderRecord(ctx, r, Options{ForceColor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "12:30:00 WARN disk almost full free_mb=120  \nSELECT 1 "
+	if got := stripANSI(out); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if !strings.Contains(out, Reset) {
+		t.Error("Expected colored output")
+	}
+
+	if _, err := RenderRecord(ctx, r, Options{MaxSQLLength: -1}); err == nil {
+		t.Error("Expected validation error")
+	}
+}
+
+func TestLiveTail(t *testing.T) {
+	var next bytes.Buffer
+	tail := NewLiveTail(NewDevHandler(Options{W: &next}), Options{})
+	log := slog.New(tail)
+
+	srv := httptest.NewServer(tail)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?stream=1&level=warn&q=disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	for !tail.hub.active() {
+		time.Sleep(time.Millisecond)
+	}
+
+	log.Error("disk failure", "dev", "sda")
+	log.Info("disk ok")
+	log.Warn("network slow")
+	log.Warn("disk almost full")
+
+	sc := bufio.NewScanner(resp.Body)
+	var events []string
+	for len(events) < 2 && sc.Scan() {
+		if line, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			events = append(events, line)
+		}
+	}
+
+	if len(events) != 2 || !strings.Contains(events[0], "ERROR disk failure dev=sda") || !strings.Contains(events[1], "WARN disk almost full") {
+		t.Errorf("Expected filtered plain events, got: %q", events)
+	}
+	if strings.Contains(events[0], "\x1b[") {
+		t.Error("Plain format must not contain ANSI codes")
+	}
+	if strings.Count(stripANSI(next.String()), "\n") != 4 {
+		t.Errorf("All records must reach next handler, got: %q", next.String())
+	}
+
+	page, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page.Body.Close()
+	if ct := page.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected HTML page, got: %s", ct)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	got := ansiToHTML(Red + "a<b" + Reset + " & " + "\x1b[1;38;2;255;0;128mx" + Reset)
+	want := `<span class="fg-31">a&lt;b</span> &amp; <span class="b" style="color:#ff0080;">x</span>`
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelError, "<script>", 0)
+	out, err := RenderHTML(context.Background(), r, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "<script>") || !strings.Contains(out, "&lt;script&gt;") || strings.Contains(out, "\x1b") {
+		t.Errorf("Expected escaped HTML without ANSI codes, got: %q", out)
+	}
+}
+
+func TestPressure(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var changes []float64
+	h := NewDevHandler(Options{W: io.Discard, SampleRate: 10, OnPressure: func(p float64) {
+		changes = append(changes, p)
+	}}).(*handlerTextColor)
+	h.pre.sampler.now = func() time.Time { return now }
+	log := slog.New(h)
+
+	if p := Pressure(log); p != 0 {
+		t.Errorf("Expected no pressure before logging, got %f", p)
+	}
+
+	for i := 0; i < 200; i++ {
+		log.Info("storm")
+		now = now.Add(10 * time.Millisecond)
+	}
+
+	if p := Pressure(log); p < 0.5 || p > 1 {
+		t.Errorf("Expected high pressure during storm, got %f", p)
+	}
+	if len(changes) == 0 || changes[0] != 1 {
+		t.Errorf("Expected callback on saturation, got %v", changes)
+	}
+
+	if p := Pressure(slog.New(NewDevHandler(Options{W: io.Discard}))); p != 0 {
+		t.Errorf("Expected zero pressure without sampling, got %f", p)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, Strict: true}))
+
+	type secret struct{ token string }
+	log.Info("handled",
+		"done", make(chan struct{}),
+		slog.Group("req", "auth", secret{"x"}),
+		"user", struct{ Name string }{"bob"},
+		"err", errors.New("boom"),
+	)
+
+	out := stripANSI(buf.String())
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 2 warnings and the record, got:\n%s", out)
+	}
+
+	if !strings.Contains(lines[0], "key=done") || !strings.Contains(lines[0], "reason=channel") || !strings.Contains(lines[0], "call_site=") || !strings.Contains(lines[0], "color_test.go:") {
+		t.Errorf("Unexpected channel warning: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "key=req.auth") || !strings.Contains(lines[1], `reason="struct without exported fields"`) {
+		t.Errorf("Unexpected struct warning: %s", lines[1])
+	}
+}
+
+func TestRecordSizeStats(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, SizeStats: true, LargeRecord: 200}))
+
+	for range 3 {
+		log.Info("small")
+	}
+	log.Info("big", "payload", strings.Repeat("x", 300))
+
+	sizes := RecordSizeStats(log)
+	if len(sizes) != 2 {
+		t.Fatalf("Expected 2 call sites, got %+v", sizes)
+	}
+
+	big, small := sizes[0], sizes[1]
+	if big.Count != 1 || big.Bytes < 300 || !strings.Contains(big.Site, "color_test.go:") {
+		t.Errorf("Unexpected big site: %+v", big)
+	}
+	if small.Count != 3 || small.Bytes != 3*int64(small.Max) {
+		t.Errorf("Unexpected small site: %+v", small)
+	}
+	if n := slices.Index(small.Buckets, 3); n < 0 || SizeBuckets[n] < small.Max {
+		t.Errorf("Unexpected buckets: %v", small.Buckets)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stripANSI(buf.String())), "\n")
+	if strings.Contains(lines[0], RecordBytes) || !strings.HasSuffix(lines[3], RecordBytes+"="+strconv.Itoa(big.Max)) {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestBackgroundDetection(t *testing.T) {
+	osc := []struct {
+		resp string
+		bg   Background
+		ok   bool
+	}{
+		{"\u001b]11;rgb:ffff/ffff/ffff\u001b\\", BackgroundLight, true},
+		{"\u001b]11;rgb:1e1e/1e1e/1e1e\a", BackgroundDark, true},
+		{"\u001b]11;rgb:fd/f6/e3\a", BackgroundLight, true},
+		{"\u001b]11;rgb:0/0/0\a", BackgroundDark, true},
+		{"\u001b]11;?\a", BackgroundAuto, false},
+		{"rgb:zz/00/00", BackgroundAuto, false},
+	}
+	for _, tc := range osc {
+		if bg, ok := parseOSC11(tc.resp); bg != tc.bg || ok != tc.ok {
+			t.Errorf("parseOSC11(%q) = %v, %v, want %v, %v", tc.resp, bg, ok, tc.bg, tc.ok)
+		}
+	}
+
+	fgbg := map[string]Background{"15;0": BackgroundDark, "0;15": BackgroundLight, "0;default;7": BackgroundLight, "7;8": BackgroundDark}
+	for s, want := range fgbg {
+		if bg, ok := parseColorFGBG(s); !ok || bg != want {
+			t.Errorf("parseColorFGBG(%q) = %v, want %v", s, bg, want)
+		}
+	}
+
+	// не терминал: без запроса, темная палитра
+	if bg := resolveBackground(BackgroundAuto, &bytes.Buffer{}); bg != BackgroundDark {
+		t.Errorf("Expected dark background for non-terminal writer, got %v", bg)
+	}
+	if bg := resolveBackground(BackgroundQuery, &bytes.Buffer{}); bg != BackgroundDark {
+		t.Errorf("Expected dark background without query for non-terminal writer, got %v", bg)
+	}
+	if err := (Options{W: io.Discard, Background: BackgroundQuery}).Validate(); err != nil {
+		t.Errorf("Expected BackgroundQuery to be valid, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	slog.New(NewDevHandler(Options{W: &buf, Background: BackgroundLight, ForceColor: true})).Warn("light")
+	if strings.Contains(buf.String(), Faint) || !strings.Contains(buf.String(), LightTheme().LevelWarn+"WARN") {
+		t.Errorf("Expected light theme colors, got %q", buf.String())
+	}
+}
+
+func TestNumericSlices(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf}))
+
+	ids := make([]int, 100)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+
+	log.Info("batch",
+		"backoff", []time.Duration{1234567 * time.Nanosecond, 3400 * time.Microsecond, 9 * time.Millisecond, 2500 * time.Millisecond},
+		"ids", ids,
+		"ratios", [2]float64{0.5, 1.25},
+		"raw", []byte("ab"),
+	)
+
+	out := stripANSI(buf.String())
+	for _, want := range []string{
+		"backoff=[1.23ms 3.4ms 9ms 2.5s]",
+		"ids=[1 2 3 4 5 6 7 8 9 10 …+90]",
+		"ratios=[0.5 1.25]",
+		`raw="[97 98]"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output: %s", want, out)
+		}
+	}
+
+	buf.Reset()
+	slog.New(NewDevHandler(Options{W: &buf, SliceItems: 3})).Info("batch", "ids", ids)
+	if out := stripANSI(buf.String()); !strings.Contains(out, "ids=[1 2 3 …+97]") {
+		t.Errorf("Expected capped slice, got: %s", out)
+	}
+}
+
+func TestTheme(t *testing.T) {
+	const bold = "\u001b[1m"
+
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, Theme: &Theme{Message: bold, Key: Blue}, ForceColor: true}))
+	log.Info("hello", "user", "bob")
+
+	out := buf.String()
+	if !strings.Contains(out, bold+"hello"+Reset) || !strings.Contains(out, Blue+"user=") {
+		t.Errorf("Expected overridden colors, got %q", out)
+	}
+	// незаданные поля берутся из палитры по умолчанию
+	if !strings.Contains(out, DefaultTheme().LevelInfo+"INFO") {
+		t.Errorf("Expected default level color, got %q", out)
+	}
+}
+
+func TestPrintColorTest(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	theme := DefaultTheme()
+	theme.LevelWarn = Color256(208).String()
+
+	// буфер не терминал, NO_COLOR и DisableColor заданы — цвета все равно выводятся
+	var buf bytes.Buffer
+	if err := PrintColorTest(Options{W: &buf, Theme: &theme, DisableColor: true}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+
+	for i, want := range []struct{ color, name, msg string }{
+		{theme.LevelDebug, "DEBUG", theme.Message + "debug message" + Reset},
+		{theme.LevelInfo, "INFO", theme.Message + "info message" + Reset},
+		{theme.LevelWarn, "WARN", theme.Message + "warn message" + Reset},
+		{theme.LevelError, "ERROR", theme.ErrorMessage + "error message" + Reset},
+	} {
+		if i >= len(lines) {
+			t.Fatalf("Expected a line for %s, got %q", want.name, buf.String())
+		}
+		if !strings.Contains(lines[i], " "+want.color+want.name+Reset+" ") || !strings.Contains(lines[i], want.msg) {
+			t.Errorf("Expected %s line with its colors, got %q", want.name, lines[i])
+		}
+	}
+	if !strings.Contains(buf.String(), theme.SQL+"SELECT * FROM users") {
+		t.Errorf("Expected colored SQL samples, got %q", buf.String())
+	}
+}
+
+func TestDisableColor(t *testing.T) {
+	logAll := func(opt Options) string {
+		var buf bytes.Buffer
+		opt.W = &buf
+		opt.FirstOccurrence = true
+		opt.DeltaKeys = []string{"n"}
+
+		log := slog.New(NewDevHandler(opt))
+		ctx := context.WithValue(context.Background(), Sql, "SELECT 1")
+		ctx = context.WithValue(ctx, Rows, int64(1))
+		ctx = context.WithValue(ctx, Duration, time.Millisecond)
+		log.InfoContext(ctx, "query", "n", 1)
+		log.Error("failed", "n", 2, "err", logError{errors.New("boom")})
+		return buf.String()
+	}
+
+	if out := logAll(Options{DisableColor: true}); strings.ContainsRune(out, ansiEsc) {
+		t.Errorf("Expected plain output, got %q", out)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if out := logAll(Options{}); strings.ContainsRune(out, ansiEsc) {
+		t.Errorf("Expected plain output with NO_COLOR, got %q", out)
+	}
+	if out := logAll(Options{ForceColor: true}); !strings.ContainsRune(out, ansiEsc) {
+		t.Errorf("Expected colors with ForceColor, got %q", out)
+	}
+
+	if err := (Options{W: io.Discard, ForceColor: true, DisableColor: true}).Validate(); err == nil {
+		t.Error("Expected conflicting color options to fail validation")
+	}
+}
+
+func TestColorTTYDetection(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "dev.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	slog.New(NewDevHandler(Options{W: f})).Info("to file", "n", 1)
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "to file") || bytes.ContainsRune(data, ansiEsc) {
+		t.Errorf("Expected plain output in a regular file, got %q", data)
+	}
+
+	var buf bytes.Buffer
+	slog.New(NewDevHandler(Options{W: &buf, ForceColor: true})).Info("forced")
+	if !strings.ContainsRune(buf.String(), ansiEsc) {
+		t.Errorf("Expected colors with ForceColor, got %q", buf.String())
+	}
+
+	out, err := RenderRecord(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "rendered", 0), Options{})
+	if err != nil || !strings.ContainsRune(out, ansiEsc) {
+		t.Errorf("RenderRecord must keep colors regardless of W, got %q, %v", out, err)
+	}
+}
+
+func TestRichColors(t *testing.T) {
+	orange, kw := RGB(255, 128, 0).String(), Color256(33).String()
+	if orange != "\u001b[38;2;255;128;0m" || kw != "\u001b[38;5;33m" || (Style{Bg: Color256(100)}).String() != "\u001b[48;5;100m" {
+		t.Fatal("Unexpected color codes")
+	}
+
+	theme := &Theme{LevelInfo: orange, SQLKeyword: kw}
+
+	logSQL := func() string {
+		var buf bytes.Buffer
+		log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, Theme: theme}))
+		ctx := context.WithValue(context.Background(), Sql, "select * from users where name = 'from'")
+		log.InfoContext(ctx, "")
+		return buf.String()
+	}
+
+	t.Setenv("COLORTERM", "truecolor")
+	out := logSQL()
+	if !strings.Contains(out, orange+"INFO") {
+		t.Errorf("Expected truecolor level, got %q", out)
+	}
+	if !strings.Contains(out, kw+"where"+Reset) || strings.Contains(out, kw+"from"+Reset+"'") {
+		t.Errorf("Expected highlighted keywords outside literals, got %q", out)
+	}
+	if !strings.Contains(stripANSI(out), "select * from users where name = 'from'") {
+		t.Errorf("Keyword highlighting changed SQL text: %q", stripANSI(out))
+	}
+
+	t.Setenv("COLORTERM", "")
+	if out := logSQL(); !strings.Contains(out, Color256(208).String()+"INFO") {
+		t.Errorf("Expected truecolor downgraded to 256 colors, got %q", out)
+	}
+}
+
+func TestKeyColors(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, KeyColors: []KeyColor{
+		{Pattern: "trace_*", Color: Magenta},
+		{Pattern: "user_id", Color: Cyan},
+		{Pattern: "http.status", Color: Yellow},
+	}}))
+
+	log.Info("msg", "user_id", 7, "trace_id", "abc", "other", 1,
+		slog.Group("http", slog.Int("status", 200)), slog.Group("req", slog.Int("user_id", 8)))
+
+	out := buf.String()
+	for _, want := range []string{
+		Cyan + "user_id=7" + Reset,
+		Magenta + "trace_id=abc" + Reset,
+		Yellow + "http.status=200" + Reset,
+		Cyan + "req.user_id=8" + Reset,
+		Faint + "other=" + Reset,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestThemeLightPreset(t *testing.T) {
+	var buf bytes.Buffer
+	light := LightTheme()
+	log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, Theme: &light}))
+	log.Info("msg", "user", "bob")
+	log.Warn("careful")
+
+	out := buf.String()
+	if strings.Contains(out, Faint) || strings.Contains(out, BrightYellow) {
+		t.Errorf("Light theme must not use Faint or bright yellow, got %q", out)
+	}
+	if !strings.Contains(out, light.LevelInfo+"INFO") || !strings.Contains(out, light.Key+"user=") {
+		t.Errorf("Expected light theme colors, got %q", out)
+	}
+}
+
+func TestHueBadge(t *testing.T) {
+	if hueColor("acme") != hueColor("acme") {
+		t.Error("Expected stable color for the same value")
+	}
+
+	// разные арендаторы должны получать разные цвета хотя бы в большинстве случаев
+	colors := map[uint8]bool{}
+	for _, v := range []string{"acme", "globex", "initech", "umbrella", "hooli", "wayne"} {
+		colors[hueColor(v)] = true
+	}
+	if len(colors) < 3 {
+		t.Errorf("Expected distinct colors, got %v", colors)
+	}
+
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, HueKey: "tenant"}))
+	log.InfoContext(context.WithValue(context.Background(), "tenant", "acme"), "msg")
+	log.InfoContext(PushAttrs(context.Background(), "tenant", "acme"), "msg")
+	log.Info("no tenant")
+
+	want := "\u001b[30;48;5;" + strconv.Itoa(int(hueColor("acme"))) + "m acme " + Reset
+	if n := strings.Count(buf.String(), want); n != 2 {
+		t.Errorf("Expected 2 badges %q, got %d in %q", want, n, buf.String())
+	}
+
+	buf.Reset()
+	log = slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, HueKey: "tenant"}))
+	log.InfoContext(context.WithValue(context.Background(), "tenant", "a-very-long-tenant"), "msg")
+	if !strings.Contains(buf.String(), "[a-very-lo…] ") {
+		t.Errorf("Expected plain truncated badge, got %q", buf.String())
+	}
+}
+
+func TestLevelBadges(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, LevelBadges: true}))
+	log.Info("a")
+	log.Error("b")
+
+	theme := DefaultTheme()
+	out := buf.String()
+	for _, want := range []string{theme.LevelInfoBadge + " INFO " + Reset, theme.LevelErrorBadge + " ERRO " + Reset} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in %q", want, out)
+		}
+	}
+
+	// без цветов уровни выравниваются по ширине
+	buf.Reset()
+	log = slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, LevelWidth: 5}))
+	log.Info("a")
+	log.Warn("b")
+	log.Log(context.Background(), slog.LevelError+2, "c")
+
+	// ширина в колонках, а не в байтах: кириллица и широкие символы
+	RegisterLevel(slog.LevelError+5, "ОШИБКА", "")
+	RegisterLevel(slog.LevelError+6, "警告", "")
+	log.Log(context.Background(), slog.LevelError+5, "d")
+	log.Log(context.Background(), slog.LevelError+6, "e")
+
+	lines := strings.Split(strings.TrimSpace(stripANSI(buf.String())), "\n")
+	for i, want := range []string{"INFO  a", "WARN  b", "ERROR c", "ОШИБК d", "警告  e"} {
+		if i >= len(lines) || !strings.Contains(lines[i], want) {
+			t.Errorf("Expected %q in line %d of %q", want, i, buf.String())
+		}
+	}
+}
+
+type countingExtractor struct{ calls int }
+
+func (e *countingExtractor) Extract(ctx context.Context) (slog.Attr, bool) {
+	e.calls++
+	return slog.Int("user_id", 7), true
+}
+
+func TestPrefetch(t *testing.T) {
+	var buf bytes.Buffer
+	ext := &countingExtractor{}
+	log := slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, AddCxtAttr: []string{"request_id"}, CtxExtractors: []CtxExtractor{ext}}))
+
+	ctx := Prefetch(context.WithValue(context.Background(), "request_id", "r1"))
+	log.InfoContext(ctx, "a")
+	log.With("k", 1).InfoContext(ctx, "b")
+
+	if ext.calls != 1 {
+		t.Errorf("Expected extractor to run once per request, got %d", ext.calls)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", buf.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "request_id=r1") || !strings.Contains(line, "user_id=7") {
+			t.Errorf("Expected prefetched context values in %q", line)
+		}
+	}
+
+	// без Prefetch значения отрисовываются для каждой записи
+	log.InfoContext(context.Background(), "c")
+	if ext.calls != 2 {
+		t.Errorf("Expected extractor to run without prefetch, got %d calls", ext.calls)
+	}
+}
+
+func TestLevelIcons(t *testing.T) {
+	r := slog.NewRecord(time.Time{}, slog.LevelWarn, "careful", 0)
+
+	out, err := RenderRecord(context.Background(), r, Options{DisableColor: true, Icons: IconsPrefix})
+	if err != nil || !strings.HasPrefix(out, "⚠ WARN careful") {
+		t.Errorf("Expected icon before level, got %q (%v)", out, err)
+	}
+
+	out, _ = RenderRecord(context.Background(), r, Options{DisableColor: true, Icons: IconsOnly})
+	if !strings.HasPrefix(out, "⚠ careful") {
+		t.Errorf("Expected icon instead of level, got %q", out)
+	}
+
+	// буфер не терминал: символы заменяются текстом
+	var buf bytes.Buffer
+	slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, Icons: IconsOnly})).Warn("careful")
+	if strings.Contains(buf.String(), "⚠") || !strings.Contains(buf.String(), "WARN careful") {
+		t.Errorf("Expected text level for non-terminal writer, got %q", buf.String())
+	}
+}
+
+func TestDevHandlerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewDevHandler(Options{W: &buf, DisableColor: true, Level: slog.LevelInfo})
+	log := slog.New(h)
+
+	if h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Expected Debug to be disabled at Info")
+	}
+
+	log.Debug("hidden")
+	log.Info("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("Expected only Info record, got %q", buf.String())
+	}
+
+	// понижение уровня правилом тоже отбрасывает запись
+	buf.Reset()
+	log = slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, Level: slog.LevelInfo,
+		LevelRules: []LevelRule{{Message: regexp.MustCompile("noisy"), Level: slog.LevelDebug}}}))
+	log.Info("noisy")
+	if buf.Len() != 0 {
+		t.Errorf("Expected demoted record to be dropped, got %q", buf.String())
+	}
+}
+
+func TestStyle(t *testing.T) {
+	for _, c := range []struct {
+		style Style
+		want  string
+	}{
+		{Style{}, ""},
+		{Style{Fg: ColorRed}, Red},
+		{Style{Fg: ColorBrightBlack}, Faint},
+		{Style{Bg: ColorYellow}, YellowBack},
+		{Style{Fg: ColorRed, Bg: ColorYellow, Bold: true}, "\u001b[1;31;43m"},
+		{Style{Fg: Color256(208), Underline: true, Faint: true}, "\u001b[2;4;38;5;208m"},
+		{Style{Bg: RGB(1, 2, 3)}, "\u001b[48;2;1;2;3m"},
+		{Style{Fg: ColorBrightWhite, Bg: Color256(9)}, "\u001b[97;101m"},
+	} {
+		if got := c.style.String(); got != c.want {
+			t.Errorf("%+v: expected %q, got %q", c.style, c.want, got)
+		}
+	}
+
+	if got := string(Style{Bold: true}.AppendANSI([]byte("x"))); got != "x\u001b[1m" {
+		t.Errorf("AppendANSI: got %q", got)
+	}
+}
+
+func TestRegisterLevel(t *testing.T) {
+	const (
+		levelTrace = slog.Level(-8)
+		levelFatal = slog.Level(12)
+	)
+	fatal := Style{Fg: ColorBrightWhite, Bg: ColorRed, Bold: true}.String()
+	RegisterLevel(levelTrace, "TRACE", "")
+	RegisterLevel(levelFatal, "FATAL", fatal)
+
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, Level: levelTrace}))
+	log.Log(context.Background(), levelTrace, "t")
+	log.Log(context.Background(), levelFatal, "f")
+
+	out := buf.String()
+	if !strings.Contains(out, DefaultTheme().LevelDebug+"TRACE"+Reset) || !strings.Contains(out, fatal+"FATAL"+Reset) {
+		t.Errorf("Expected registered level names and colors, got %q", out)
+	}
+
+	buf.Reset()
+	jsonLog, _ := NewLogger(Options{W: &buf})
+	jsonLog.Log(context.Background(), levelFatal, "f")
+	if !strings.Contains(buf.String(), `"level":"FATAL"`) {
+		t.Errorf("Expected registered level name in JSON, got %q", buf.String())
+	}
+	if LevelName(slog.LevelError+2) != "ERROR+2" {
+		t.Errorf("Expected unregistered level name, got %q", LevelName(slog.LevelError+2))
+	}
+
+	schema, _ := JSONSchema(Options{})
+	if !strings.Contains(string(schema), "FATAL") {
+		t.Errorf("Expected registered level in JSON schema level pattern")
+	}
+}
+
+func TestSpan(t *testing.T) {
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, Source: true, SlowThreshold: time.Nanosecond})))
+
+	ctx, end := Span(context.Background(), "import")
+	_, endParse := Span(ctx, "parse")
+	endParse(nil)
+	end(errors.New("boom"))
+
+	lines := strings.Split(strings.TrimSpace(stripANSI(buf.String())), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %q", buf.String())
+	}
+	for i, want := range []string{"DEBUG", "start import", "span=import"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("Expected %q (%d) in %q", want, i, lines[0])
+		}
+	}
+	if !strings.Contains(lines[2], "INFO") || !strings.Contains(lines[2], "end import/parse") {
+		t.Errorf("Expected nested span end at Info, got %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "ERROR") || !strings.Contains(lines[3], "boom") {
+		t.Errorf("Expected failed span end at Error, got %q", lines[3])
+	}
+	if !strings.Contains(lines[0], "color_test.go") {
+		t.Errorf("Expected Span call site as source, got %q", lines[0])
+	}
+	if !strings.Contains(buf.String(), "duration="+Reset+DefaultTheme().SlowDuration) {
+		t.Errorf("Expected slow duration coloring, got %q", buf.String())
+	}
+}
+
+func TestSourcePathMode(t *testing.T) {
+	_, self, _, _ := runtime.Caller(0)
+
+	for _, c := range []struct {
+		mode SourcePathMode
+		want string
+	}{
+		{SourcePathTrimmed, filepath.Base(filepath.Dir(self)) + "/color_test.go:"},
+		{SourcePathFull, self + ":"},
+		{SourcePathFileOnly, " color_test.go:"},
+	} {
+		var buf bytes.Buffer
+		slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, Source: true, SourcePath: c.mode})).Info("msg")
+		if !strings.Contains(buf.String(), c.want) {
+			t.Errorf("Mode %d: expected %q in %q", c.mode, c.want, buf.String())
+		}
+	}
+}
+
+func TestConcurrentGroups(t *testing.T) {
+	// цвет ключа выбирается по полному пути групп: чужие группы дают чужой цвет
+	colors := map[string]string{
+		"req.a.b.x.": Style{Fg: ColorRed}.String(),
+		"req.a.b.y.": Style{Fg: ColorGreen}.String(),
+		"req.c.":     Style{Fg: ColorBlue}.String(),
+	}
+	var rules []KeyColor
+	for prefix, color := range colors {
+		rules = append(rules, KeyColor{Pattern: prefix + "g.i", Color: color})
+	}
+
+	var buf bytes.Buffer
+	base := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, KeyColors: rules})).WithGroup("req")
+
+	// у "req.a.b" в массиве groups есть свободное место: без обрезки
+	// соседние WithGroup записывали бы имена в общий массив
+	parent := base.WithGroup("a").WithGroup("b").With("id", 1)
+	loggers := map[string]*slog.Logger{
+		"req.a.b.x.": parent.WithGroup("x"),
+		"req.a.b.y.": parent.WithGroup("y"),
+		"req.c.":     base.WithGroup("c"),
+	}
+
+	var wg sync.WaitGroup
+	for prefix, l := range loggers {
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range 50 {
+					l.Info("msg", "prefix", prefix, slog.Group("g", "i", i))
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3*20*50 {
+		t.Fatalf("Expected %d lines, got %d", 3*20*50, len(lines))
+	}
+
+	re := regexp.MustCompile(` (\S+)prefix=(\S+) (\S+)g\.i=`)
+	for _, line := range lines {
+		m := re.FindStringSubmatch(stripANSI(line))
+		if m == nil || m[1] != m[2] || m[3] != m[2] {
+			t.Fatalf("Group prefixes mixed up in %q", line)
+		}
+		if want := colors[m[2]] + m[2] + "g.i="; !strings.Contains(line, want) {
+			t.Fatalf("Expected %q in %q", want, line)
+		}
+	}
+}
+
+func TestMsgf(t *testing.T) {
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, Source: true})))
+
+	Infof(context.Background(), "user %{user}d logged in from %s, 100%% %.1f", 42, "10.0.0.1", 0.5, "attempt", 2)
+	want := "user 42 logged in from 10.0.0.1, 100% 0.5 attempt=2"
+	if got := buf.String(); !strings.Contains(got, want) || strings.Contains(got, "user=") || strings.Contains(got, "arg1=") {
+		t.Errorf("Expected %q without placeholder attrs, got %q", want, got)
+	}
+	if !strings.Contains(buf.String(), "color_test.go") {
+		t.Errorf("Expected Infof call site as source, got %q", buf.String())
+	}
+
+	// в JSON шаблон остается сообщением, значения — атрибутами с исходным типом
+	buf.Reset()
+	slog.SetDefault(slog.New(NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), Options{})))
+	Warnf(context.Background(), "user %{user}d logged in from %s", 42, "10.0.0.1")
+	for _, want := range []string{`"msg":"user %{user}d logged in from %s"`, `"user":42`, `"arg1":"10.0.0.1"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %s in %s", want, buf.String())
+		}
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{
+		W: &buf, DisableColor: true, Multiline: true, AddCxtAttr: []string{"request_id"}, GroupCompactThreshold: 2,
+		Clock: func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) },
+	})).With("service", "api")
+
+	ctx := context.WithValue(context.Background(), "request_id", "r1")
+	log.InfoContext(ctx, "msg", "a", 1, slog.Group("g", "b", 2), slog.Group("h", "x", 1, "y", 2))
+
+	want := "12:00:00 INFO msg\n" +
+		"    a=1\n" +
+		"    g.b=2\n" +
+		"    h{x=1 y=2}\n" +
+		"    request_id=r1\n" +
+		"    service=api\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestDetectColorSupport(t *testing.T) {
+	for _, env := range []string{"NO_COLOR", "COLORTERM", "TERM_PROGRAM", "GITHUB_ACTIONS", "GITEA_ACTIONS", "GITLAB_CI", "BUILDKITE", "CIRCLECI", "TRAVIS", "DRONE"} {
+		t.Setenv(env, "")
+	}
+
+	if got := DetectColorSupport(&bytes.Buffer{}); got != ColorLevelNone {
+		t.Errorf("Expected none for buffer, got %s", got)
+	}
+
+	// stdout теста — не терминал: цвета только в CI
+	if got := DetectColorSupport(os.Stdout); got != ColorLevelNone {
+		t.Errorf("Expected none for piped stdout, got %s", got)
+	}
+	t.Setenv("GITLAB_CI", "true")
+	if got := DetectColorSupport(os.Stdout); got != ColorLevelBasic {
+		t.Errorf("Expected basic in GitLab CI, got %s", got)
+	}
+	t.Setenv("NO_COLOR", "1")
+	if got := DetectColorSupport(os.Stdout); got != ColorLevelNone {
+		t.Errorf("Expected none with NO_COLOR, got %s", got)
+	}
+
+	for _, c := range []struct {
+		term, colorterm string
+		want            ColorLevel
+	}{
+		{"xterm", "", ColorLevelBasic},
+		{"xterm-256color", "", ColorLevel256},
+		{"xterm-256color", "truecolor", ColorLevelTrueColor},
+		{"xterm-direct", "", ColorLevelTrueColor},
+		{"dumb", "", ColorLevelNone},
+	} {
+		t.Setenv("TERM", c.term)
+		t.Setenv("COLORTERM", c.colorterm)
+		if got := termColorLevel(); got != c.want {
+			t.Errorf("TERM=%s COLORTERM=%s: expected %s, got %s", c.term, c.colorterm, c.want, got)
+		}
+	}
+}
+
+func TestGroupStyle(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+	for _, c := range []struct {
+		style GroupStyle
+		want  string
+	}{
+		{GroupDots, "12:00:00 INFO msg req.a=1 req.user.id=7 req.user.name=bob  req.svc=api \n"},
+		{GroupBrackets, "12:00:00 INFO msg req={a=1 user={id=7 name=bob}}  req={svc=api} \n"},
+		{GroupIndent, "12:00:00 INFO msg\n" +
+			"    req:\n" +
+			"        a=1\n" +
+			"        user:\n" +
+			"            id=7\n" +
+			"            name=bob\n" +
+			"    req:\n" +
+			"        svc=api\n"},
+	} {
+		var buf bytes.Buffer
+		log := slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, GroupStyle: c.style, Clock: clock}))
+		log.WithGroup("req").With("svc", "api").Info("msg", "a", 1, slog.Group("user", "id", 7, "name", "bob"), slog.Group("empty"))
+
+		if buf.String() != c.want {
+			t.Errorf("Style %d: expected\n%q\ngot\n%q", c.style, c.want, buf.String())
+		}
+	}
+}
+
+func TestAlign(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, ForceColor: true, Source: true, Align: true, SourceWidth: 24}))
+
+	log.Info("first")
+	log.Error("second")
+	ctx := context.WithValue(context.Background(), Source, slog.Source{Function: "pkg.VeryLongFunctionName", File: "/src/app/repository/user_repository.go", Line: 1200})
+	log.WarnContext(ctx, "third")
+
+	lines := strings.Split(strings.TrimSpace(stripANSI(buf.String())), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", buf.String())
+	}
+
+	// сообщения начинаются с одной колонки, несмотря на цвета и длину уровня и источника
+	col := strings.Index(lines[0], "first")
+	for i, msg := range []string{"first", "second", "third"} {
+		if got := strings.Index(lines[i], msg); got != col {
+			t.Errorf("Expected %q at column %d, got %d in %q", msg, col, got, lines[i])
+		}
+	}
+	if !strings.Contains(lines[2], "repository/user_reposit…") {
+		t.Errorf("Expected truncated long source, got %q", lines[2])
+	}
+	// цвет обрезанного источника сбрасывается перед следующей колонкой
+	if !strings.Contains(buf.String(), "user_reposit…"+Reset) {
+		t.Errorf("Expected reset after truncated source, got %q", buf.String())
+	}
+
+	// уровень, зарегистрированный после создания обработчика, не обрезается
+	prev := levels.Load()
+	defer levels.Store(prev)
+	RegisterLevel(slog.Level(16), "CRITICAL", "")
+
+	buf.Reset()
+	log.Log(context.Background(), slog.Level(16), "fourth")
+	log.Info("fifth")
+
+	lines = strings.Split(strings.TrimSpace(stripANSI(buf.String())), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "CRITICAL ") || strings.Index(lines[0], "fourth") != strings.Index(lines[1], "fifth") {
+		t.Errorf("Expected late registered level in full and aligned, got %q", lines)
+	}
+}
+
+func TestTimeDelta(t *testing.T) {
+	steps := []time.Duration{0, 12345678 * time.Nanosecond, 1500 * time.Millisecond}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		mode TimeMode
+		want string
+	}{
+		{TimeDelta, "     +0s INFO a \n" + " +12.3ms INFO b \n" + "   +1.5s INFO c \n"},
+		{TimeClockDelta, "12:00:00      +0s INFO a \n" + "12:00:00  +12.3ms INFO b \n" + "12:00:01    +1.5s INFO c \n"},
+	} {
+		i, clock := 0, now
+		var buf bytes.Buffer
+		log := slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, TimeMode: c.mode, Clock: func() time.Time {
+			clock = clock.Add(steps[i])
+			i++
+			return clock
+		}}))
+
+		// время предыдущей записи общее для производных логеров
+		log.Info("a")
+		log.With().WithGroup("g").Info("b")
+		log.Info("c")
+
+		if buf.String() != c.want {
+			t.Errorf("Mode %d: expected\n%q\ngot\n%q", c.mode, c.want, buf.String())
+		}
+	}
+}
+
+func TestTimeLocation(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+	loc := time.FixedZone("MSK", 3*60*60)
+
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, Clock: clock, TimeLocation: loc}))
+	log.Info("a")
+
+	if want := "15:00:00 INFO a \n"; buf.String() != want {
+		t.Errorf("Expected time in TimeLocation %q, got %q", want, buf.String())
+	}
+
+	// без TimeLocation время выводится в поясе записи
+	buf.Reset()
+	log = slog.New(NewDevHandler(Options{W: &buf, DisableColor: true, Clock: clock}))
+	log.Info("a")
+
+	if want := "12:00:00 INFO a \n"; buf.String() != want {
+		t.Errorf("Expected record time %q, got %q", want, buf.String())
+	}
+}
+
+func TestKeyboardControl(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewDevHandler(Options{W: &buf, DisableColor: true, KeyboardControl: true}).(*handlerTextColor)
+	// конструктор только готовит переключатели, stdin не читается
+	if h.focus == nil || h.focus.stop != nil {
+		t.Fatal("Expected keyboard switches without reading stdin")
+	}
+	if _, err := StartKeyboard(h); !errors.Is(err, ErrKeyboardNoTTY) {
+		t.Errorf("Expected ErrKeyboardNoTTY for non-terminal writer, got %v", err)
+	}
+	if _, err := StartKeyboard(NewDevHandler(Options{W: &buf})); !errors.Is(err, ErrKeyboardDisabled) {
+		t.Errorf("Expected ErrKeyboardDisabled without Options.KeyboardControl, got %v", err)
+	}
+	log := slog.New(h).With("svc", "api")
+
+	h.handleKey('w')
+	log.Info("hidden info")
+	log.Warn("shown warn")
+
+	h.handleKey('s')
+	sqlCtx := context.WithValue(context.Background(), Sql, "SELECT 1")
+	log.WarnContext(sqlCtx, "hidden sql")
+	h.handleKey('s')
+	log.WarnContext(sqlCtx, "shown sql")
+
+	h.handleKey('p')
+	log.Error("held error")
+	if strings.Contains(buf.String(), "held error") {
+		t.Errorf("Expected output held while paused, got %q", buf.String())
+	}
+	h.handleKey('p')
+
+	out := buf.String()
+	for _, want := range []string{"[keys] level WARN", "shown warn", "[keys] sql hidden", "shown sql", "[keys] paused", "held error", "[keys] resumed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in %q", want, out)
+		}
+	}
+	for _, unwanted := range []string{"hidden info", "hidden sql"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Unexpected %q in %q", unwanted, out)
+		}
+	}
+
+	// stdin не терминал: режим не меняется, клавиши не читаются
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if stop, ok := readKeys(r, func(byte) {}); ok || stop != nil {
+		t.Error("Expected no keyboard control for non-terminal stdin")
+	}
+}
+
+func TestPager(t *testing.T) {
+	// не терминал: вывод копируется в out при Close, затем пишется напрямую
+	var buf bytes.Buffer
+	p := NewPager(&buf)
+	slog.New(NewDevHandler(Options{W: p})).Info("paged")
+	if buf.Len() != 0 {
+		t.Errorf("Expected output buffered until Close, got %q", buf.String())
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p.Write([]byte("direct\n"))
+	if out := stripANSI(buf.String()); !strings.Contains(out, "paged") || !strings.HasSuffix(out, "direct\n") {
+		t.Errorf("Expected passthrough for non-terminal writer, got %q", out)
+	}
+
+	t.Setenv("PAGER", "")
+	if cmd := pagerCommand(); !slices.Equal(cmd, []string{"less", "-R"}) {
+		t.Errorf("Expected less -R by default, got %v", cmd)
+	}
+	t.Setenv("PAGER", "more -s")
+	if cmd := pagerCommand(); !slices.Equal(cmd, []string{"more", "-s"}) {
+		t.Errorf("Expected command from $PAGER, got %v", cmd)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	// /dev/null — символьное устройство, как терминал
+	tty, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer tty.Close()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "pager")
+	got := filepath.Join(dir, "got")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n{ echo \"LESS=$LESS\"; cat; } > "+got+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PAGER", script)
+	t.Setenv("LESS", "")
+	p = NewPager(tty)
+	p.Write([]byte("through pager\n"))
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(got); string(data) != "LESS=FRX\nthrough pager\n" {
+		t.Errorf("Expected output through $PAGER with LESS=FRX, got %q", data)
+	}
+
+	// пейджер не запустился: вывод копируется в out без ошибки
+	t.Setenv("PAGER", filepath.Join(dir, "missing"))
+	p = NewPager(tty)
+	p.Write([]byte("fallback\n"))
+	if err := p.Close(); err != nil {
+		t.Errorf("Expected fallback write to out, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"io"
+	"os"
+)
+
+// colorizer — палитра обработчика. Выключенный colorizer содержит пустые
+// коды, и все append* методы выводят текст без ANSI-последовательностей.
+type colorizer struct {
+	Theme
+	keys  *keyColors
+	reset string
+}
+
+func newColorizer(opt Options) colorizer {
+	if !colorEnabled(opt) {
+		return colorizer{}
+	}
+
+	mapCode := func(code string) string { return code }
+	if !opt.assumeTerminal && colorLevel(opt) < ColorLevelTrueColor {
+		mapCode = downgradeRGB
+	}
+
+	return colorizer{
+		Theme: resolveTheme(opt).mapCodes(mapCode),
+		keys:  newKeyColors(opt.KeyColors, mapCode),
+		reset: Reset,
+	}
+}
+
+// colorLevel — набор цветов W. При ForceColor вывод в не терминал
+// оценивается по переменным окружения терминала
+func colorLevel(opt Options) ColorLevel {
+	if level := DetectColorSupport(opt.W); level > ColorLevelNone || !opt.ForceColor {
+		return level
+	}
+	return max(termColorLevel(), ColorLevelBasic)
+}
+
+// colorEnabled: ForceColor важнее DisableColor, переменной NO_COLOR
+// (https://no-color.org, учитывается при любом непустом значении)
+// и DetectColorSupport: W — терминал, понимающий ANSI-последовательности,
+// или stdout в CI с цветными логами сборки
+func colorEnabled(opt Options) bool {
+	switch {
+	case opt.ForceColor:
+		// в консоли Windows цвета включаются, если это возможно
+		if writerIsTerminal(opt.W) {
+			enableVirtualTerminal(opt.W)
+		}
+		return true
+	case opt.DisableColor, os.Getenv("NO_COLOR") != "":
+		return false
+	case opt.assumeTerminal:
+		return true
+	}
+
+	return DetectColorSupport(opt.W) > ColorLevelNone
+}
+
+// writerIsTerminal сообщает, что w — терминал. Writer без метода Fd
+// (буфер, сетевое соединение, файл за оберткой) терминалом не считается.
+func writerIsTerminal(w io.Writer) bool {
+	f, ok := w.(interface{ Fd() uintptr })
+	return ok && isTerminal(f.Fd())
+}
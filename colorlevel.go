@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ColorLevel — набор цветов, который понимает вывод
+type ColorLevel int
+
+const (
+	// Без цветов: не терминал, NO_COLOR, TERM=dumb
+	ColorLevelNone ColorLevel = iota
+	// 16 цветов
+	ColorLevelBasic
+	// Палитра 256 цветов
+	ColorLevel256
+	// 24-битные цвета
+	ColorLevelTrueColor
+)
+
+func (l ColorLevel) String() string {
+	switch l {
+	case ColorLevelBasic:
+		return "basic"
+	case ColorLevel256:
+		return "256"
+	case ColorLevelTrueColor:
+		return "truecolor"
+	}
+	return "none"
+}
+
+// CI, которые показывают ANSI-цвета в логах сборки, хотя вывод — не терминал
+var ciColorLevels = []struct {
+	env   string
+	level ColorLevel
+}{
+	{"GITHUB_ACTIONS", ColorLevelTrueColor},
+	{"GITEA_ACTIONS", ColorLevelTrueColor},
+	{"GITLAB_CI", ColorLevelBasic},
+	{"BUILDKITE", ColorLevelBasic},
+	{"CIRCLECI", ColorLevelBasic},
+	{"TRAVIS", ColorLevelBasic},
+	{"DRONE", ColorLevelBasic},
+}
+
+// DetectColorSupport определяет набор цветов для вывода в w по NO_COLOR,
+// TERM, COLORTERM, TERM_PROGRAM, режиму консоли Windows (включает в ней
+// обработку ANSI-последовательностей) и переменным окружения CI. Его
+// использует dev обработчик; приложения могут выводить по нему свои
+// цветные элементы рядом с логом:
+//
+//	if logger.DetectColorSupport(os.Stdout) >= logger.ColorLevel256 {
+//		fmt.Print(logger.Color256(208), "progress", logger.Reset)
+//	}
+//
+// Writer без метода Fd (буфер, обертка над файлом) — ColorLevelNone,
+// переменные CI учитываются только для os.Stdout и os.Stderr.
+func DetectColorSupport(w io.Writer) ColorLevel {
+	if os.Getenv("NO_COLOR") != "" {
+		return ColorLevelNone
+	}
+
+	if !writerIsTerminal(w) {
+		if isStdStream(w) {
+			return ciColorLevel()
+		}
+		return ColorLevelNone
+	}
+
+	if !enableVirtualTerminal(w) {
+		return ColorLevelNone
+	}
+
+	return termColorLevel()
+}
+
+// termColorLevel определяет набор цветов терминала по переменным окружения
+func termColorLevel() ColorLevel {
+	if truecolorSupported() {
+		return ColorLevelTrueColor
+	}
+
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode":
+		return ColorLevelTrueColor
+	case "Apple_Terminal":
+		return ColorLevel256
+	}
+
+	term := strings.ToLower(os.Getenv("TERM"))
+	switch {
+	case term == "dumb":
+		return ColorLevelNone
+	case strings.HasSuffix(term, "-direct") || strings.Contains(term, "truecolor"):
+		return ColorLevelTrueColor
+	case strings.Contains(term, "256color"):
+		return ColorLevel256
+	case term == "" && runtime.GOOS == "windows":
+		// консоль Windows 10 с включенной обработкой ANSI понимает 24-битные цвета
+		return ColorLevelTrueColor
+	}
+
+	return ColorLevelBasic
+}
+
+// ciColorLevel — набор цветов лога сборки известной CI
+func ciColorLevel() ColorLevel {
+	for _, ci := range ciColorLevels {
+		if os.Getenv(ci.env) != "" {
+			return ci.level
+		}
+	}
+	return ColorLevelNone
+}
+
+// isStdStream сообщает, что w — stdout или stderr процесса
+func isStdStream(w io.Writer) bool {
+	return w == os.Stdout || w == os.Stderr
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// PrintColorTest выводит в Options.W образцы всех уровней, быстрый и
+// медленный SQL, атрибуты и ошибку с темой и остальными настройками opt.
+// Нужен для быстрой проверки темы и поддержки цвета терминалом, поэтому
+// цвета выводятся всегда, даже в файл или пайп.
+//
+//	logger.PrintColorTest(logger.Options{W: os.Stdout, Theme: &theme, Source: true})
+func PrintColorTest(opt Options) error {
+	opt.ForceColor = true
+	opt.DisableColor = false
+	// образцы не должны попадать в отчеты
+	opt.Digest = nil
+	opt.ApplyDefaults()
+	if err := opt.Validate(); err != nil {
+		return err
+	}
+	h := NewDevHandler(opt)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	records := []slog.Record{
+		slog.NewRecord(now, slog.LevelDebug, "debug message", pcs[0]),
+		slog.NewRecord(now, slog.LevelInfo, "info message", pcs[0]),
+		slog.NewRecord(now, slog.LevelWarn, "warn message", pcs[0]),
+		slog.NewRecord(now, slog.LevelError, "error message", pcs[0]),
+	}
+
+	records[1].AddAttrs(
+		slog.String("user", "john"),
+		slog.Int("count", 42),
+		slog.Float64("ratio", 0.75),
+		slog.Bool("ok", true),
+		slog.Duration("elapsed", 1500*time.Millisecond),
+		slog.Group("http", slog.String("method", "GET"), slog.Int("status", 200)),
+	)
+	records[3].AddAttrs(Err(errors.New("connection refused")))
+
+	for _, r := range records {
+		if err := h.Handle(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	queries := []struct {
+		sql      string
+		rows     int64
+		duration time.Duration
+		level    slog.Level
+	}{
+		{"SELECT * FROM users WHERE id = 1", 1, 3 * time.Millisecond, slog.LevelInfo},
+		{"SELECT * FROM orders WHERE created_at > '2024-01-01'", 1250, 2 * time.Second, slog.LevelInfo},
+		{"INSERT INTO users (name) VALUES ('john')", 0, 5 * time.Millisecond, slog.LevelError},
+	}
+
+	for _, q := range queries {
+		qctx := context.WithValue(ctx, Sql, q.sql)
+		qctx = context.WithValue(qctx, Rows, q.rows)
+		qctx = context.WithValue(qctx, Duration, q.duration)
+
+		msg := ""
+		if q.level == slog.LevelError {
+			msg = "duplicate key value violates unique constraint"
+		}
+
+		if err := h.Handle(qctx, slog.NewRecord(now, q.level, msg, pcs[0])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
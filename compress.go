@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+)
+
+// CompressStream — потоковый компрессор: *gzip.Writer, *zstd.Encoder
+// (модуль github.com/bairto15/slog_gorm_color/zstd) и т.п.
+type CompressStream interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Compressor создает поток сжатия поверх w
+type Compressor func(w io.Writer) (CompressStream, error)
+
+// Gzip возвращает Compressor с уровнем сжатия gzip, например gzip.BestSpeed
+func Gzip(level int) Compressor {
+	return func(w io.Writer) (CompressStream, error) {
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+var ErrCompressClosed = errors.New("logger: compress writer is closed")
+
+// CompressWriter сжимает вывод логера перед записью в файл или сетевое
+// соединение, см. также RotatingFile и NetworkWriter. Сжатые данные копятся в компрессоре: Flush сбрасывает
+// их в w (и вызывает Sync/Flush у w), Close завершает поток. Чтобы записи
+// об ошибках не терялись при падении процесса, задайте Options.FlushLevel.
+//
+// После ошибки записи в w поток считается поврежденным: все последующие
+// вызовы возвращают эту ошибку до Reset с новым получателем.
+type CompressWriter struct {
+	mu  sync.Mutex
+	c   Compressor
+	w   io.Writer
+	z   CompressStream
+	err error
+}
+
+func NewCompressWriter(w io.Writer, c Compressor) (*CompressWriter, error) {
+	z, err := c(w)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompressWriter{c: c, w: w, z: z}, nil
+}
+
+func (cw *CompressWriter) Write(p []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.z.Write(p)
+	if err != nil {
+		cw.err = err
+	}
+	return n, err
+}
+
+// Flush сбрасывает сжатый блок в w
+func (cw *CompressWriter) Flush() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.err != nil {
+		return cw.err
+	}
+
+	if err := cw.z.Flush(); err != nil {
+		cw.err = err
+		return err
+	}
+
+	return flushWriter(cw.w)
+}
+
+// Close завершает поток сжатия. w не закрывается.
+func (cw *CompressWriter) Close() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.err != nil {
+		return cw.err
+	}
+
+	err := cw.z.Close()
+	if err == nil {
+		err = flushWriter(cw.w)
+	}
+
+	cw.err = ErrCompressClosed
+	return err
+}
+
+// Reset завершает текущий поток (ошибка игнорируется, если поток уже
+// поврежден) и начинает новый поток в w — после ротации файла или
+// переподключения
+func (cw *CompressWriter) Reset(w io.Writer) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	var closeErr error
+	if cw.err == nil {
+		closeErr = cw.z.Close()
+	}
+
+	z, err := cw.c(w)
+	if err != nil {
+		cw.err = err
+		return err
+	}
+
+	cw.w, cw.z, cw.err = w, z, nil
+	return closeErr
+}
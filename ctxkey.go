@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// CtxExtractor достает атрибут из контекста. Экстракторы из
+// Options.CtxExtractors выводятся после значений AddCxtAttr.
+type CtxExtractor interface {
+	Extract(ctx context.Context) (slog.Attr, bool)
+}
+
+// CtxKey — типизированный ключ контекста. Значение выводится атрибутом
+// с именем ключа без форматирования через fmt и приведения типов.
+//
+//	var UserID = logger.NewCtxKey[int64]("user_id")
+//
+//	ctx = UserID.Set(ctx, 42)
+//	id, ok := UserID.Get(ctx)
+//
+//	logger.InitDevLogger(logger.Options{CtxExtractors: []logger.CtxExtractor{UserID}})
+type CtxKey[T any] struct {
+	name string
+}
+
+// NewCtxKey создает ключ. Ключи различаются по указателю,
+// два ключа с одним именем не пересекаются.
+func NewCtxKey[T any](name string) *CtxKey[T] {
+	return &CtxKey[T]{name: name}
+}
+
+// Name возвращает имя атрибута
+func (k *CtxKey[T]) Name() string {
+	return k.name
+}
+
+// Set возвращает контекст со значением v
+func (k *CtxKey[T]) Set(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// Get возвращает значение из контекста
+func (k *CtxKey[T]) Get(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+func (k *CtxKey[T]) Extract(ctx context.Context) (slog.Attr, bool) {
+	v, ok := k.Get(ctx)
+	if !ok {
+		return slog.Attr{}, false
+	}
+
+	return slog.Attr{Key: k.name, Value: slog.AnyValue(v)}, true
+}
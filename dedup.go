@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// dedupAttrs удаляет атрибуты с повторяющимся полным ключом, оставляя
+// последний на его месте. Одноименные группы объединяются: a={x=1 y=2} и
+// a={y=3} дают a={x=1 y=3}. Группы с пустым ключом встраиваются, как это
+// делают обработчики slog
+func dedupAttrs(attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	index := make(map[string]int, len(attrs))
+	removed := false
+
+	var add func(a slog.Attr)
+	add = func(a slog.Attr) {
+		a.Value = a.Value.Resolve()
+		group := a.Value.Kind() == slog.KindGroup
+
+		if a.Key == "" {
+			if group {
+				for _, m := range a.Value.Group() {
+					add(m)
+				}
+			}
+			return
+		}
+
+		if i, ok := index[a.Key]; ok {
+			prev := out[i].Value
+			if group && prev.Kind() == slog.KindGroup {
+				out[i].Value = slog.GroupValue(append(slices.Clip(prev.Group()), a.Value.Group()...)...)
+				return
+			}
+			out[i] = slog.Attr{}
+			removed = true
+		}
+
+		index[a.Key] = len(out)
+		out = append(out, a)
+	}
+
+	for _, a := range attrs {
+		add(a)
+	}
+
+	if removed {
+		out = slices.DeleteFunc(out, func(a slog.Attr) bool { return a.Key == "" })
+	}
+
+	for i, a := range out {
+		if a.Value.Kind() == slog.KindGroup {
+			out[i].Value = slog.GroupValue(dedupAttrs(a.Value.Group())...)
+		}
+	}
+
+	return out
+}
+
+// dedupRecord оставляет в записи атрибуты attrs и атрибуты записи без
+// повторов полного ключа
+func dedupRecord(attrs []slog.Attr, rec slog.Record) slog.Record {
+	all := make([]slog.Attr, 0, len(attrs)+rec.NumAttrs())
+	all = append(all, attrs...)
+	rec.Attrs(func(a slog.Attr) bool {
+		all = append(all, a)
+		return true
+	})
+
+	r := slog.NewRecord(rec.Time, rec.Level, rec.Message, rec.PC)
+	r.AddAttrs(dedupAttrs(all)...)
+	return r
+}
+
+// appendScopedAttrs выводит атрибуты DedupAttrs, вложенные в группы
+// WithGroup. В стиле GroupDots группы WithGroup выводятся префиксом ключей,
+// как и без DedupAttrs
+func (h *handlerTextColor) appendScopedAttrs(buf *buffer, attrs []slog.Attr, depth int) {
+	prefix := ""
+	if depth > 0 {
+		prefix = strings.Join(h.groups[:depth], ".") + "."
+	}
+
+	for _, a := range attrs {
+		if h.groupStyle == GroupDots && depth < len(h.groups) && a.Key == h.groups[depth] && a.Value.Kind() == slog.KindGroup {
+			h.appendScopedAttrs(buf, a.Value.Group(), depth+1)
+			continue
+		}
+		h.appendAttr(buf, a, prefix, h.groups[:depth])
+	}
+}
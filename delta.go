@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// Максимальное количество ключей, для которых хранится предыдущее значение
+const deltaCacheSize = 256
+
+// deltaCache хранит последние значения числовых атрибутов.
+// При переполнении вытесняется самый старый ключ.
+type deltaCache struct {
+	mu     sync.Mutex
+	max    int
+	values map[string]float64
+	order  []string
+}
+
+func newDeltaCache(max int) *deltaCache {
+	return &deltaCache{
+		max:    max,
+		values: make(map[string]float64, max),
+	}
+}
+
+// swap сохраняет новое значение и возвращает предыдущее
+func (c *deltaCache) swap(key string, v float64) (prev float64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok = c.values[key]
+	if !ok {
+		if len(c.order) >= c.max {
+			delete(c.values, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.values[key] = v
+
+	return prev, ok
+}
+
+func (h *handlerTextColor) appendDelta(buf *buffer, key string, v slog.Value) {
+	if h.deltas == nil {
+		return
+	}
+	if _, ok := h.deltaKeys[key]; !ok {
+		return
+	}
+
+	var cur float64
+	isInt := true
+
+	switch v.Kind() {
+	case slog.KindInt64:
+		cur = float64(v.Int64())
+	case slog.KindUint64:
+		cur = float64(v.Uint64())
+	case slog.KindFloat64:
+		cur = v.Float64()
+		isInt = false
+	default:
+		return
+	}
+
+	prev, ok := h.deltas.swap(key, cur)
+	if !ok {
+		return
+	}
+
+	diff := cur - prev
+
+	buf.WriteString(h.theme.Faint)
+	buf.WriteString(" (")
+	if diff >= 0 {
+		buf.WriteByte('+')
+	}
+	if isInt {
+		*buf = strconv.AppendInt(*buf, int64(diff), 10)
+	} else {
+		*buf = strconv.AppendFloat(*buf, diff, 'g', -1, 64)
+	}
+	buf.WriteByte(')')
+	buf.WriteString(h.theme.reset)
+}
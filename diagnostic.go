@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Коды диагностики. Коды выводятся в тексте ошибок и в атрибуте code
+// служебных записей, по ним ищется описание: go doc logger.CodeNilWriter
+const (
+	// cfg001: Options.W не задан при вызове Validate. Задайте writer или
+	// вызовите Options.ApplyDefaults (os.Stderr); конструкторы логеров
+	// подставляют os.Stderr сами
+	CodeNilWriter = "cfg001"
+	// cfg002: заданы одновременно Options.ForceColor и Options.DisableColor.
+	// Оставьте один из них; NO_COLOR учитывается без DisableColor
+	CodeColorConflict = "cfg002"
+	// ctx001: в контексте записи есть Duration или Rows, но нет Sql —
+	// обычно контекст gorm заменен до вызова логера. Запрос не выводится,
+	// передавайте в логер контекст, полученный из Trace
+	CodeMissingSQL = "ctx001"
+	// trc001: Trace получил отрицательную или больше gormslog.GormOptions.MaxDuration
+	// длительность запроса — begin без монотонных часов (десериализован,
+	// обрезан) и перевод системных часов. Передайте длительность явно через
+	// gormslog.WithQueryDuration
+	CodeBadDuration = "trc001"
+)
+
+// Ключ атрибута с кодом в служебных записях
+const DiagnosticCodeKey = "code"
+
+// DiagnosticError — ошибка настройки или использования логера с кодом.
+// errors.Is сравнивает ошибки по коду.
+type DiagnosticError struct {
+	Code string
+	Msg  string
+}
+
+func (e *DiagnosticError) Error() string {
+	return "logger: " + e.Code + ": " + e.Msg
+}
+
+func (e *DiagnosticError) Is(target error) bool {
+	t, ok := target.(*DiagnosticError)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrNilWriter     = &DiagnosticError{Code: CodeNilWriter, Msg: "Options.W is nil"}
+	ErrColorConflict = &DiagnosticError{Code: CodeColorConflict, Msg: "Options.ForceColor and Options.DisableColor are both set"}
+	ErrMissingSQL    = &DiagnosticError{Code: CodeMissingSQL, Msg: "context has SQL duration or rows but no SQL"}
+	ErrBadDuration   = &DiagnosticError{Code: CodeBadDuration, Msg: "query duration is negative or implausibly long"}
+)
+
+// diagnostic создает служебную запись уровня Warn с кодом ошибки
+func diagnostic(err *DiagnosticError, attrs ...slog.Attr) slog.Record {
+	r := slog.NewRecord(time.Now(), slog.LevelWarn, err.Error(), 0)
+	r.AddAttrs(slog.String(DiagnosticCodeKey, err.Code))
+	r.AddAttrs(attrs...)
+	return r
+}
+
+// checkSQLContext сообщает о потерянном Sql один раз за время работы обработчика
+func (p *preprocessor) checkSQLContext(ctx context.Context) {
+	if p.missingSQL.Load() || ctx.Value(Sql) != nil {
+		return
+	}
+
+	if ctx.Value(Duration) == nil && ctx.Value(Rows) == nil {
+		return
+	}
+
+	if p.missingSQL.CompareAndSwap(false, true) {
+		p.notify(diagnostic(ErrMissingSQL))
+	}
+}
@@ -0,0 +1,353 @@
+package logger
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DigestConfig — периодический отчет о запросах в webhook: самые долгие по
+// суммарному времени запросы (по SQLFingerprint), классы ошибок и счетчики
+// записей за интервал. Отчет отправляется JSON {"text": "..."}, который
+// принимают входящие webhook Slack, Mattermost и Rocket.Chat:
+//
+//	logger.InitLogger(logger.Options{
+//		Digest: &logger.DigestConfig{URL: os.Getenv("SLACK_WEBHOOK"), Title: "billing"},
+//	})
+//	defer logger.Shutdown(nil)
+//
+// Пустые отчеты не отправляются. Shutdown отправляет отчет за неполный интервал.
+type DigestConfig struct {
+	// Адрес webhook
+	URL string
+	// Интервал отчетов, по умолчанию час
+	Interval time.Duration
+	// Число запросов в отчете, по умолчанию 5
+	Top int
+	// Заголовок отчета, например имя сервиса
+	Title string
+	// HTTP клиент, по умолчанию с таймаутом 10 секунд
+	Client *http.Client
+}
+
+// Ограничение числа отпечатков и классов ошибок за интервал
+const digestMaxKeys = 1000
+
+// digest накапливает статистику запросов за интервал и отправляет ее в webhook
+type digest struct {
+	cfg    DigestConfig
+	slow   SlowConfig
+	notify func(slog.Record)
+
+	mu      sync.Mutex
+	start   time.Time
+	levels  [4]int
+	slowN   int
+	queries map[string]*digestQuery
+	errs    map[string]int
+
+	// отправка по интервалу запускается первой записью: обработчики
+	// RenderRecord и без записей не держат горутину
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+type digestQuery struct {
+	sql   string
+	count int
+	slow  int
+	total time.Duration
+	max   time.Duration
+}
+
+func newDigest(cfg DigestConfig, slow SlowConfig, notify func(slog.Record)) *digest {
+	cfg.Interval = cmp.Or(cfg.Interval, time.Hour)
+	cfg.Top = cmp.Or(cfg.Top, 5)
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	d := &digest{
+		cfg:    cfg,
+		slow:   slow,
+		notify: notify,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	d.reset(time.Now())
+
+	return d
+}
+
+func (d *digest) reset(now time.Time) {
+	d.start = now
+	d.levels = [4]int{}
+	d.slowN = 0
+	d.queries = map[string]*digestQuery{}
+	d.errs = map[string]int{}
+}
+
+func (d *digest) run() {
+	defer close(d.done)
+
+	t := time.NewTicker(d.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			d.flush()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// close останавливает отправку по интервалу и отправляет последний отчет
+func (d *digest) close() error {
+	var err error
+	d.stopOnce.Do(func() {
+		// без записей горутина не запускалась и больше не запустится
+		d.startOnce.Do(func() { close(d.done) })
+		close(d.stop)
+		<-d.done
+		err = d.flush()
+	})
+	return err
+}
+
+func (d *digest) record(ctx context.Context, r slog.Record) {
+	d.startOnce.Do(func() { go d.run() })
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case r.Level < slog.LevelInfo:
+		d.levels[0]++
+	case r.Level < slog.LevelWarn:
+		d.levels[1]++
+	case r.Level < slog.LevelError:
+		d.levels[2]++
+	default:
+		d.levels[3]++
+		if class := errorClass(r); len(d.errs) < digestMaxKeys || d.errs[class] > 0 {
+			d.errs[class]++
+		}
+	}
+
+	dur, ok := ctx.Value(Duration).(time.Duration)
+	if !ok {
+		return
+	}
+	sql, _ := ctx.Value(Sql).(string)
+	if sql == "" {
+		return
+	}
+
+	fp := sqlFingerprint(sql)
+	q := d.queries[fp]
+	if q == nil {
+		if len(d.queries) >= digestMaxKeys {
+			return
+		}
+		q = &digestQuery{sql: fp}
+		d.queries[fp] = q
+	}
+
+	q.count++
+	q.total += dur
+	q.max = max(q.max, dur)
+	if d.slow.IsSlow(sql, dur) {
+		q.slow++
+		d.slowN++
+	}
+}
+
+// errorClass — класс ошибки записи: код SQLSTATE ошибки драйвера, тип
+// значения-ошибки в атрибутах или сообщение записи
+func errorClass(r slog.Record) string {
+	var class string
+	r.Attrs(func(a slog.Attr) bool {
+		v := a.Value.Resolve()
+		switch {
+		case a.Key == "db" && v.Kind() == slog.KindGroup:
+			for _, f := range v.Group() {
+				if f.Key == "sqlstate" || f.Key == "errno" {
+					class = f.Key + " " + f.Value.String()
+					return false
+				}
+			}
+		case v.Kind() == slog.KindAny:
+			if err, ok := v.Any().(error); ok && class == "" {
+				class = errorType(err)
+			}
+		}
+		return true
+	})
+
+	if class == "" {
+		class = TruncateWidth(r.Message, 80)
+	}
+
+	return class
+}
+
+// errorType — тип самой внутренней ошибки цепочки
+func errorType(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = next
+	}
+}
+
+// flush отправляет отчет за прошедший интервал
+func (d *digest) flush() error {
+	d.mu.Lock()
+	now := time.Now()
+	text := d.text(now)
+	d.reset(now)
+	d.mu.Unlock()
+
+	if text == "" {
+		return nil
+	}
+
+	err := d.post(text)
+	if err != nil {
+		// *url.Error содержит адрес webhook вместе с токеном
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = redactURL(uerr.URL)
+		}
+
+		r := slog.NewRecord(time.Now(), slog.LevelWarn, "digest webhook failed", 0)
+		r.AddAttrs(slog.String("url", redactURL(d.cfg.URL)), slog.Any("error", err))
+		d.notify(r)
+	}
+
+	return err
+}
+
+func (d *digest) post(text string) error {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{text})
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.cfg.Client.Post(d.cfg.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("digest webhook: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// text собирает отчет в разметке Slack, пустую строку — если записей не было
+func (d *digest) text(now time.Time) string {
+	total := d.levels[0] + d.levels[1] + d.levels[2] + d.levels[3]
+	if total == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteString("*")
+	if d.cfg.Title != "" {
+		b.WriteString(d.cfg.Title)
+		b.WriteString(": ")
+	}
+	b.WriteString("query digest*  ")
+	b.WriteString(d.start.Format("15:04"))
+	b.WriteString("–")
+	b.WriteString(now.Format("15:04"))
+	b.WriteString(" (")
+	b.WriteString(now.Sub(d.start).Round(time.Second).String())
+	b.WriteString(")\n")
+
+	fmt.Fprintf(&b, "records: %d debug, %d info, %d warn, %d error; slow queries: %d\n",
+		d.levels[0], d.levels[1], d.levels[2], d.levels[3], d.slowN)
+
+	if len(d.queries) > 0 {
+		queries := make([]*digestQuery, 0, len(d.queries))
+		for _, q := range d.queries {
+			queries = append(queries, q)
+		}
+		slices.SortFunc(queries, func(a, b *digestQuery) int {
+			return cmp.Or(cmp.Compare(b.total, a.total), strings.Compare(a.sql, b.sql))
+		})
+
+		b.WriteString("*Top queries by total time*\n")
+		for i, q := range queries[:min(len(queries), d.cfg.Top)] {
+			fmt.Fprintf(&b, "%d. `%s` ×%d, total %s, avg %s, max %s",
+				i+1, TruncateWidth(q.sql, 200), q.count,
+				roundDelta(q.total), roundDelta(q.total/time.Duration(q.count)), roundDelta(q.max))
+			if q.slow > 0 {
+				b.WriteString(", slow ")
+				b.WriteString(strconv.Itoa(q.slow))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(d.errs) > 0 {
+		classes := make([]string, 0, len(d.errs))
+		for c := range d.errs {
+			classes = append(classes, c)
+		}
+		slices.SortFunc(classes, func(a, b string) int {
+			return cmp.Or(cmp.Compare(d.errs[b], d.errs[a]), strings.Compare(a, b))
+		})
+
+		b.WriteString("*Errors*\n")
+		for _, c := range classes[:min(len(classes), d.cfg.Top)] {
+			fmt.Fprintf(&b, "• `%s` ×%d\n", c, d.errs[c])
+		}
+		if len(classes) > d.cfg.Top {
+			fmt.Fprintf(&b, "• %d more\n", len(classes)-d.cfg.Top)
+		}
+	}
+
+	return b.String()
+}
+
+// redactURL скрывает путь webhook: в нем секретный токен
+func redactURL(u string) string {
+	if i := strings.Index(u, "://"); i >= 0 {
+		if j := strings.IndexByte(u[i+3:], '/'); j >= 0 {
+			return u[:i+3+j] + "/***"
+		}
+	}
+	return u
+}
+
+// stopDigest отправляет последний отчет DigestConfig
+func (p *preprocessor) stopDigest() error {
+	if p.digest == nil {
+		return nil
+	}
+	return p.digest.close()
+}
@@ -0,0 +1,29 @@
+// Package logger — цветной dev обработчик slog и middleware для JSON
+// обработчиков.
+//
+// Логер gorm и Plugin находятся в отдельном модуле gormslog, поэтому
+// приложения, которым нужен только обработчик slog, не получают
+// gorm.io/gorm в графе модулей:
+//
+//	import "github.com/bairto15/slog_gorm_color/gormslog"
+//
+//	db.Logger = gormslog.NewGormLogger(false, nil)
+//
+// Ключи атрибутов (Sql, Preload, LockWait ...) объявлены в этом пакете:
+// их читают обработчики, а заполняет gormslog.
+//
+// # Переход на gormslog
+//
+// Функции и типы, зависящие от gorm, удалены из этого пакета без
+// переходных оберток: обертка потребовала бы импорта gorm. Имена в
+// gormslog прежние, достаточно заменить пакет и добавить модуль
+// (go get github.com/bairto15/slog_gorm_color/gormslog):
+//
+//	logger.NewGormLogger, logger.NewGormLoggerOptions, logger.GormOptions
+//	logger.NewPlugin, logger.PluginOptions, logger.NewSQLCommenter
+//	logger.CreateInBatches, logger.BatchError, logger.NewMigrator
+//	logger.GormLevel, logger.SlogLevel, logger.LevelSilent
+//	logger.WithLockWait, logger.WithQueryDuration, logger.PgxStmtName
+//
+// → gormslog.NewGormLogger, gormslog.GormOptions и т.д.
+package logger
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Ширина длительности в SQL бейдже при Options.Align: 12.3ms, 2m05s
+const badgeWidth = 6
+
+// badgeDuration форматирует длительность запроса для SQL бейджа: три
+// значащие цифры в единицах по величине — 850ns, 12.4µs, 1.23ms, 456ms,
+// 2.50s, 2m05s, 1h30m
+func badgeDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + badgeDuration(-d)
+	}
+
+	d = roundDelta(d)
+
+	switch {
+	case d < time.Microsecond:
+		return strconv.FormatInt(int64(d), 10) + "ns"
+	case d < time.Millisecond:
+		return significant(float64(d)/float64(time.Microsecond)) + "µs"
+	case d < time.Second:
+		return significant(float64(d)/float64(time.Millisecond)) + "ms"
+	case d < time.Minute:
+		return significant(d.Seconds()) + "s"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", d/time.Minute, d%time.Minute/time.Second)
+	}
+
+	return fmt.Sprintf("%dh%02dm", d/time.Hour, d%time.Hour/time.Minute)
+}
+
+// significant форматирует v от 1 до 1000 с тремя значащими цифрами
+func significant(v float64) string {
+	prec := 2
+	switch {
+	case v >= 100:
+		prec = 0
+	case v >= 10:
+		prec = 1
+	}
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
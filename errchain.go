@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// Ограничение глубины цепочки ошибок и числа кадров стека
+const (
+	errorChainDepth = 32
+	errorStackDepth = 32
+)
+
+// appendErrorChains выводит под записью причины ошибок из атрибутов:
+// строку на каждую ошибку цепочки errors.Unwrap с отступом по глубине, и
+// стек самой глубокой ошибки с методом StackTrace при Options.ErrorStack
+//
+//	12:00:00 ERROR load failed err="load config: open app.yaml: no such file or directory"
+//	    ↳ open app.yaml: no such file or directory *fs.PathError
+//	      ↳ no such file or directory syscall.Errno
+func (h *handlerTextColor) appendErrorChains(ctx context.Context, buf *buffer, r slog.Record) {
+	for _, attr := range core.ScopeAttrs(ctx) {
+		h.appendAttrErrorChains(buf, attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		h.appendAttrErrorChains(buf, attr)
+		return true
+	})
+}
+
+func (h *handlerTextColor) appendAttrErrorChains(buf *buffer, attr slog.Attr) {
+	v := attr.Value.Resolve()
+
+	switch v.Kind() {
+	case slog.KindGroup:
+		for _, m := range v.Group() {
+			h.appendAttrErrorChains(buf, m)
+		}
+	case slog.KindAny:
+		err, ok := v.Any().(error)
+		if !ok {
+			return
+		}
+		if le, ok := err.(logError); ok {
+			err = le.error
+		}
+
+		var stack reflect.Value
+		if h.errorStack {
+			stack = errorStack(err)
+		}
+
+		h.appendCauses(buf, err, 1)
+
+		if stack.IsValid() {
+			h.appendStack(buf, stack)
+		}
+	}
+}
+
+// appendCauses выводит ошибки, которые оборачивает err
+func (h *handlerTextColor) appendCauses(buf *buffer, err error, depth int) {
+	if depth > errorChainDepth {
+		return
+	}
+
+	var causes []error
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if c := u.Unwrap(); c != nil {
+			causes = []error{c}
+		}
+	case interface{ Unwrap() []error }:
+		causes = u.Unwrap()
+	}
+
+	for _, c := range causes {
+		if c == nil {
+			continue
+		}
+
+		buf.WriteByte('\n')
+		buf.WriteString(strings.Repeat(" ", 2+2*depth))
+		buf.WriteString(h.theme.ErrorMessage)
+		buf.WriteString("↳ ")
+		buf.WriteString(sanitizeUTF8(c.Error(), h.invalidUTF8))
+		buf.WriteString(h.theme.reset)
+		buf.WriteByte(' ')
+		buf.WriteString(h.theme.Faint)
+		buf.WriteString(fmt.Sprintf("%T", c))
+		buf.WriteString(h.theme.reset)
+		buf.WriteByte(' ')
+
+		h.appendCauses(buf, c, depth+1)
+	}
+}
+
+// errorStack возвращает стек самой глубокой ошибки цепочки с методом
+// StackTrace() без аргументов: github.com/pkg/errors и совместимые пакеты
+// дают стек каждой обертке, первый кадр — место создания ошибки
+func errorStack(err error) reflect.Value {
+	var stack reflect.Value
+
+	for i := 0; err != nil && i < errorChainDepth; i++ {
+		m := reflect.ValueOf(err).MethodByName("StackTrace")
+		if m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() == 1 {
+			if s := m.Call(nil)[0]; s.Kind() == reflect.Slice && s.Len() > 0 {
+				stack = s
+			}
+		}
+		err = errors.Unwrap(err)
+	}
+
+	return stack
+}
+
+// appendStack выводит кадры стека: program counter (errors.Frame,
+// uintptr), runtime.Frame или значения с форматом %+v
+func (h *handlerTextColor) appendStack(buf *buffer, stack reflect.Value) {
+	for i := range min(stack.Len(), errorStackDepth) {
+		buf.WriteString("\n      ")
+		buf.WriteString(h.theme.Faint)
+		buf.WriteString("at ")
+
+		frame := stack.Index(i)
+		switch {
+		case frame.Kind() == reflect.Uintptr:
+			// адрес возврата указывает на инструкцию после вызова
+			pc := uintptr(frame.Uint()) - 1
+			fn := runtime.FuncForPC(pc)
+			if fn == nil {
+				buf.WriteString("unknown")
+				break
+			}
+			file, line := fn.FileLine(pc)
+			h.appendFrame(buf, fn.Name(), file, line)
+		case frame.Type() == reflect.TypeFor[runtime.Frame]():
+			f := frame.Interface().(runtime.Frame)
+			h.appendFrame(buf, f.Function, f.File, f.Line)
+		default:
+			buf.WriteString(fmt.Sprintf("%+v", frame.Interface()))
+		}
+
+		buf.WriteString(h.theme.reset)
+		buf.WriteByte(' ')
+	}
+}
+
+func (h *handlerTextColor) appendFrame(buf *buffer, function, file string, line int) {
+	buf.WriteString(function)
+	buf.WriteString(" (")
+	buf.WriteString(sourcePath(file, h.sourcePath))
+	buf.WriteByte(':')
+	buf.WriteString(strconv.Itoa(line))
+	buf.WriteByte(')')
+}
@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Объем вывода, который задерживается паузой; остальное отбрасывается
+const focusHoldLimit = 1 << 20
+
+var focusLevels = map[byte]slog.Level{
+	'e': slog.LevelError,
+	'w': slog.LevelWarn,
+	'i': slog.LevelInfo,
+	'd': slog.LevelDebug,
+}
+
+// focus — переключатели dev обработчика, которые меняются клавишами при
+// Options.KeyboardControl: уровень, скрытие SQL, пауза вывода
+type focus struct {
+	level   *slog.LevelVar
+	hideSQL atomic.Bool
+	paused  atomic.Bool
+
+	// вывод во время паузы, под h.mu
+	held    []byte
+	dropped int
+
+	// остановка чтения клавиш StartKeyboard, nil — клавиши не читаются
+	keysMu sync.Mutex
+	stop   func()
+}
+
+// initFocus включает переключатели. Уровень обработчика заменяется на
+// LevelVar: Options.Level, если это LevelVar, иначе новый с тем же уровнем
+func (h *handlerTextColor) initFocus() {
+	lv, ok := h.level.(*slog.LevelVar)
+	if !ok {
+		lv = new(slog.LevelVar)
+		lv.Set(h.level.Level())
+	}
+
+	h.level = lv
+	h.pre.level = lv
+	h.focus = &focus{level: lv}
+}
+
+var (
+	ErrKeyboardDisabled = errors.New("logger: keyboard control needs a dev handler with Options.KeyboardControl")
+	ErrKeyboardNoTTY    = errors.New("logger: keyboard control needs terminal output and a foreground terminal stdin")
+	ErrKeyboardStarted  = errors.New("logger: keyboard control is already started")
+)
+
+// Keyboard — чтение клавиш для переключателей Options.KeyboardControl
+type Keyboard struct {
+	h *handlerTextColor
+}
+
+// StartKeyboard начинает читать клавиши из stdin для переключателей dev
+// обработчика h (или установленного InitDevLogger: slog.Default().Handler()).
+// Нужны Options.KeyboardControl, W и stdin — терминалы Unix и процесс в
+// группе переднего плана. Логер забирает stdin: он читается в
+// неканоническом режиме без эха с VMIN=0, поэтому не включайте, если
+// приложение само читает stdin.
+//
+// Режим терминала восстанавливают Stop и Shutdown. SIGINT и SIGTERM тоже
+// восстанавливают его и отключают чтение, не мешая обработке сигнала
+// приложением; без своего signal.Notify приложение завершает повторный
+// сигнал. os.Exit, log.Fatal и паника режим не восстанавливают.
+//
+//	logger.InitDevLogger(logger.Options{KeyboardControl: true})
+//	if kb, err := logger.StartKeyboard(slog.Default().Handler()); err == nil {
+//		defer kb.Stop()
+//	}
+func StartKeyboard(h slog.Handler) (*Keyboard, error) {
+	if s, ok := h.(*swapHandler); ok {
+		h = *s.root.handler.Load()
+	}
+	dev, ok := h.(*handlerTextColor)
+	if !ok || dev.focus == nil {
+		return nil, ErrKeyboardDisabled
+	}
+	if !writerIsTerminal(dev.w) || !writerIsTerminal(os.Stdin) {
+		return nil, ErrKeyboardNoTTY
+	}
+
+	f := dev.focus
+	f.keysMu.Lock()
+	defer f.keysMu.Unlock()
+
+	if f.stop != nil {
+		return nil, ErrKeyboardStarted
+	}
+	stop, ok := readKeys(os.Stdin, dev.handleKey)
+	if !ok {
+		return nil, ErrKeyboardNoTTY
+	}
+	f.stop = stop
+
+	return &Keyboard{h: dev}, nil
+}
+
+// Stop прекращает чтение клавиш и восстанавливает режим терминала.
+// Переключатели сохраняют последние значения
+func (k *Keyboard) Stop() {
+	k.h.stopKeyboard()
+}
+
+// stopKeyboard возвращает терминал в исходный режим
+func (h *handlerTextColor) stopKeyboard() {
+	if h.focus == nil {
+		return
+	}
+
+	h.focus.keysMu.Lock()
+	defer h.focus.keysMu.Unlock()
+
+	if h.focus.stop != nil {
+		h.focus.stop()
+		h.focus.stop = nil
+	}
+}
+
+// handleKey: e, w, i, d — минимальный уровень, s — скрыть или показать
+// SQL запросы, p — пауза вывода и продолжение
+func (h *handlerTextColor) handleKey(key byte) {
+	f := h.focus
+
+	switch key {
+	case 'e', 'w', 'i', 'd':
+		level := focusLevels[key]
+		f.level.Set(level)
+		h.writeStatus("level " + level.String())
+	case 's':
+		if f.hideSQL.Load() {
+			f.hideSQL.Store(false)
+			h.writeStatus("sql shown")
+		} else {
+			f.hideSQL.Store(true)
+			h.writeStatus("sql hidden")
+		}
+	case 'p':
+		if !f.paused.Load() {
+			h.writeStatus("paused, press p to resume")
+			f.paused.Store(true)
+			return
+		}
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		f.paused.Store(false)
+		_, _ = h.w.Write(f.held)
+		f.held = nil
+
+		status := "resumed"
+		if f.dropped > 0 {
+			status += ", dropped " + strconv.Itoa(f.dropped) + " records"
+			f.dropped = 0
+		}
+		h.writeStatusLocked(status)
+	}
+}
+
+// hidden сообщает, что запись скрыта переключателем s
+func (f *focus) hidden(ctx context.Context) bool {
+	return f.hideSQL.Load() && ctx.Value(Sql) != nil
+}
+
+// hold задерживает вывод во время паузы. Вызывается под h.mu, возвращает
+// false, если пауза не включена
+func (f *focus) hold(out []byte) bool {
+	if !f.paused.Load() {
+		return false
+	}
+
+	if len(f.held)+len(out) > focusHoldLimit {
+		f.dropped++
+		return true
+	}
+
+	f.held = append(f.held, out...)
+	return true
+}
+
+func (h *handlerTextColor) writeStatus(status string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeStatusLocked(status)
+}
+
+func (h *handlerTextColor) writeStatusLocked(status string) {
+	_, _ = h.w.Write([]byte(h.theme.Faint + "[keys] " + status + h.theme.reset + "\n"))
+}
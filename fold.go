@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+type expandKey struct{}
+
+// Expand возвращает контекст, записи с которым выводятся без сворачивания
+// длинных значений (Options.FoldValues) — для отладки конкретного запроса
+func Expand(ctx context.Context) context.Context {
+	return context.WithValue(ctx, expandKey{}, true)
+}
+
+func isExpanded(ctx context.Context) bool {
+	v, _ := ctx.Value(expandKey{}).(bool)
+	return v
+}
+
+// appendStringValue выводит строковое значение, сворачивая длинные и
+// многострочные значения до первой строки: первая строка … (+12 lines, 4.2KB)
+func (h *handlerTextColor) appendStringValue(buf *buffer, s string, quote bool) {
+	s = sanitizeUTF8(s, h.invalidUTF8)
+
+	short := len(s) <= h.foldValues || DisplayWidth(s) <= h.foldValues
+	if h.foldValues <= 0 || short && !strings.Contains(s, "\n") {
+		if h.maxValueLen > 0 && len(s) > h.maxValueLen {
+			cut := h.appendTruncated(buf, s, quote)
+			h.appendTruncatedNote(buf, len(s)-cut)
+			return
+		}
+		appendString(buf, s, quote, true)
+		return
+	}
+
+	first, rest, multiline := strings.Cut(s, "\n")
+
+	// многоточие добавляется вместе с пометкой
+	appendString(buf, strings.TrimSuffix(TruncateWidth(first, h.foldValues), "…"), quote, true)
+
+	buf.WriteString(h.theme.Faint)
+	buf.WriteString(" … (")
+	if multiline {
+		buf.WriteString("+")
+		lines := strings.Count(rest, "\n")
+		if !strings.HasSuffix(rest, "\n") {
+			lines++
+		}
+		buf.WriteString(strconv.Itoa(lines))
+		buf.WriteString(" lines, ")
+	}
+	buf.WriteString(formatSize(len(s)))
+	buf.WriteString(")")
+	buf.WriteString(h.theme.reset)
+}
+
+// appendTruncated выводит первые Options.MaxValueLen байт s, не разрезая
+// символы UTF-8, и возвращает число выведенных байт
+func (h *handlerTextColor) appendTruncated(buf *buffer, s string, quote bool) int {
+	cut := strings.TrimSuffix(truncateSQL(s, h.maxValueLen), "…")
+	if quote {
+		appendString(buf, cut, true, true)
+	} else {
+		buf.WriteString(cut)
+	}
+	return len(cut)
+}
+
+// appendTruncatedNote выводит пометку об обрезанных байтах: … (+N bytes)
+func (h *handlerTextColor) appendTruncatedNote(buf *buffer, n int) {
+	buf.WriteString(h.theme.Faint)
+	buf.WriteString("… (+")
+	buf.WriteString(strconv.Itoa(n))
+	buf.WriteString(" bytes)")
+	buf.WriteString(h.theme.reset)
+}
+
+// formatSize форматирует размер в байтах: 512B, 4.2KB, 1.3MB
+func formatSize(n int) string {
+	switch {
+	case n < 1<<10:
+		return strconv.Itoa(n) + "B"
+	case n < 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<10), 'f', 1, 64) + "KB"
+	default:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + "MB"
+	}
+}
@@ -1,5 +1,3 @@
 module github.com/bairto15/slog_gorm_color
 
 go 1.24.0
-
-require gorm.io/gorm v1.31.1
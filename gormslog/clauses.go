@@ -0,0 +1,110 @@
+package gormslog
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type clausesKey struct{}
+
+// clausesCallback сохраняет в контексте запроса его предложения: условия
+// WHERE по отдельности (в том числе добавленные Scopes и мягким удалением),
+// остальные — по имени
+func clausesCallback(db *gorm.DB) {
+	stmt := db.Statement
+	if len(stmt.Clauses) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	for _, name := range stmt.BuildClauses {
+		c, ok := stmt.Clauses[name]
+		if !ok {
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(name)
+
+		where, ok := c.Expression.(clause.Where)
+		if !ok || len(where.Exprs) == 0 {
+			continue
+		}
+
+		b.WriteByte('[')
+		for i, e := range where.Exprs {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			e.Build(&clauseText{Builder: &b, stmt: stmt})
+		}
+		b.WriteByte(']')
+	}
+
+	if b.Len() > 0 {
+		stmt.Context = context.WithValue(stmt.Context, clausesKey{}, b.String())
+	}
+}
+
+// clausesAttrs возвращает атрибут Clauses из контекста запроса
+func clausesAttrs(ctx context.Context) (slog.Attr, bool) {
+	s, ok := ctx.Value(clausesKey{}).(string)
+	if !ok {
+		return slog.Attr{}, false
+	}
+	return slog.String(slogcolor.Clauses, s), true
+}
+
+// clauseText — clause.Builder, который выводит условие с плейсхолдерами
+// ? вместо значений, без добавления переменных в запрос
+type clauseText struct {
+	*strings.Builder
+	stmt *gorm.Statement
+}
+
+func (b *clauseText) WriteQuoted(field any) {
+	b.stmt.QuoteTo(b, field)
+}
+
+func (b *clauseText) AddVar(w clause.Writer, vars ...any) {
+	for i, v := range vars {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+
+		switch v := v.(type) {
+		case clause.Expression:
+			v.Build(b)
+		case clause.Column, clause.Table:
+			b.WriteQuoted(v)
+		default:
+			w.WriteByte('?')
+		}
+	}
+}
+
+func (b *clauseText) AddError(err error) error {
+	return err
+}
+
+func (p *Plugin) registerClauses(db *gorm.DB) error {
+	cb := db.Callback()
+
+	if err := cb.Query().After("gorm:query").Register("slog:clauses", clausesCallback); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("slog:clauses", clausesCallback); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("slog:clauses", clausesCallback); err != nil {
+		return err
+	}
+	return cb.Row().After("gorm:row").Register("slog:clauses", clausesCallback)
+}
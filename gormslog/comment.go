@@ -0,0 +1,80 @@
+package gormslog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// correlationComment — выражение, которое gorm пишет перед главным предложением
+// запроса: /* request_id=abc123 */ SELECT ...
+type correlationComment string
+
+func (c correlationComment) Build(builder clause.Builder) {
+	builder.WriteString(string(c))
+}
+
+// buildCorrelationComment собирает комментарий из значений контекста.
+// Пустая строка, если в контексте нет ни одного ключа.
+func buildCorrelationComment(ctx context.Context, keys []string) string {
+	var b strings.Builder
+
+	for _, k := range keys {
+		v := ctx.Value(k)
+		if v == nil {
+			continue
+		}
+
+		if b.Len() == 0 {
+			b.WriteString("/* ")
+		} else {
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strings.ReplaceAll(fmt.Sprint(v), "*/", "* /"))
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	b.WriteString(" */")
+	return b.String()
+}
+
+// correlationCallback добавляет комментарий с идентификаторами из контекста
+// к главному предложению запроса, а для Raw/Exec — в начало готового SQL
+func correlationCallback(keys []string, clauseName string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		comment := buildCorrelationComment(stmt.Context, keys)
+		if comment == "" {
+			return
+		}
+
+		if stmt.SQL.Len() > 0 {
+			sql := stmt.SQL.String()
+			if strings.HasPrefix(sql, "/* ") {
+				return
+			}
+			stmt.SQL.Reset()
+			stmt.SQL.WriteString(comment)
+			stmt.SQL.WriteByte(' ')
+			stmt.SQL.WriteString(sql)
+			return
+		}
+
+		if clauseName == "" {
+			return
+		}
+
+		c := stmt.Clauses[clauseName]
+		c.BeforeExpression = correlationComment(comment)
+		stmt.Clauses[clauseName] = c
+	}
+}
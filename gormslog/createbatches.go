@@ -0,0 +1,128 @@
+package gormslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+	"github.com/bairto15/slog_gorm_color/internal/core"
+	"gorm.io/gorm"
+)
+
+// BatchError — ошибка одной пачки CreateInBatches
+type BatchError struct {
+	// Номер пачки с 0
+	Batch int
+	// Индекс первого элемента пачки в срезе и размер пачки
+	Offset, Count int
+	Err           error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d (rows %d-%d): %v", e.Batch, e.Offset, e.Offset+e.Count-1, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+type batchKey struct{}
+
+// Пачка CreateInBatches в контексте запроса для gorm логера
+type batchInfo struct {
+	index, offset, count int
+}
+
+// CreateInBatches вставляет срез value пачками по batchSize, как
+// db.CreateInBatches, но не останавливается на ошибке пачки. Ошибки
+// запросов пачек пишутся уровнем Debug с группой batch (batch.index,
+// batch.offset, batch.rows), а после всех пачек — одна запись Error
+// "batch insert failed" со счетчиками:
+//
+//	rows, err := gormslog.CreateInBatches(db.WithContext(ctx), users, 500)
+//	var be *gormslog.BatchError
+//	if errors.As(err, &be) { ... } // первая неудачная пачка
+//
+// Возвращает число вставленных строк и errors.Join ошибок *BatchError.
+// Пачки выполняются без общей транзакции: внутри транзакции PostgreSQL
+// после первой ошибки отклоняет остальные пачки, там нужен db.CreateInBatches.
+func CreateInBatches(db *gorm.DB, value any, batchSize int) (int64, error) {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	if batchSize <= 0 || v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		tx := db.CreateInBatches(value, batchSize)
+		return tx.RowsAffected, tx.Error
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var (
+		rows       int64
+		errs       []error
+		failedRows int
+	)
+
+	for offset := 0; offset < v.Len(); offset += batchSize {
+		end := min(offset+batchSize, v.Len())
+		b := batchInfo{index: offset / batchSize, offset: offset, count: end - offset}
+
+		tx := db.WithContext(context.WithValue(ctx, batchKey{}, b)).Create(v.Slice(offset, end).Interface())
+		if tx.Error != nil {
+			errs = append(errs, &BatchError{Batch: b.index, Offset: offset, Count: b.count, Err: tx.Error})
+			failedRows += b.count
+			continue
+		}
+		rows += tx.RowsAffected
+	}
+
+	if len(errs) == 0 {
+		return rows, nil
+	}
+
+	batches := (v.Len() + batchSize - 1) / batchSize
+	logBatchErrors(ctx, errs, batches, rows, failedRows)
+
+	return rows, errors.Join(errs...)
+}
+
+// logBatchErrors пишет итоговую запись о неудачных пачках
+func logBatchErrors(ctx context.Context, errs []error, batches int, rows int64, failedRows int) {
+	ctx = core.WithLoggerName(ctx, slogcolor.LoggerGorm)
+	if core.SourceCapture(slog.Default().Handler()) {
+		funcName, file, line := getGormFuncName()
+		ctx = context.WithValue(ctx, slogcolor.Source, slog.Source{Function: funcName, File: file, Line: line})
+	}
+
+	failed := make([]int, len(errs))
+	for i, err := range errs {
+		failed[i] = err.(*BatchError).Batch
+	}
+
+	core.LogAttrs(ctx, slog.LevelError, "batch insert failed",
+		slog.Int("batches", batches),
+		slog.Int("failed_batches", len(errs)),
+		slog.Any("failed", failed),
+		slog.Int64(slogcolor.Rows, rows),
+		slog.Int("failed_rows", failedRows),
+		slog.Any("error", errs[0].(*BatchError).Err),
+	)
+}
+
+// batchAttr возвращает группу batch запроса пачки CreateInBatches
+func batchAttr(ctx context.Context) (slog.Attr, bool) {
+	b, ok := ctx.Value(batchKey{}).(batchInfo)
+	if !ok {
+		return slog.Attr{}, false
+	}
+
+	return slog.Group("batch",
+		slog.Int("index", b.index),
+		slog.Int("offset", b.offset),
+		slog.Int("rows", b.count),
+	), true
+}
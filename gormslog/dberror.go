@@ -0,0 +1,103 @@
+package gormslog
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"strconv"
+)
+
+// Поля ошибок драйверов, которые выводятся отдельными атрибутами группы db.
+// Драйверы не импортируются: поля ищутся по именам, принятым в
+// pgx (pgconn.PgError), lib/pq (pq.Error) и go-sql-driver/mysql (MySQLError).
+var dbErrorFields = []struct {
+	key    string
+	fields []string
+}{
+	{"sqlstate", []string{"Code", "SQLState"}},
+	{"errno", []string{"Number"}},
+	{"constraint", []string{"ConstraintName", "Constraint"}},
+	{"table", []string{"TableName", "Table"}},
+	{"column", []string{"ColumnName", "Column"}},
+	{"detail", []string{"Detail"}},
+	{"hint", []string{"Hint"}},
+}
+
+// dbError ищет в цепочке err ошибку драйвера БД и возвращает ее основное
+// сообщение и группу атрибутов db (db.sqlstate, db.constraint, db.detail ...)
+func dbError(err error) (string, slog.Attr, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		v := reflect.ValueOf(e)
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+
+		var attrs []slog.Attr
+		for _, f := range dbErrorFields {
+			for _, name := range f.fields {
+				s := dbErrorField(v, name)
+				// SQLSTATE всегда из 5 символов, это отсекает посторонние поля Code
+				if s == "" || f.key == "sqlstate" && len(s) != 5 {
+					continue
+				}
+				attrs = append(attrs, slog.String(f.key, s))
+				break
+			}
+		}
+
+		// ошибка драйвера обязательно содержит код и сообщение
+		msg := dbErrorField(v, "Message")
+		if len(attrs) == 0 || msg == "" || attrs[0].Key != "sqlstate" && attrs[0].Key != "errno" {
+			continue
+		}
+
+		return msg, slog.Attr{Key: "db", Value: slog.GroupValue(attrs...)}, true
+	}
+
+	return "", slog.Attr{}, false
+}
+
+// dbErrorField возвращает значение строкового или числового поля,
+// пустую строку для отсутствующих и нулевых полей
+func dbErrorField(v reflect.Value, name string) string {
+	f := v.FieldByName(name)
+	if !f.IsValid() {
+		return ""
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		return f.String()
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		if f.Uint() == 0 {
+			return ""
+		}
+		return strconv.FormatUint(f.Uint(), 10)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		if f.Int() == 0 {
+			return ""
+		}
+		return strconv.FormatInt(f.Int(), 10)
+	case reflect.Array:
+		// mysql: SQLState [5]byte
+		if f.Type().Elem().Kind() != reflect.Uint8 {
+			return ""
+		}
+		b := make([]byte, f.Len())
+		for i := range b {
+			b[i] = byte(f.Index(i).Uint())
+		}
+		if b[0] == 0 {
+			return ""
+		}
+		return string(b)
+	}
+
+	return ""
+}
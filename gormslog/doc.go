@@ -0,0 +1,8 @@
+// Package gormslog — логер gorm и Plugin поверх обработчиков пакета
+// github.com/bairto15/slog_gorm_color. Отдельный модуль: приложения,
+// которым нужен только обработчик slog, не зависят от gorm.io/gorm.
+//
+//	logger.InitDevLogger(logger.Options{W: os.Stdout})
+//	db, _ := gorm.Open(dialector, &gorm.Config{Logger: gormslog.NewGormLogger(false, nil)})
+//	db.Use(gormslog.NewPlugin(gormslog.PluginOptions{}))
+package gormslog
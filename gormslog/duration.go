@@ -0,0 +1,27 @@
+package gormslog
+
+import (
+	"context"
+	"time"
+)
+
+// Порог неправдоподобной длительности запроса по умолчанию, см. GormOptions.MaxDuration
+const defaultMaxQueryDuration = 24 * time.Hour
+
+type queryDurationKey struct{}
+
+// WithQueryDuration передает в Trace длительность запроса, измеренную
+// вызывающим кодом. Нужна, когда begin не содержит показаний монотонных
+// часов (восстановлен из JSON, обрезан Round/Truncate) и time.Since(begin)
+// искажается переводом системных часов.
+func WithQueryDuration(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, queryDurationKey{}, d)
+}
+
+// queryDuration возвращает длительность запроса из WithQueryDuration или time.Since(begin)
+func queryDuration(ctx context.Context, begin time.Time) time.Duration {
+	if d, ok := ctx.Value(queryDurationKey{}).(time.Duration); ok {
+		return d
+	}
+	return time.Since(begin)
+}
@@ -0,0 +1,14 @@
+module github.com/bairto15/slog_gorm_color/gormslog
+
+go 1.24.0
+
+require (
+	github.com/bairto15/slog_gorm_color v0.0.0-20261017051127-18db24c6ebf6
+	gorm.io/gorm v1.31.1
+)
+
+require (
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	golang.org/x/text v0.20.0 // indirect
+)
@@ -0,0 +1,456 @@
+package gormslog
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"path"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+	"github.com/bairto15/slog_gorm_color/internal/core"
+	"gorm.io/gorm/logger"
+)
+
+// Источник записей gorm, вызванных без кода приложения в стеке: пинги
+// соединений, закрытие подготовленных выражений в фоновых горутинах
+const GormInternal = "gorm-internal"
+
+// Префикс функций этого пакета: обертки пула и колбэки не считаются источником
+var pkgPrefix = reflect.TypeOf(gormLogger{}).PkgPath() + "."
+
+type gormLogger struct {
+	logger.Config
+	attr    []slog.Attr
+	secrets []*regexp.Regexp
+	slow    slogcolor.SlowConfig
+
+	warnZeroRows  bool
+	zeroRowsAllow map[string]struct{}
+
+	recentQueries int
+	requestIDKey  string
+
+	maxDuration time.Duration
+	// Предупреждение CodeBadDuration выводится один раз, общий для сессий LogMode
+	badDuration *atomic.Bool
+
+	levelVar *slog.LevelVar
+	// Уровень задан LogMode для сессии (db.Debug()) и важнее levelVar
+	sessionLevel bool
+
+	disableSource bool
+}
+
+// Настройки gorm логера
+type GormOptions struct {
+	// Выводить SQL с подставленными параметрами
+	ShowParams bool
+	// Атрибуты, добавляемые к каждому SQL запросу
+	Attrs []slog.Attr
+	// Шаблоны секретов, которые маскируются в строковых литералах SQL
+	// и в тексте ошибок запросов
+	SecretPatterns []*regexp.Regexp
+	// Запросы дольше порога пишутся с уровнем Warn. 0 — без предупреждений
+	SlowThreshold time.Duration
+	// Пороги для отдельных классов запросов, см. slogcolor.SlowRule
+	SlowRules []slogcolor.SlowRule
+	// Общие с dev обработчиком пороги, важнее SlowThreshold и SlowRules
+	Slow *slogcolor.SlowConfig
+	// Предупреждать об UPDATE/DELETE, не изменивших ни одной строки
+	WarnZeroRows bool
+	// Отпечатки запросов (slogcolor.SQLFingerprint), для которых 0 строк — ожидаемый результат
+	ZeroRowsAllow []string
+	// Количество последних запросов, которые хранятся для каждого request ID,
+	// см. slogcolor.GetRecentQueries. 0 — история не ведется
+	RecentQueries int
+	// Ключ контекста с request ID, по умолчанию "request_id"
+	RequestIDKey string
+	// Длительность запроса больше порога считается ошибкой измерения,
+	// см. slogcolor.CodeBadDuration. По умолчанию 24 часа
+	MaxDuration time.Duration
+	// Общий с slog уровень записей gorm. Уровень сессии из LogMode
+	// (db.Debug() задает logger.Info) важнее LevelVar: такие записи
+	// пишутся в обработчик без проверки Enabled. nil — уровни gorm
+	// не учитываются, фильтрует только обработчик slog
+	LevelVar *slog.LevelVar
+	// Не определять место вызова gorm в коде приложения (runtime.Callers
+	// на каждый запрос). Источник не определяется и без этой настройки,
+	// если обработчик slog.Default создан с slogcolor.Options.DisableSource
+	DisableSource bool
+}
+
+func NewGormLogger(showParams bool, attr []slog.Attr) logger.Interface {
+	return NewGormLoggerOptions(GormOptions{ShowParams: showParams, Attrs: attr})
+}
+
+func NewGormLoggerOptions(opt GormOptions) logger.Interface {
+	slow := slogcolor.SlowConfig{Threshold: opt.SlowThreshold, Rules: opt.SlowRules}
+	if opt.Slow != nil {
+		slow = *opt.Slow
+	}
+
+	l := &gormLogger{
+		Config:  logger.Config{LogLevel: logger.Info, SlowThreshold: slow.Threshold},
+		attr:    opt.Attrs,
+		secrets: opt.SecretPatterns,
+		slow:    slow,
+
+		warnZeroRows: opt.WarnZeroRows,
+
+		recentQueries: opt.RecentQueries,
+		requestIDKey:  cmp.Or(opt.RequestIDKey, "request_id"),
+
+		maxDuration: cmp.Or(opt.MaxDuration, defaultMaxQueryDuration),
+		badDuration: new(atomic.Bool),
+
+		levelVar: opt.LevelVar,
+
+		disableSource: opt.DisableSource,
+	}
+
+	if opt.LevelVar != nil {
+		l.LogLevel = GormLevel(opt.LevelVar.Level())
+	}
+
+	if len(opt.ZeroRowsAllow) > 0 {
+		l.zeroRowsAllow = make(map[string]struct{}, len(opt.ZeroRowsAllow))
+		for _, fp := range opt.ZeroRowsAllow {
+			l.zeroRowsAllow[fp] = struct{}{}
+		}
+	}
+
+	if opt.ShowParams {
+		return l
+	}
+
+	return &withOutParams{gormLogger: l}
+}
+
+// logDiagnostic пишет служебную запись о неправильном использовании логера
+func (g *gormLogger) logDiagnostic(err *slogcolor.DiagnosticError, attrs ...slog.Attr) {
+	r := slog.NewRecord(time.Now(), slog.LevelWarn, err.Error(), 0)
+	r.AddAttrs(slog.String(slogcolor.DiagnosticCodeKey, err.Code))
+	r.AddAttrs(attrs...)
+
+	h := slog.Default().Handler()
+	if h.Enabled(context.Background(), r.Level) {
+		_ = h.Handle(context.Background(), r)
+	}
+}
+
+// Имплементация интерфейса gorm логера
+func (g *gormLogger) LogMode(logLevel logger.LogLevel) logger.Interface {
+	newLogger := *g
+	newLogger.LogLevel = logLevel
+	newLogger.sessionLevel = true
+	return &newLogger
+}
+
+func (g *gormLogger) Info(ctx context.Context, msg string, data ...any) {
+	g.log(g.gormContext(ctx), slog.LevelInfo, msg, core.ArgsToAttrs(data)...)
+}
+
+func (g *gormLogger) Warn(ctx context.Context, msg string, data ...any) {
+	g.log(g.gormContext(ctx), slog.LevelWarn, msg, core.ArgsToAttrs(data)...)
+}
+
+func (g *gormLogger) Error(ctx context.Context, msg string, data ...any) {
+	g.log(g.gormContext(ctx), slog.LevelError, msg, core.ArgsToAttrs(data)...)
+}
+
+// log пишет запись с учетом GormOptions.LevelVar и уровня сессии LogMode
+func (g *gormLogger) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	ctx = core.WithLoggerName(ctx, slogcolor.LoggerGorm)
+
+	if g.levelVar == nil {
+		core.LogAttrs(ctx, level, msg, attrs...)
+		return
+	}
+
+	if !g.sessionLevel {
+		if level >= g.levelVar.Level() {
+			core.LogAttrs(ctx, level, msg, attrs...)
+		}
+		return
+	}
+
+	if level < SlogLevel(g.LogLevel) {
+		return
+	}
+
+	// уровень сессии уже проверен: Enabled обработчика с тем же LevelVar
+	// не должен отбросить записи db.Debug()
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.AddAttrs(attrs...)
+	_ = slog.Default().Handler().Handle(ctx, r)
+}
+
+// gormContext дополняет контекст источником вызова из кода приложения,
+// а для вызовов из фоновых горутин gorm — меткой GormInternal.
+// gorm может передать nil контекст, он заменяется на context.Background().
+func (g *gormLogger) gormContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if !g.captureSource() {
+		return ctx
+	}
+
+	if _, ok := ctx.Value(slogcolor.Source).(slog.Source); ok {
+		return ctx
+	}
+
+	funcName, file, line := getGormFuncName()
+
+	return context.WithValue(ctx, slogcolor.Source, slog.Source{Function: funcName, File: file, Line: line})
+}
+
+func (g *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if core.Silenced(ctx, true) {
+		// запрос без записи все равно учитывается счетчиком slogcolor.WithSQLCounter
+		core.CountSQL(ctx, max(queryDuration(ctx, begin), 0))
+		return
+	}
+
+	sql, rows := fc()
+
+	attrs := g.attr
+	secretMasked := false
+	if len(g.secrets) > 0 {
+		full := sql
+		if sql, secretMasked = core.MaskSecrets(sql, g.secrets); secretMasked {
+			// хэш исходного запроса: замаскированный SQL с ним не совпадает
+			ctx = context.WithValue(ctx, slogcolor.SqlHash, core.SQLHash(full))
+		}
+	}
+
+	// текст ошибки драйвера часто содержит значения параметров
+	var (
+		errMsg  string
+		errAttr slog.Attr
+		dbErr   bool
+	)
+	if err != nil {
+		errMsg = err.Error()
+		if m, attr, ok := dbError(err); ok {
+			errMsg, errAttr, dbErr = m, attr, true
+		}
+		if len(g.secrets) > 0 {
+			var masked bool
+			errMsg, masked = core.MaskText(errMsg, g.secrets)
+			secretMasked = secretMasked || masked
+			if dbErr {
+				errAttr, masked = g.maskAttr(errAttr)
+				secretMasked = secretMasked || masked
+			}
+		}
+	}
+	if secretMasked {
+		attrs = append(slices.Clip(attrs), slog.Bool(slogcolor.SecretMasked, true))
+	}
+
+	for _, k := range [...]string{slogcolor.Preload, slogcolor.Association} {
+		if v, ok := ctx.Value(k).(string); ok {
+			attrs = append(slices.Clip(attrs), slog.String(k, v))
+		}
+	}
+
+	if attr, ok := lockWaitAttr(ctx); ok {
+		attrs = append(slices.Clip(attrs), attr)
+	}
+
+	batch, inBatch := batchAttr(ctx)
+	if inBatch {
+		attrs = append(slices.Clip(attrs), batch)
+	}
+
+	if sa := stmtAttrs(ctx); sa != nil {
+		attrs = append(slices.Clip(attrs), sa...)
+	}
+
+	if attr, ok := clausesAttrs(ctx); ok {
+		attrs = append(slices.Clip(attrs), attr)
+	}
+
+	ctx = context.WithValue(ctx, slogcolor.Sql, sql)
+	ctx = context.WithValue(ctx, slogcolor.Rows, rows)
+
+	duration := queryDuration(ctx, begin)
+	if duration < 0 || duration > g.maxDuration {
+		if g.badDuration.CompareAndSwap(false, true) {
+			g.logDiagnostic(slogcolor.ErrBadDuration, slog.Duration(slogcolor.Duration, duration), slog.Time("begin", begin))
+		}
+		duration = max(duration, 0)
+	}
+	ctx = context.WithValue(ctx, slogcolor.Duration, duration)
+	core.CountSQL(ctx, duration)
+
+	var (
+		file string
+		line int
+	)
+	if g.captureSource() {
+		var funcName string
+		funcName, file, line = getGormFuncName()
+
+		source := slog.Source{
+			Function: funcName,
+			File:     file,
+			Line:     line,
+		}
+
+		ctx = context.WithValue(ctx, slogcolor.Source, source)
+	}
+
+	if g.recentQueries > 0 {
+		g.recordRecent(ctx, begin, sql, rows, duration, file, line, err)
+	}
+
+	if err != nil {
+		if dbErr {
+			attrs = append(slices.Clip(attrs), errAttr)
+		}
+
+		// ошибку пачки CreateInBatches сводит итоговая запись
+		level := slog.LevelError
+		if inBatch {
+			level = slog.LevelDebug
+		}
+
+		g.log(ctx, level, errMsg, attrs...)
+		return
+	}
+
+	if g.warnZeroRows && rows == 0 && g.zeroRowsUnexpected(sql) {
+		g.log(ctx, slog.LevelWarn, "no rows affected", attrs...)
+		return
+	}
+
+	if threshold := g.slow.For(sql); threshold > 0 && duration > threshold {
+		attrs = append(slices.Clip(attrs), slog.Duration("slow_threshold", threshold))
+		g.log(ctx, slog.LevelWarn, "slow query", attrs...)
+		return
+	}
+
+	g.log(ctx, slog.LevelInfo, "", attrs...)
+}
+
+type withOutParams struct {
+	*gormLogger
+}
+
+// LogMode сохраняет скрытие параметров в сессиях db.Debug()
+func (g *withOutParams) LogMode(logLevel logger.LogLevel) logger.Interface {
+	return &withOutParams{gormLogger: g.gormLogger.LogMode(logLevel).(*gormLogger)}
+}
+
+func (g *withOutParams) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return sql, nil
+}
+
+// maskAttr маскирует секреты в строковых значениях группы db ошибки драйвера
+func (g *gormLogger) maskAttr(a slog.Attr) (slog.Attr, bool) {
+	group := a.Value.Group()
+	attrs := make([]slog.Attr, len(group))
+	masked := false
+	for i, ga := range group {
+		if ga.Value.Kind() == slog.KindString {
+			if s, ok := core.MaskText(ga.Value.String(), g.secrets); ok {
+				ga.Value = slog.StringValue(s)
+				masked = true
+			}
+		}
+		attrs[i] = ga
+	}
+	if !masked {
+		return a, false
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}, true
+}
+
+// recordRecent добавляет запрос в историю request ID из контекста
+func (g *gormLogger) recordRecent(ctx context.Context, begin time.Time, sql string, rows int64, duration time.Duration, file string, line int, err error) {
+	id := core.RequestID(ctx.Value(g.requestIDKey))
+	if id == "" {
+		return
+	}
+
+	q := slogcolor.QueryInfo{SQL: sql, Rows: rows, Duration: duration, Time: begin}
+	if err != nil {
+		q.Error, _ = core.MaskText(err.Error(), g.secrets)
+	}
+	if file != "" {
+		q.Source = core.TrimPath(file) + ":" + strconv.Itoa(line)
+	}
+
+	core.AddRecentQuery(id, g.recentQueries, q)
+}
+
+// zeroRowsUnexpected сообщает, что запрос — UPDATE или DELETE не из списка исключений
+func (g *gormLogger) zeroRowsUnexpected(sql string) bool {
+	verb := core.SQLVerb(sql)
+	if verb != "UPDATE" && verb != "DELETE" {
+		return false
+	}
+
+	if g.zeroRowsAllow != nil {
+		if _, ok := g.zeroRowsAllow[slogcolor.SQLFingerprint(sql)]; ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func getGormFuncName() (funcName string, file string, line int) {
+	pcs := [32]uintptr{}
+
+	length := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:length])
+
+	for i := 0; i < length; i++ {
+		frame, _ := frames.Next()
+
+		if isInternalFrame(frame) {
+			continue
+		}
+
+		if (!strings.Contains(frame.Function, "gorm.io/gorm") || strings.HasSuffix(frame.File, "_test.go")) && !strings.HasSuffix(frame.File, ".gen.go") {
+			funcName = strings.Replace(path.Ext(frame.Function), ".", "", 1)
+
+			file = frame.File
+			line = frame.Line
+
+			return
+		}
+	}
+
+	return GormInternal, "", 0
+}
+
+// captureSource сообщает, нужно ли определять место вызова gorm
+func (g *gormLogger) captureSource() bool {
+	return !g.disableSource && core.SourceCapture(slog.Default().Handler())
+}
+
+// isInternalFrame — кадр рантайма или этого пакета (кроме тестов)
+func isInternalFrame(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+
+	return strings.HasPrefix(frame.Function, "runtime.") || strings.HasPrefix(frame.Function, pkgPrefix)
+}
@@ -0,0 +1,47 @@
+package gormslog
+
+import (
+	"log/slog"
+	"math"
+
+	"gorm.io/gorm/logger"
+)
+
+// LevelSilent — уровень slog для logger.Silent: выше любого уровня записей
+const LevelSilent = slog.Level(math.MaxInt)
+
+// SlogLevel возвращает уровень slog, начиная с которого пишутся записи gorm
+// с уровнем l. Уровни gorm упорядочены наоборот: чем больше, тем подробнее
+// (Silent < Error < Warn < Info), поэтому сравнивать их числа с уровнями
+// slog нельзя:
+//
+//	logger.Info  → slog.LevelInfo
+//	logger.Warn  → slog.LevelWarn
+//	logger.Error → slog.LevelError
+//	logger.Silent → LevelSilent
+func SlogLevel(l logger.LogLevel) slog.Level {
+	switch {
+	case l >= logger.Info:
+		return slog.LevelInfo
+	case l == logger.Warn:
+		return slog.LevelWarn
+	case l == logger.Error:
+		return slog.LevelError
+	}
+	return LevelSilent
+}
+
+// GormLevel — обратное к SlogLevel преобразование: уровень gorm, при котором
+// пишутся записи slog с уровнем l и выше. Debug записи gorm не пишет,
+// поэтому уровни ниже Info соответствуют logger.Info
+func GormLevel(l slog.Level) logger.LogLevel {
+	switch {
+	case l <= slog.LevelInfo:
+		return logger.Info
+	case l <= slog.LevelWarn:
+		return logger.Warn
+	case l <= slog.LevelError:
+		return logger.Error
+	}
+	return logger.Silent
+}
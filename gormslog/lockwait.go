@@ -0,0 +1,229 @@
+package gormslog
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+	"gorm.io/gorm"
+)
+
+// LockWaitProbe получает время ожидания блокировок запроса: Start
+// вызывается плагином перед запросом, возвращенная функция — сразу после
+// него, в том числе при ошибке запроса
+type LockWaitProbe interface {
+	Start(db *gorm.DB) (finish func() (time.Duration, bool))
+}
+
+// LockWaitFunc — проба, которой достаточно вызова после запроса
+type LockWaitFunc func(db *gorm.DB) (time.Duration, bool)
+
+func (f LockWaitFunc) Start(db *gorm.DB) func() (time.Duration, bool) {
+	return func() (time.Duration, bool) {
+		if db.Error != nil {
+			return 0, false
+		}
+		return f(db)
+	}
+}
+
+// WithLockWait сохраняет в контексте время ожидания блокировок, если драйвер
+// или приложение получают его самостоятельно
+func WithLockWait(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, slogcolor.LockWait, d)
+}
+
+// MySQLLockWait читает LOCK_TIME последнего запроса текущего соединения из
+// performance_schema (MySQL 8.0.16+). Работает только внутри транзакции,
+// иначе нет гарантии, что запрос выполнялся на том же соединении.
+var MySQLLockWait LockWaitProbe = LockWaitFunc(mysqlLockWait)
+
+func mysqlLockWait(db *gorm.DB) (time.Duration, bool) {
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); !ok {
+		return 0, false
+	}
+
+	row := probeConn(db.Statement.ConnPool).QueryRowContext(db.Statement.Context,
+		"SELECT LOCK_TIME FROM performance_schema.events_statements_history "+
+			"WHERE THREAD_ID = PS_CURRENT_THREAD_ID() AND SQL_TEXT NOT LIKE '%performance_schema%' "+
+			"ORDER BY EVENT_ID DESC LIMIT 1")
+
+	// LOCK_TIME хранится в пикосекундах
+	var ps int64
+	if err := row.Scan(&ps); err != nil {
+		return 0, false
+	}
+
+	return time.Duration(ps / 1000), true
+}
+
+// Интервал опроса pg_locks по умолчанию
+const defaultPostgresLockWaitInterval = 20 * time.Millisecond
+
+// PostgresLockWait — проба PostgreSQL 14+: пока запрос транзакции
+// выполняется, отдельное соединение пула раз в Interval читает из pg_locks
+// время ожидания неполученной блокировки (waitstart) процессом транзакции.
+// Запросы быстрее Interval не опрашиваются, точность — Interval.
+// Работает только внутри транзакции: процесс сервера узнается запросом
+// pg_backend_pid() один раз на транзакцию, если SlowTransaction включен,
+// иначе перед каждым запросом. Дополняет log_lock_waits: сервер пишет
+// ожидания дольше deadlock_timeout, проба — время ожидания каждого запроса
+//
+//	db.Use(gormslog.NewPlugin(gormslog.PluginOptions{LockWait: gormslog.PostgresLockWait{}}))
+type PostgresLockWait struct {
+	// Интервал опроса, по умолчанию 20ms
+	Interval time.Duration
+}
+
+func (p PostgresLockWait) Start(db *gorm.DB) func() (time.Duration, bool) {
+	pid, ok := postgresBackendPID(db)
+	if !ok {
+		return func() (time.Duration, bool) { return 0, false }
+	}
+	pool, ok := rootDB(db)
+	if !ok {
+		return func() (time.Duration, bool) { return 0, false }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan time.Duration, 1)
+	go func() {
+		result <- pollLockWait(ctx, pool, pid, cmp.Or(p.Interval, defaultPostgresLockWaitInterval))
+	}()
+
+	return func() (time.Duration, bool) {
+		cancel()
+		return <-result, true
+	}
+}
+
+// pollLockWait суммирует ожидания блокировок процесса pid до отмены ctx.
+// Время ожидания считает сервер: часы клиента и сервера могут расходиться
+func pollLockWait(ctx context.Context, pool *sql.DB, pid int64, interval time.Duration) time.Duration {
+	var (
+		total time.Duration
+		// текущее ожидание на момент последнего опроса
+		current  time.Duration
+		observed time.Time
+	)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if current > 0 {
+				// ожидание могло продолжаться до конца запроса
+				total += current + time.Since(observed)
+			}
+			return total
+		case <-t.C:
+		}
+
+		var sec sql.NullFloat64
+		err := pool.QueryRowContext(ctx,
+			"SELECT EXTRACT(EPOCH FROM clock_timestamp() - min(waitstart))::float8 FROM pg_locks WHERE pid = $1 AND NOT granted",
+			pid).Scan(&sec)
+		if err != nil {
+			continue
+		}
+
+		wait := time.Duration(sec.Float64 * float64(time.Second))
+		if !sec.Valid || wait < current {
+			// блокировка получена, или между опросами началось новое ожидание
+			total += current
+			current = 0
+		}
+		if sec.Valid {
+			current, observed = wait, time.Now()
+		}
+	}
+}
+
+// postgresBackendPID возвращает процесс сервера транзакции запроса
+func postgresBackendPID(db *gorm.DB) (int64, bool) {
+	pool := db.Statement.ConnPool
+	if _, ok := pool.(gorm.TxCommitter); !ok {
+		return 0, false
+	}
+
+	t, tracked := pool.(*trackedTx)
+	if tracked {
+		if pid := t.backendPID.Load(); pid != 0 {
+			return pid, true
+		}
+	}
+
+	var pid int64
+	if err := probeConn(pool).QueryRowContext(db.Statement.Context, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		return 0, false
+	}
+
+	if tracked {
+		t.backendPID.Store(pid)
+	}
+	return pid, true
+}
+
+// rootDB возвращает пул соединений db для запросов вне транзакции
+func rootDB(db *gorm.DB) (*sql.DB, bool) {
+	switch pool := db.ConnPool.(type) {
+	case *sql.DB:
+		return pool, true
+	case gorm.GetDBConnector:
+		sqlDB, err := pool.GetDBConn()
+		return sqlDB, err == nil
+	}
+	return nil, false
+}
+
+// probeConn возвращает соединение для служебных запросов проб: мимо
+// счетчика запросов транзакции trackedTx
+func probeConn(pool gorm.ConnPool) gorm.ConnPool {
+	if t, ok := pool.(*trackedTx); ok {
+		return t.Tx
+	}
+	return pool
+}
+
+const lockWaitFinishKey = "slog:lock_wait_finish"
+
+func lockWaitStart(probe LockWaitProbe) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			return
+		}
+		// повторный вызов возвращает тот же результат, опрос уже остановлен
+		db.InstanceSet(lockWaitFinishKey, sync.OnceValues(probe.Start(db)))
+	}
+}
+
+func lockWaitFinish(db *gorm.DB) {
+	v, ok := db.InstanceGet(lockWaitFinishKey)
+	if !ok {
+		return
+	}
+	finish, _ := v.(func() (time.Duration, bool))
+	if finish == nil {
+		return
+	}
+
+	// вызывается и при ошибке: проба останавливает опрос
+	d, ok := finish()
+	if ok && db.Error == nil {
+		db.Statement.Context = WithLockWait(db.Statement.Context, d)
+	}
+}
+
+func lockWaitAttr(ctx context.Context) (slog.Attr, bool) {
+	d, ok := ctx.Value(slogcolor.LockWait).(time.Duration)
+	if !ok {
+		return slog.Attr{}, false
+	}
+	return slog.Float64(slogcolor.LockWait, float64(d.Microseconds())/1000), true
+}
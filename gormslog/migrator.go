@@ -0,0 +1,216 @@
+package gormslog
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// Migrator оборачивает gorm.Migrator и логирует каждую операцию с таблицами,
+// колонками и индексами с длительностью и счетчиком прогресса:
+//
+//	[4/17] create index users.idx_users_email duration=120ms
+//
+// Операции внутри AutoMigrate (создание таблиц, добавление и изменение
+// колонок, индексы, ограничения) логируются по отдельности: на время
+// миграции диалект db подменяется оберткой, которая возвращает Migrator.
+//
+// total — ожидаемое количество операций; 0 — AutoMigrate считает операции
+// пробным прогоном перед миграцией: запросы к схеме выполняются, операции
+// только считаются.
+type Migrator struct {
+	gorm.Migrator
+	db       *gorm.DB
+	progress *migrationProgress
+}
+
+// migrationProgress — счетчик операций, общий для Migrator и оберток
+// внутри AutoMigrate
+type migrationProgress struct {
+	mu    sync.Mutex
+	step  int
+	total int
+	// total считается пробным прогоном AutoMigrate
+	plan bool
+	// пробный прогон: операции считаются, но не выполняются
+	counting bool
+}
+
+func NewMigrator(db *gorm.DB, total int) *Migrator {
+	return newMigrator(db, &migrationProgress{total: total, plan: total == 0})
+}
+
+// newMigrator возвращает Migrator, чей db использует migratorDialector:
+// вложенные вызовы db.Migrator() внутри gorm тоже логируются
+func newMigrator(db *gorm.DB, p *migrationProgress) *Migrator {
+	tx := db.Session(&gorm.Session{})
+	if d, ok := tx.Dialector.(migratorDialector); ok {
+		tx.Dialector = d.Dialector
+	}
+	tx.Dialector = migratorDialector{Dialector: tx.Dialector, progress: p}
+
+	return tx.Migrator().(*Migrator)
+}
+
+// migratorDialector возвращает Migrator вместо мигратора диалекта
+type migratorDialector struct {
+	gorm.Dialector
+	progress *migrationProgress
+}
+
+func (d migratorDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return &Migrator{Migrator: d.Dialector.Migrator(db), db: db, progress: d.progress}
+}
+
+// BuildIndexOptions нужен CreateTable мигратора gorm
+func (m *Migrator) BuildIndexOptions(opts []schema.IndexOption, stmt *gorm.Statement) []any {
+	if b, ok := m.Migrator.(migrator.BuildIndexOptionsInterface); ok {
+		return b.BuildIndexOptions(opts, stmt)
+	}
+	return nil
+}
+
+func (m *Migrator) AutoMigrate(dst ...any) error {
+	if m.progress.plan && !m.progress.counting {
+		if n, err := m.count(dst); err == nil {
+			m.progress.mu.Lock()
+			m.progress.total = m.progress.step + n
+			m.progress.mu.Unlock()
+		}
+	}
+
+	return m.Migrator.AutoMigrate(dst...)
+}
+
+// count возвращает число операций AutoMigrate для dst: запросы к схеме
+// выполняются без логирования, операции — нет
+func (m *Migrator) count(dst []any) (int, error) {
+	p := &migrationProgress{counting: true}
+	tx := m.db.Session(&gorm.Session{Logger: logger.Discard})
+
+	err := newMigrator(tx, p).Migrator.AutoMigrate(dst...)
+	return p.step, err
+}
+
+func (m *Migrator) CreateTable(dst ...any) error {
+	for _, d := range dst {
+		if err := m.run("create table "+m.table(d), func() error { return m.Migrator.CreateTable(d) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) DropTable(dst ...any) error {
+	for _, d := range dst {
+		if err := m.run("drop table "+m.table(d), func() error { return m.Migrator.DropTable(d) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) RenameTable(oldName, newName any) error {
+	return m.run("rename table "+m.table(oldName)+" to "+m.table(newName), func() error {
+		return m.Migrator.RenameTable(oldName, newName)
+	})
+}
+
+func (m *Migrator) AddColumn(dst any, field string) error {
+	return m.run("add column "+m.table(dst)+"."+field, func() error { return m.Migrator.AddColumn(dst, field) })
+}
+
+func (m *Migrator) DropColumn(dst any, field string) error {
+	return m.run("drop column "+m.table(dst)+"."+field, func() error { return m.Migrator.DropColumn(dst, field) })
+}
+
+func (m *Migrator) AlterColumn(dst any, field string) error {
+	return m.run("alter column "+m.table(dst)+"."+field, func() error { return m.Migrator.AlterColumn(dst, field) })
+}
+
+func (m *Migrator) RenameColumn(dst any, oldName, field string) error {
+	return m.run("rename column "+m.table(dst)+"."+oldName+" to "+field, func() error {
+		return m.Migrator.RenameColumn(dst, oldName, field)
+	})
+}
+
+func (m *Migrator) CreateView(name string, option gorm.ViewOption) error {
+	return m.run("create view "+name, func() error { return m.Migrator.CreateView(name, option) })
+}
+
+func (m *Migrator) DropView(name string) error {
+	return m.run("drop view "+name, func() error { return m.Migrator.DropView(name) })
+}
+
+func (m *Migrator) CreateConstraint(dst any, name string) error {
+	return m.run("create constraint "+m.table(dst)+"."+name, func() error { return m.Migrator.CreateConstraint(dst, name) })
+}
+
+func (m *Migrator) DropConstraint(dst any, name string) error {
+	return m.run("drop constraint "+m.table(dst)+"."+name, func() error { return m.Migrator.DropConstraint(dst, name) })
+}
+
+func (m *Migrator) CreateIndex(dst any, name string) error {
+	return m.run("create index "+m.table(dst)+"."+name, func() error { return m.Migrator.CreateIndex(dst, name) })
+}
+
+func (m *Migrator) DropIndex(dst any, name string) error {
+	return m.run("drop index "+m.table(dst)+"."+name, func() error { return m.Migrator.DropIndex(dst, name) })
+}
+
+func (m *Migrator) RenameIndex(dst any, oldName, newName string) error {
+	return m.run("rename index "+m.table(dst)+"."+oldName+" to "+newName, func() error {
+		return m.Migrator.RenameIndex(dst, oldName, newName)
+	})
+}
+
+func (m *Migrator) run(op string, fn func() error) error {
+	m.progress.mu.Lock()
+	m.progress.step++
+	progress := "[" + strconv.Itoa(m.progress.step)
+	if m.progress.total > 0 {
+		progress += "/" + strconv.Itoa(max(m.progress.total, m.progress.step))
+	}
+	counting := m.progress.counting
+	m.progress.mu.Unlock()
+
+	if counting {
+		return nil
+	}
+
+	msg := progress + "] " + op
+
+	begin := time.Now()
+	err := fn()
+	attrs := []slog.Attr{slog.Duration(slogcolor.Duration, time.Since(begin))}
+
+	ctx := m.db.Statement.Context
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, msg, append(attrs, slogcolor.Err(err))...)
+		return err
+	}
+
+	slog.LogAttrs(ctx, slog.LevelInfo, msg, attrs...)
+	return nil
+}
+
+func (m *Migrator) table(dst any) string {
+	if name, ok := dst.(string); ok {
+		return name
+	}
+
+	stmt := &gorm.Statement{DB: m.db}
+	if err := stmt.Parse(dst); err == nil && stmt.Schema != nil {
+		return stmt.Schema.Table
+	}
+
+	return fmt.Sprintf("%T", dst)
+}
@@ -0,0 +1,309 @@
+package gormslog
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Plugin — плагин gorm, который через колбэки дополняет контекст запроса
+// сведениями для логера (путь preload и ассоциаций).
+//
+//	db.Use(gormslog.NewPlugin(gormslog.PluginOptions{}))
+type Plugin struct {
+	opt PluginOptions
+}
+
+// Настройки плагина
+type PluginOptions struct {
+	// Порог длительности транзакции от BEGIN до COMMIT/ROLLBACK,
+	// после которого пишется предупреждение. 0 — не отслеживать.
+	SlowTransaction time.Duration
+	// Получение времени ожидания блокировок для атрибута lock_wait_ms:
+	// MySQLLockWait, PostgresLockWait или своя проба
+	LockWait LockWaitProbe
+	// Ключи контекста, значения которых добавляются комментарием в начало
+	// каждого запроса (/* request_id=abc123 */ SELECT ...), чтобы запросы из
+	// pg_stat_statements и логов сервера можно было связать с логами приложения
+	CorrelationKeys []string
+
+	// Выводить для сессий с PrepareStmt номер текста запроса (sql_seq=12) и
+	// число его повторных выполнений (sql_repeat). Оба считает логер по
+	// тексту запроса, а не драйвер: номер не совпадает с именем выражения
+	// в базе
+	PreparedStmts bool
+	// Имя выражения, под которым его подготавливает драйвер, например
+	// PgxStmtName: выводится вместо sql_seq атрибутом stmt. Драйвер с кэшем
+	// выражений готовит все запросы, поэтому имя выводится для всех сессий
+	StmtNamer StmtNamer
+	// Размер кэша выражений для подсчета повторов, по умолчанию 256
+	StmtCacheSize int
+
+	// Отладка построения запроса: атрибут clauses с предложениями запроса
+	// и каждым условием WHERE отдельно — "WHERE[tenant_id = ?; deleted_at IS NULL] LIMIT".
+	// Показывает, откуда взялось неожиданное условие: из Scopes, Where
+	// или мягкого удаления. Значения условий не выводятся
+	Clauses bool
+}
+
+func NewPlugin(opt PluginOptions) *Plugin {
+	return &Plugin{opt: opt}
+}
+
+func (p *Plugin) Name() string {
+	return "slog_gorm_color"
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	if p.opt.SlowTransaction > 0 {
+		p.wrapConnPool(db)
+	}
+
+	cb := db.Callback()
+
+	if err := cb.Query().Before("gorm:query").Register("slog:relation_path", relationPath(slogcolor.Preload)); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:preload").Register("slog:preload_parent", markRelationParent(slogcolor.Preload)); err != nil {
+		return err
+	}
+
+	if err := cb.Create().Before("gorm:save_before_associations").Register("slog:association_parent", markRelationParent(slogcolor.Association)); err != nil {
+		return err
+	}
+	if err := cb.Create().Before("gorm:create").Register("slog:relation_path", relationPath(slogcolor.Association)); err != nil {
+		return err
+	}
+
+	if err := cb.Update().Before("gorm:save_before_associations").Register("slog:association_parent", markRelationParent(slogcolor.Association)); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("slog:relation_path", relationPath(slogcolor.Association)); err != nil {
+		return err
+	}
+
+	if p.opt.LockWait != nil {
+		if err := p.registerLockWait(db); err != nil {
+			return err
+		}
+	}
+
+	if len(p.opt.CorrelationKeys) > 0 {
+		if err := p.registerCorrelation(db); err != nil {
+			return err
+		}
+	}
+
+	if p.opt.Clauses {
+		if err := p.registerClauses(db); err != nil {
+			return err
+		}
+	}
+
+	if p.opt.PreparedStmts || p.opt.StmtNamer != nil {
+		if err := p.registerStmtNames(db); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Plugin) registerCorrelation(db *gorm.DB) error {
+	cb := db.Callback()
+	keys := p.opt.CorrelationKeys
+
+	if err := cb.Query().Before("gorm:query").Register("slog:correlation", correlationCallback(keys, "SELECT")); err != nil {
+		return err
+	}
+	if err := cb.Create().Before("gorm:create").Register("slog:correlation", correlationCallback(keys, "INSERT")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("slog:correlation", correlationCallback(keys, "UPDATE")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("slog:correlation", correlationCallback(keys, "DELETE")); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("slog:correlation", correlationCallback(keys, "SELECT")); err != nil {
+		return err
+	}
+	return cb.Raw().Before("gorm:raw").Register("slog:correlation", correlationCallback(keys, ""))
+}
+
+func (p *Plugin) registerStmtNames(db *gorm.DB) error {
+	cb := db.Callback()
+	fn := stmtCallback(newStmtCache(p.opt.StmtCacheSize, p.opt.StmtNamer), p.opt.StmtNamer != nil)
+
+	if err := cb.Query().After("gorm:query").Register("slog:stmt", fn); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("slog:stmt", fn); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("slog:stmt", fn); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("slog:stmt", fn); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("slog:stmt", fn); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("slog:stmt", fn)
+}
+
+func (p *Plugin) registerLockWait(db *gorm.DB) error {
+	cb := db.Callback()
+	start := lockWaitStart(p.opt.LockWait)
+
+	if err := cb.Query().Before("gorm:query").Register("slog:lock_wait_start", start); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("slog:lock_wait", lockWaitFinish); err != nil {
+		return err
+	}
+	if err := cb.Create().Before("gorm:create").Register("slog:lock_wait_start", start); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("slog:lock_wait", lockWaitFinish); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("slog:lock_wait_start", start); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("slog:lock_wait", lockWaitFinish); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("slog:lock_wait_start", start); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("slog:lock_wait", lockWaitFinish); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("slog:lock_wait_start", start); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("slog:lock_wait", lockWaitFinish); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("slog:lock_wait_start", start); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("slog:lock_wait", lockWaitFinish)
+}
+
+// wrapConnPool подменяет пул соединений на отслеживающий транзакции.
+// Для PreparedStmtDB оборачивается вложенный пул.
+func (p *Plugin) wrapConnPool(db *gorm.DB) {
+	wrap := func(pool gorm.ConnPool) gorm.ConnPool {
+		if _, ok := pool.(*trackingPool); ok {
+			return pool
+		}
+		return &trackingPool{ConnPool: pool, slowTx: p.opt.SlowTransaction}
+	}
+
+	if ps, ok := db.ConnPool.(*gorm.PreparedStmtDB); ok {
+		ps.ConnPool = wrap(ps.ConnPool)
+	} else {
+		db.ConnPool = wrap(db.ConnPool)
+	}
+
+	db.Statement.ConnPool = db.ConnPool
+}
+
+type relationParentKey struct{ kind string }
+
+// relationParent описывает запрос, который порождает дочерние запросы preload/ассоциаций
+type relationParent struct {
+	stmt   *gorm.Statement
+	schema *schema.Schema
+	path   string
+	names  []string
+}
+
+func markRelationParent(kind string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		if db.Error != nil || stmt.Schema == nil {
+			return
+		}
+
+		var names []string
+		if kind == slogcolor.Preload {
+			if len(stmt.Preloads) == 0 {
+				return
+			}
+			for name := range stmt.Preloads {
+				first, _, _ := strings.Cut(name, ".")
+				names = append(names, first)
+			}
+		}
+
+		path, _ := stmt.Context.Value(kind).(string)
+
+		stmt.Context = context.WithValue(stmt.Context, relationParentKey{kind}, &relationParent{
+			stmt:   stmt,
+			schema: stmt.Schema,
+			path:   path,
+			names:  names,
+		})
+	}
+}
+
+func relationPath(kind string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		parent, ok := stmt.Context.Value(relationParentKey{kind}).(*relationParent)
+		if !ok || parent.stmt == stmt || stmt.Schema == nil {
+			return
+		}
+
+		name := relationName(parent, stmt.Schema)
+		if name == "" {
+			return
+		}
+		if parent.path != "" {
+			name = parent.path + "." + name
+		}
+
+		stmt.Context = context.WithValue(stmt.Context, kind, name)
+	}
+}
+
+// relationName ищет связь родительской схемы, ведущую к схеме дочернего запроса.
+// Если таких связей несколько, предпочитается указанная в Preload.
+func relationName(parent *relationParent, child *schema.Schema) string {
+	var found []string
+
+	for name, rel := range parent.schema.Relationships.Relations {
+		switch {
+		case rel.FieldSchema == child:
+			found = append(found, name)
+		case rel.JoinTable != nil && rel.JoinTable == child:
+			found = append(found, name+"(join)")
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return ""
+	case 1:
+		return found[0]
+	}
+
+	slices.Sort(found)
+	for _, name := range found {
+		if slices.Contains(parent.names, name) || slices.Contains(parent.names, clause.Associations) {
+			return name
+		}
+	}
+
+	return found[0]
+}
@@ -0,0 +1,160 @@
+package gormslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const sqlCommenterClause = "SQLCOMMENTER"
+
+// SQLCommenter — плагин gorm, добавляющий в конец запросов комментарий по
+// спецификации sqlcommenter (https://google.github.io/sqlcommenter/spec/):
+//
+//	SELECT * FROM users /*controller='users',route='%2Fapi%2Fusers',traceparent='00-...-01'*/
+//
+// Значения берутся из тех же значений контекста, что выводит логер
+// (ctx.Value по строковому ключу), и из атрибутов PushAttrs.
+type SQLCommenter struct {
+	tags map[string]string
+	keys []string
+}
+
+// Соответствие тегов комментария ключам контекста по умолчанию
+var DefaultSQLCommenterTags = map[string]string{
+	"controller":  "controller",
+	"route":       "route",
+	"traceparent": "traceparent",
+}
+
+// NewSQLCommenter создает плагин. tags — соответствие тегов комментария
+// ключам контекста; nil — DefaultSQLCommenterTags.
+func NewSQLCommenter(tags map[string]string) *SQLCommenter {
+	if tags == nil {
+		tags = DefaultSQLCommenterTags
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return &SQLCommenter{tags: tags, keys: keys}
+}
+
+func (c *SQLCommenter) Name() string {
+	return "slog_gorm_color:sqlcommenter"
+}
+
+func (c *SQLCommenter) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	if err := cb.Query().Before("gorm:query").Register("slog:sqlcommenter", c.callback); err != nil {
+		return err
+	}
+	if err := cb.Create().Before("gorm:create").Register("slog:sqlcommenter", c.callback); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("slog:sqlcommenter", c.callback); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("slog:sqlcommenter", c.callback); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("slog:sqlcommenter", c.callback); err != nil {
+		return err
+	}
+	return cb.Raw().Before("gorm:raw").Register("slog:sqlcommenter", c.callback)
+}
+
+// comment собирает комментарий: ключи отсортированы, значения URL-кодированы
+// и заключены в одинарные кавычки
+func (c *SQLCommenter) comment(ctx context.Context) string {
+	var scope []slog.Attr
+	var b strings.Builder
+
+	for _, tag := range c.keys {
+		key := c.tags[tag]
+
+		v := ctx.Value(key)
+		if v == nil {
+			if scope == nil {
+				scope = core.ScopeAttrs(ctx)
+			}
+			// внутренний уровень PushAttrs перекрывает внешние
+			for _, a := range scope {
+				if a.Key == key {
+					v = a.Value.Resolve().Any()
+				}
+			}
+		}
+		if v == nil {
+			continue
+		}
+
+		if b.Len() == 0 {
+			b.WriteString("/*")
+		} else {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(sqlCommenterEscape(tag))
+		b.WriteString("='")
+		b.WriteString(sqlCommenterEscape(fmt.Sprint(v)))
+		b.WriteByte('\'')
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	b.WriteString("*/")
+	return b.String()
+}
+
+func (c *SQLCommenter) callback(db *gorm.DB) {
+	stmt := db.Statement
+	delete(stmt.Clauses, sqlCommenterClause)
+
+	comment := c.comment(stmt.Context)
+	if comment == "" {
+		return
+	}
+
+	// Raw/Exec: SQL уже собран
+	if stmt.SQL.Len() > 0 {
+		sql := stmt.SQL.String()
+		if strings.Contains(sql, "/*") || strings.Contains(sql, "--") {
+			return
+		}
+
+		trimmed := strings.TrimRight(sql, " ;\n\t")
+		stmt.SQL.Reset()
+		stmt.SQL.WriteString(trimmed)
+		stmt.SQL.WriteByte(' ')
+		stmt.SQL.WriteString(comment)
+		stmt.SQL.WriteString(sql[len(trimmed):])
+		return
+	}
+
+	if len(stmt.BuildClauses) == 0 {
+		return
+	}
+
+	stmt.Clauses[sqlCommenterClause] = clause.Clause{Expression: correlationComment(comment)}
+	stmt.BuildClauses = append(slices.Clip(stmt.BuildClauses), sqlCommenterClause)
+}
+
+// sqlCommenterEscape кодирует ключ или значение; кавычки и '/' тоже кодируются,
+// поэтому комментарий не может закончиться раньше времени
+func sqlCommenterEscape(s string) string {
+	return url.PathEscape(s)
+}
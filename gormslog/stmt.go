@@ -0,0 +1,140 @@
+package gormslog
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Счетчики текстов запросов ведет сам логер, а не драйвер или
+// gorm.PreparedStmtDB: номер и повторы считаются по тексту запроса в кэше
+// логера и могут расходиться с кэшем выражений драйвера
+const (
+	// Имя выражения в драйвере, вычисленное StmtNamer
+	Stmt = "stmt"
+	// Порядковый номер текста запроса в кэше логера, если StmtNamer не задан
+	SqlSeq = "sql_seq"
+	// Число повторных выполнений того же текста запроса
+	SqlRepeat = "sql_repeat"
+)
+
+// Размер кэша подготовленных выражений по умолчанию
+const defaultStmtCacheSize = 256
+
+// StmtNamer возвращает имя, под которым драйвер подготавливает выражение
+type StmtNamer func(sql string) string
+
+// PgxStmtName — имя выражения в кэше pgx v5 (QueryExecModeCacheStatement,
+// режим по умолчанию): stmtcache_ и sha256 текста запроса
+func PgxStmtName(sql string) string {
+	digest := sha256.Sum256([]byte(sql))
+	return "stmtcache_" + hex.EncodeToString(digest[:24])
+}
+
+type stmtKey struct{}
+
+type stmtInfo struct {
+	name   string
+	seq    int
+	repeat int
+}
+
+// stmtCache — LRU кэш текстов запросов с номером или именем выражения и
+// числом повторных выполнений. Повторы считаются, пока запрос в кэше: при
+// вытеснении текст получает новый номер, а счетчик начинается заново.
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	namer StmtNamer
+	seq   int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type stmtEntry struct {
+	sql  string
+	info stmtInfo
+}
+
+func newStmtCache(size int, namer StmtNamer) *stmtCache {
+	if size <= 0 {
+		size = defaultStmtCacheSize
+	}
+
+	return &stmtCache{
+		size:  size,
+		namer: namer,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *stmtCache) use(sql string) stmtInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[sql]; ok {
+		c.order.MoveToFront(el)
+		e := el.Value.(*stmtEntry)
+		e.info.repeat++
+		return e.info
+	}
+
+	var info stmtInfo
+	if c.namer != nil {
+		info.name = c.namer(sql)
+	} else {
+		c.seq++
+		info.seq = c.seq
+	}
+
+	e := &stmtEntry{sql: sql, info: info}
+	c.items[sql] = c.order.PushFront(e)
+
+	if c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.items, last.Value.(*stmtEntry).sql)
+	}
+
+	return e.info
+}
+
+// usesPreparedStmt — сессия gorm с PrepareStmt
+func usesPreparedStmt(db *gorm.DB) bool {
+	switch db.Statement.ConnPool.(type) {
+	case *gorm.PreparedStmtDB, *gorm.PreparedStmtTX:
+		return true
+	}
+	return false
+}
+
+func stmtCallback(cache *stmtCache, allSessions bool) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.DryRun || db.Statement.SQL.Len() == 0 {
+			return
+		}
+		if !allSessions && !usesPreparedStmt(db) {
+			return
+		}
+
+		info := cache.use(db.Statement.SQL.String())
+		db.Statement.Context = context.WithValue(db.Statement.Context, stmtKey{}, info)
+	}
+}
+
+func stmtAttrs(ctx context.Context) []slog.Attr {
+	info, ok := ctx.Value(stmtKey{}).(stmtInfo)
+	if !ok {
+		return nil
+	}
+	if info.name != "" {
+		return []slog.Attr{slog.String(Stmt, info.name), slog.Int(SqlRepeat, info.repeat)}
+	}
+	return []slog.Attr{slog.Int(SqlSeq, info.seq), slog.Int(SqlRepeat, info.repeat)}
+}
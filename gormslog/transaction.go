@@ -0,0 +1,136 @@
+package gormslog
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+	"gorm.io/gorm"
+)
+
+// trackingPool оборачивает пул соединений gorm, чтобы отслеживать транзакции
+type trackingPool struct {
+	gorm.ConnPool
+	slowTx time.Duration
+}
+
+func (p *trackingPool) BeginTx(ctx context.Context, opt *sql.TxOptions) (gorm.ConnPool, error) {
+	var tx gorm.Tx
+
+	switch beginner := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		sqlTx, err := beginner.BeginTx(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		tx = sqlTx
+	case gorm.ConnPoolBeginner:
+		connPool, err := beginner.BeginTx(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		t, ok := connPool.(gorm.Tx)
+		if !ok {
+			return connPool, nil
+		}
+		tx = t
+	default:
+		return nil, gorm.ErrInvalidTransaction
+	}
+
+	funcName, file, line := getGormFuncName()
+	ctx = context.WithValue(ctx, slogcolor.Source, slog.Source{Function: funcName, File: file, Line: line})
+
+	return &trackedTx{Tx: tx, ctx: ctx, slow: p.slowTx, begin: time.Now()}, nil
+}
+
+func (p *trackingPool) GetDBConn() (*sql.DB, error) {
+	if sqldb, ok := p.ConnPool.(*sql.DB); ok {
+		return sqldb, nil
+	}
+
+	if connector, ok := p.ConnPool.(gorm.GetDBConnector); ok {
+		return connector.GetDBConn()
+	}
+
+	return nil, gorm.ErrInvalidDB
+}
+
+// trackedTx считает запросы внутри транзакции и предупреждает о долгих транзакциях
+type trackedTx struct {
+	gorm.Tx
+
+	ctx   context.Context
+	slow  time.Duration
+	begin time.Time
+
+	mu         sync.Mutex
+	statements int
+	longest    time.Duration
+	longestSql string
+
+	// Процесс сервера для PostgresLockWait, 0 — еще не запрошен
+	backendPID atomic.Int64
+}
+
+func (t *trackedTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	defer t.observe(query, time.Now())
+	return t.Tx.ExecContext(ctx, query, args...)
+}
+
+func (t *trackedTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	defer t.observe(query, time.Now())
+	return t.Tx.QueryContext(ctx, query, args...)
+}
+
+func (t *trackedTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	defer t.observe(query, time.Now())
+	return t.Tx.QueryRowContext(ctx, query, args...)
+}
+
+func (t *trackedTx) Commit() error {
+	err := t.Tx.Commit()
+	t.finish("commit")
+	return err
+}
+
+func (t *trackedTx) Rollback() error {
+	err := t.Tx.Rollback()
+	t.finish("rollback")
+	return err
+}
+
+func (t *trackedTx) observe(query string, start time.Time) {
+	d := time.Since(start)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.statements++
+	if d > t.longest {
+		t.longest = d
+		t.longestSql = query
+	}
+}
+
+func (t *trackedTx) finish(result string) {
+	elapsed := time.Since(t.begin)
+	if elapsed <= t.slow {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	slog.LogAttrs(t.ctx, slog.LevelWarn, "slow transaction",
+		slog.Duration(slogcolor.Duration, elapsed),
+		slog.String("result", result),
+		slog.Int("statements", t.statements),
+		slog.Duration("longest_duration", t.longest),
+		slog.String("longest_sql", t.longestSql),
+	)
+}
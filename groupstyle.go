@@ -0,0 +1,14 @@
+package logger
+
+// GroupStyle — вид групп slog (WithGroup, slog.Group) в терминале
+type GroupStyle int
+
+const (
+	// Имя группы — префикс ключей: req.user.id=1 req.user.name=bob
+	GroupDots GroupStyle = iota
+	// Группа — блок в фигурных скобках: req={user={id=1 name=bob}}
+	GroupBrackets
+	// Имя группы — отдельная строка, атрибуты группы — строками с отступом
+	// на уровень глубже. Включает Options.Multiline
+	GroupIndent
+)
@@ -0,0 +1,238 @@
+package logger
+
+import (
+	"context"
+	"html"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// HTMLStyle — CSS классов, которыми RenderHTML заменяет ANSI-коды
+const HTMLStyle = `.b{font-weight:bold}.f{opacity:.6}.u{text-decoration:underline}
+.fg-30{color:#000}.fg-31{color:#cd3131}.fg-32{color:#0dbc79}.fg-33{color:#e5e510}
+.fg-34{color:#2472c8}.fg-35{color:#bc3fbc}.fg-36{color:#11a8cd}.fg-37{color:#e5e5e5}
+.fg-90{color:#767676}.fg-91{color:#f14c4c}.fg-92{color:#23d18b}.fg-93{color:#f5f543}
+.fg-94{color:#3b8eea}.fg-95{color:#d670d6}.fg-96{color:#29b8db}.fg-97{color:#fff}
+.bg-40{background:#000}.bg-41{background:#cd3131}.bg-42{background:#0dbc79}.bg-43{background:#e5e510;color:#000}
+.bg-44{background:#2472c8}.bg-45{background:#bc3fbc}.bg-46{background:#11a8cd}.bg-47{background:#e5e5e5;color:#000}
+`
+
+// RenderHTML возвращает запись в том же виде, что RenderRecord, но цвета
+// заданы элементами span с классами из HTMLStyle, а текст экранирован
+func RenderHTML(ctx context.Context, r slog.Record, opts Options) (string, error) {
+	s, err := RenderRecord(ctx, r, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return ansiToHTML(s), nil
+}
+
+// sgrState — текущие атрибуты текста по последовательностям SGR
+type sgrState struct {
+	bold, faint, underline bool
+	fg, bg                 string
+	// цвета 256/RGB задаются стилем, а не классом
+	fgStyle, bgStyle string
+}
+
+func (s sgrState) zero() bool {
+	return s == sgrState{}
+}
+
+func (s sgrState) open(b *strings.Builder) {
+	var classes []string
+	if s.bold {
+		classes = append(classes, "b")
+	}
+	if s.faint {
+		classes = append(classes, "f")
+	}
+	if s.underline {
+		classes = append(classes, "u")
+	}
+	if s.fg != "" {
+		classes = append(classes, "fg-"+s.fg)
+	}
+	if s.bg != "" {
+		classes = append(classes, "bg-"+s.bg)
+	}
+
+	b.WriteString("<span")
+	if len(classes) > 0 {
+		b.WriteString(` class="`)
+		b.WriteString(strings.Join(classes, " "))
+		b.WriteByte('"')
+	}
+	if s.fgStyle != "" || s.bgStyle != "" {
+		b.WriteString(` style="`)
+		if s.fgStyle != "" {
+			b.WriteString("color:" + s.fgStyle + ";")
+		}
+		if s.bgStyle != "" {
+			b.WriteString("background:" + s.bgStyle + ";")
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('>')
+}
+
+// apply применяет параметры одной последовательности ESC[...m
+func (s *sgrState) apply(params string) {
+	codes := strings.Split(params, ";")
+
+	for i := 0; i < len(codes); i++ {
+		n, err := strconv.Atoi(codes[i])
+		if err != nil && codes[i] != "" {
+			continue
+		}
+
+		switch {
+		case n == 0:
+			*s = sgrState{}
+		case n == 1:
+			s.bold = true
+		case n == 2:
+			s.faint = true
+		case n == 4:
+			s.underline = true
+		case n == 22:
+			s.bold, s.faint = false, false
+		case n == 24:
+			s.underline = false
+		case n >= 30 && n <= 37 || n >= 90 && n <= 97:
+			s.fg, s.fgStyle = codes[i], ""
+		case n == 39:
+			s.fg, s.fgStyle = "", ""
+		case n >= 40 && n <= 47 || n >= 100 && n <= 107:
+			s.bg, s.bgStyle = codes[i], ""
+		case n == 49:
+			s.bg, s.bgStyle = "", ""
+		case n == 38 || n == 48:
+			color, used := extendedColor(codes[i+1:])
+			i += used
+			if color == "" {
+				continue
+			}
+			if n == 38 {
+				s.fg, s.fgStyle = "", color
+			} else {
+				s.bg, s.bgStyle = "", color
+			}
+		}
+	}
+}
+
+// extendedColor разбирает 5;n (палитра 256) и 2;r;g;b (RGB) после 38/48
+func extendedColor(codes []string) (string, int) {
+	if len(codes) >= 2 && codes[0] == "5" {
+		n, err := strconv.Atoi(codes[1])
+		if err != nil || n < 0 || n > 255 {
+			return "", 2
+		}
+		r, g, b := color256RGB(n)
+		return rgbHex(r, g, b), 2
+	}
+
+	if len(codes) >= 4 && codes[0] == "2" {
+		var rgb [3]int
+		for j := range rgb {
+			v, err := strconv.Atoi(codes[j+1])
+			if err != nil || v < 0 || v > 255 {
+				return "", 4
+			}
+			rgb[j] = v
+		}
+		return rgbHex(rgb[0], rgb[1], rgb[2]), 4
+	}
+
+	return "", len(codes)
+}
+
+// color256RGB возвращает RGB цвета палитры xterm-256
+func color256RGB(n int) (int, int, int) {
+	switch {
+	case n < 16:
+		basic := [16][3]int{
+			{0, 0, 0}, {205, 49, 49}, {13, 188, 121}, {229, 229, 16},
+			{36, 114, 200}, {188, 63, 188}, {17, 168, 205}, {229, 229, 229},
+			{118, 118, 118}, {241, 76, 76}, {35, 209, 139}, {245, 245, 67},
+			{59, 142, 234}, {214, 112, 214}, {41, 184, 219}, {255, 255, 255},
+		}
+		c := basic[n]
+		return c[0], c[1], c[2]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return level(n / 36), level(n / 6 % 6), level(n % 6)
+	default:
+		v := 8 + (n-232)*10
+		return v, v, v
+	}
+}
+
+func rgbHex(r, g, b int) string {
+	const hex = "0123456789abcdef"
+	return string([]byte{'#', hex[r>>4], hex[r&15], hex[g>>4], hex[g&15], hex[b>>4], hex[b&15]})
+}
+
+// ansiToHTML заменяет последовательности SGR на span, экранируя текст.
+// Остальные управляющие последовательности отбрасываются.
+func ansiToHTML(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) * 2)
+
+	var state sgrState
+	open := false
+
+	for len(s) > 0 {
+		i := strings.IndexByte(s, byte(ansiEsc))
+		if i < 0 {
+			b.WriteString(html.EscapeString(s))
+			break
+		}
+
+		b.WriteString(html.EscapeString(s[:i]))
+		s = s[i+1:]
+
+		if !strings.HasPrefix(s, "[") {
+			continue
+		}
+
+		// конец последовательности — первая буква
+		end := strings.IndexFunc(s[1:], func(r rune) bool {
+			return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		})
+		if end < 0 {
+			break
+		}
+		end++
+
+		if s[end] == 'm' {
+			state.apply(s[1:end])
+
+			if open {
+				b.WriteString("</span>")
+				open = false
+			}
+			if !state.zero() {
+				state.open(&b)
+				open = true
+			}
+		}
+
+		s = s[end+1:]
+	}
+
+	if open {
+		b.WriteString("</span>")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// Фоны из палитры 256 цветов, различимые между собой и читаемые с черным текстом
+var hueBackgrounds = [...]uint8{203, 209, 215, 221, 185, 149, 113, 79, 80, 81, 75, 111, 147, 183, 177, 213}
+
+// Ширина метки Options.HueKey в колонках
+const hueBadgeWidth = 10
+
+// hueValue возвращает значение ключа Options.HueKey из контекста или
+// атрибутов PushAttrs (внутренняя область важнее)
+func hueValue(ctx context.Context, key string) (string, bool) {
+	if v := ctx.Value(key); v != nil {
+		return fmt.Sprint(v), true
+	}
+
+	attrs := core.ScopeAttrs(ctx)
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i].Key == key {
+			return attrs[i].Value.Resolve().String(), true
+		}
+	}
+
+	return "", false
+}
+
+// hueColor выбирает фон по значению: одно значение — всегда один цвет
+func hueColor(value string) uint8 {
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	return hueBackgrounds[h.Sum32()%uint32(len(hueBackgrounds))]
+}
+
+// appendHueBadge выводит метку со значением HueKey на фоне его цвета
+func (h *handlerTextColor) appendHueBadge(ctx context.Context, buf *buffer) {
+	value, ok := hueValue(ctx, h.hueKey)
+	if !ok {
+		return
+	}
+
+	value = TruncateWidth(sanitizeUTF8(value, h.invalidUTF8), hueBadgeWidth)
+
+	if h.theme.reset == "" {
+		buf.WriteString("[" + value + "] ")
+		return
+	}
+
+	*buf = Style{Fg: ColorBlack, Bg: Color256(hueColor(value))}.AppendANSI(*buf)
+	buf.WriteByte(' ')
+	buf.WriteString(value)
+	buf.WriteByte(' ')
+	buf.WriteString(h.theme.reset)
+	buf.WriteByte(' ')
+}
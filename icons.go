@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// IconMode — вывод уровня записи символом, см. Options.Icons
+type IconMode int
+
+const (
+	// Уровень выводится текстом
+	IconsOff IconMode = iota
+	// Символ перед названием уровня: "✔ INFO"
+	IconsPrefix
+	// Только символ вместо названия уровня
+	IconsOnly
+)
+
+// levelIcon возвращает символ уровня
+func levelIcon(level slog.Level) string {
+	switch {
+	case level == slog.LevelInfo:
+		return "✔"
+	case level == slog.LevelWarn:
+		return "⚠"
+	case level >= slog.LevelError:
+		return "✖"
+	}
+	return "●"
+}
+
+// iconMode возвращает Options.Icons или IconsOff, если вывод не в терминал
+// с кодировкой UTF-8: символы там превратятся в мусор
+func iconMode(opt Options) IconMode {
+	if opt.Icons == IconsOff || opt.assumeTerminal {
+		return opt.Icons
+	}
+
+	if !writerIsTerminal(opt.W) || !utf8Locale() {
+		return IconsOff
+	}
+
+	return opt.Icons
+}
+
+// utf8Locale сообщает, что кодировка терминала по переменным локали — UTF-8.
+// Первая непустая из LC_ALL, LC_CTYPE, LANG определяет кодировку
+func utf8Locale() bool {
+	for _, name := range [...]string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
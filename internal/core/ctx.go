@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+type loggerNameKey struct{}
+
+// WithLoggerName задает имя логера записей с контекстом, см. logger.LevelRoute
+func WithLoggerName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, loggerNameKey{}, name)
+}
+
+// LoggerName возвращает имя логера из WithLoggerName
+func LoggerName(ctx context.Context) (string, bool) {
+	n, ok := ctx.Value(loggerNameKey{}).(string)
+	return n, ok
+}
+
+type silenceKey struct{}
+
+// Что подавляет logger.Silence/logger.SilenceSQL
+type SilenceMode int
+
+const (
+	SilenceSQL SilenceMode = iota + 1
+	SilenceAll
+)
+
+func WithSilence(ctx context.Context, mode SilenceMode) context.Context {
+	return context.WithValue(ctx, silenceKey{}, mode)
+}
+
+// Silenced сообщает, что записи с контекстом подавлены. sql — запись SQL запроса
+func Silenced(ctx context.Context, sql bool) bool {
+	switch ctx.Value(silenceKey{}) {
+	case SilenceAll:
+		return true
+	case SilenceSQL:
+		return sql
+	}
+	return false
+}
+
+type attrScopeKey struct{}
+
+// attrScope — уровень вложенности атрибутов контекста (запрос → обработчик → репозиторий)
+type attrScope struct {
+	parent *attrScope
+	attrs  []slog.Attr
+}
+
+// PushAttrs добавляет в контекст новый уровень атрибутов
+func PushAttrs(ctx context.Context, attrs []slog.Attr) context.Context {
+	parent, _ := ctx.Value(attrScopeKey{}).(*attrScope)
+	return context.WithValue(ctx, attrScopeKey{}, &attrScope{parent: parent, attrs: attrs})
+}
+
+// PopAttrs возвращает контекст без последнего уровня атрибутов
+func PopAttrs(ctx context.Context) context.Context {
+	s, _ := ctx.Value(attrScopeKey{}).(*attrScope)
+	if s == nil {
+		return ctx
+	}
+
+	if s.parent == nil {
+		// nil без типа: типизированный nil *attrScope прошел бы проверку
+		// типа в следующем PopAttrs
+		return context.WithValue(ctx, attrScopeKey{}, nil)
+	}
+	return context.WithValue(ctx, attrScopeKey{}, s.parent)
+}
+
+// ScopeAttrs возвращает атрибуты всех уровней от внешнего к внутреннему
+func ScopeAttrs(ctx context.Context) []slog.Attr {
+	s, _ := ctx.Value(attrScopeKey{}).(*attrScope)
+	if s == nil {
+		return nil
+	}
+
+	var levels []*attrScope
+	n := 0
+	for ; s != nil; s = s.parent {
+		levels = append(levels, s)
+		n += len(s.attrs)
+	}
+
+	attrs := make([]slog.Attr, 0, n)
+	for i := len(levels) - 1; i >= 0; i-- {
+		attrs = append(attrs, levels[i].attrs...)
+	}
+
+	return attrs
+}
+
+// ArgsToAttrs разбирает аргументы как slog.Info: пары ключ-значение или slog.Attr
+func ArgsToAttrs(args []any) []slog.Attr {
+	if len(args) == 0 {
+		return nil
+	}
+
+	var r slog.Record
+	r.Add(args...)
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	return attrs
+}
+
+type sqlCounterKey struct{}
+
+// sqlCounter считает запросы gorm логера в контексте. Запрос учитывается
+// и во всех внешних счетчиках: запросы участка Span входят в счетчик запроса
+type sqlCounter struct {
+	count  atomic.Int64
+	nanos  atomic.Int64
+	parent *sqlCounter
+}
+
+// WithSQLCounter добавляет в контекст счетчик SQL запросов
+func WithSQLCounter(ctx context.Context) context.Context {
+	parent, _ := ctx.Value(sqlCounterKey{}).(*sqlCounter)
+	return context.WithValue(ctx, sqlCounterKey{}, &sqlCounter{parent: parent})
+}
+
+// SQLCount возвращает число запросов и их общее время, false — если
+// счетчика в контексте нет
+func SQLCount(ctx context.Context) (int, time.Duration, bool) {
+	c, ok := ctx.Value(sqlCounterKey{}).(*sqlCounter)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(c.count.Load()), time.Duration(c.nanos.Load()), true
+}
+
+// CountSQL учитывает запрос длительностью d во всех счетчиках контекста
+func CountSQL(ctx context.Context, d time.Duration) {
+	c, _ := ctx.Value(sqlCounterKey{}).(*sqlCounter)
+	for ; c != nil; c = c.parent {
+		c.count.Add(1)
+		c.nanos.Add(int64(d))
+	}
+}
@@ -0,0 +1,5 @@
+// Package core — общее состояние пакета logger и адаптера gormslog: ключи
+// контекста, счетчики и история SQL запросов, разбор текста SQL. Не входит
+// в публичный API: адаптер находится в отдельном модуле и не может вызвать
+// неэкспортированные функции logger.
+package core
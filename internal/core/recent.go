@@ -0,0 +1,106 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Количество запросов (request ID), для которых хранится история SQL;
+// при переполнении забываются самые старые
+const maxRecentRequests = 1024
+
+// QueryInfo — выполненный SQL запрос из истории запроса приложения, см. logger.QueryInfo
+type QueryInfo struct {
+	SQL      string
+	Rows     int64
+	Duration time.Duration
+	Time     time.Time
+	Error    string
+	Source   string
+}
+
+// queryRing — кольцевой буфер последних запросов одного request ID
+type queryRing struct {
+	items []QueryInfo
+	next  int
+	full  bool
+}
+
+func (r *queryRing) add(q QueryInfo) {
+	r.items[r.next] = q
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *queryRing) list() []QueryInfo {
+	if !r.full {
+		return append([]QueryInfo(nil), r.items[:r.next]...)
+	}
+
+	out := make([]QueryInfo, 0, len(r.items))
+	out = append(out, r.items[r.next:]...)
+	return append(out, r.items[:r.next]...)
+}
+
+// recentStore хранит кольцевые буферы по request ID
+type recentStore struct {
+	mu    sync.Mutex
+	rings map[string]*queryRing
+	order []string
+}
+
+var recentQueries = &recentStore{rings: make(map[string]*queryRing)}
+
+func (s *recentStore) add(requestID string, size int, q QueryInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.rings[requestID]
+	if !ok {
+		if len(s.order) >= maxRecentRequests {
+			delete(s.rings, s.order[0])
+			s.order = s.order[1:]
+		}
+
+		r = &queryRing{items: make([]QueryInfo, size)}
+		s.rings[requestID] = r
+		s.order = append(s.order, requestID)
+	}
+
+	r.add(q)
+}
+
+// AddRecentQuery добавляет запрос в историю requestID из size последних запросов
+func AddRecentQuery(requestID string, size int, q QueryInfo) {
+	recentQueries.add(requestID, size, q)
+}
+
+// RecentQueries возвращает историю requestID от старых запросов к новым
+func RecentQueries(requestID string) []QueryInfo {
+	recentQueries.mu.Lock()
+	defer recentQueries.mu.Unlock()
+
+	r, ok := recentQueries.rings[requestID]
+	if !ok {
+		return nil
+	}
+
+	return r.list()
+}
+
+// RequestID возвращает значение ключа контекста с request ID строкой
+func RequestID(v any) string {
+	switch id := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return id
+	case fmt.Stringer:
+		return id.String()
+	default:
+		return fmt.Sprint(id)
+	}
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// SourceCapture сообщает, нужен ли обработчику h источник записей: без
+// него не нужно вызывать runtime.Callers. Задается пакетом logger, который
+// знает свои обработчики с Options.DisableSource
+var SourceCapture = func(h slog.Handler) bool { return true }
+
+// LogAttrs пишет запись в slog.Default как slog.LogAttrs, но без
+// runtime.Callers, если обработчику не нужен источник
+func LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	h := slog.Default().Handler()
+	if SourceCapture(h) {
+		slog.LogAttrs(ctx, level, msg, attrs...)
+		return
+	}
+
+	if !h.Enabled(ctx, level) {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.AddAttrs(attrs...)
+	_ = h.Handle(ctx, r)
+}
+
+// TrimPath оставляет от пути к файлу последний каталог и имя: "repo/user.go"
+func TrimPath(file string) string {
+	dir, name := filepath.Split(file)
+	if dir == "" {
+		return name
+	}
+	return path.Join(filepath.Base(dir), name)
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+)
+
+// IsWordByte — символ идентификатора или ключевого слова SQL
+func IsWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// SQLHash возвращает FNV-1a хэш полного SQL: по нему обрезанная запись
+// сопоставляется с запросом в pg_stat_statements или APM
+func SQLHash(sql string) string {
+	h := fnv.New64a()
+	h.Write([]byte(sql))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// SQLVerb возвращает первое ключевое слово запроса в верхнем регистре,
+// пропуская пробелы и комментарии в начале (/* request_id=... */ UPDATE ...)
+func SQLVerb(sql string) string {
+	for {
+		sql = strings.TrimLeft(sql, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(sql, "/*"):
+			end := strings.Index(sql, "*/")
+			if end < 0 {
+				return ""
+			}
+			sql = sql[end+2:]
+		case strings.HasPrefix(sql, "--"):
+			end := strings.IndexByte(sql, '\n')
+			if end < 0 {
+				return ""
+			}
+			sql = sql[end+1:]
+		default:
+			end := 0
+			for end < len(sql) && IsWordByte(sql[end]) {
+				end++
+			}
+			return strings.ToUpper(sql[:end])
+		}
+	}
+}
+
+const secretMask = "***"
+
+// MaskSecrets заменяет совпадения шаблонов внутри строковых литералов SQL.
+// Возвращает true, если что-то было замаскировано.
+func MaskSecrets(sql string, patterns []*regexp.Regexp) (string, bool) {
+	if !strings.ContainsRune(sql, '\'') {
+		return sql, false
+	}
+
+	var b strings.Builder
+	orig, masked := sql, false
+
+	for {
+		start := strings.IndexByte(sql, '\'')
+		if start < 0 {
+			break
+		}
+		end := LiteralEnd(sql, start+1)
+
+		b.WriteString(sql[:start+1])
+
+		lit := sql[start+1 : end]
+		for _, p := range patterns {
+			if p.MatchString(lit) {
+				lit = p.ReplaceAllString(lit, secretMask)
+				masked = true
+			}
+		}
+		b.WriteString(lit)
+
+		sql = sql[end:]
+		if len(sql) == 0 {
+			break
+		}
+		b.WriteByte('\'')
+		sql = sql[1:]
+	}
+	b.WriteString(sql)
+
+	if !masked {
+		return orig, false
+	}
+
+	return b.String(), true
+}
+
+// MaskText заменяет совпадения шаблонов во всем тексте, например в
+// сообщении ошибки драйвера, где значения не заключены в кавычки.
+// Возвращает true, если что-то было замаскировано.
+func MaskText(s string, patterns []*regexp.Regexp) (string, bool) {
+	masked := false
+	for _, p := range patterns {
+		if p.MatchString(s) {
+			s = p.ReplaceAllString(s, secretMask)
+			masked = true
+		}
+	}
+	return s, masked
+}
+
+// LiteralEnd возвращает индекс закрывающей кавычки литерала,
+// учитывая экранирование удвоенной кавычкой.
+func LiteralEnd(sql string, i int) int {
+	for i < len(sql) {
+		if sql[i] == '\'' {
+			if i+1 < len(sql) && sql[i+1] == '\'' {
+				i += 2
+				continue
+			}
+			return i
+		}
+		i++
+	}
+	return len(sql)
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package logger
+
+import "os"
+
+// Управление с клавиатуры поддерживается только в терминалах Unix
+func readKeys(stdin *os.File, onKey func(byte)) (stop func(), ok bool) {
+	return nil, false
+}
@@ -0,0 +1,80 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// readKeys переводит терминал stdin в неканонический режим без эха и
+// передает нажатые клавиши в onKey. stop останавливает чтение и
+// восстанавливает режим терминала. SIGINT и SIGTERM тоже восстанавливают
+// режим и останавливают чтение; сигнал не повторяется: подписчики
+// signal.Notify приложения получают его один раз, а после signal.Stop
+// повторный сигнал обрабатывается по умолчанию. Не включается, если stdin
+// не терминал или процесс в фоновом задании
+func readKeys(stdin *os.File, onKey func(byte)) (stop func(), ok bool) {
+	fd := stdin.Fd()
+
+	var old syscall.Termios
+	if err := tcget(fd, &old); err != nil || !foreground(fd) {
+		return nil, false
+	}
+
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = 1 // чтение ждет не дольше 0.1 с: цикл видит stopped
+	if err := tcset(fd, &raw); err != nil {
+		return nil, false
+	}
+
+	var (
+		stopped atomic.Bool
+		restore sync.Once
+	)
+	done := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	restoreTerminal := func() {
+		restore.Do(func() {
+			signal.Stop(sigs)
+			_ = tcset(fd, &old)
+		})
+	}
+
+	go func() {
+		defer close(done)
+
+		buf := make([]byte, 16)
+		for !stopped.Load() {
+			n, err := syscall.Read(int(fd), buf)
+			if err != nil && err != syscall.EINTR && err != syscall.EAGAIN {
+				return
+			}
+			for _, c := range buf[:max(n, 0)] {
+				onKey(c)
+			}
+		}
+	}()
+
+	go func() {
+		if _, ok := <-sigs; !ok {
+			return
+		}
+		stopped.Store(true)
+		restoreTerminal()
+	}()
+
+	return func() {
+		stopped.Store(true)
+		<-done
+		restoreTerminal()
+		close(sigs)
+	}, true
+}
@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// KeyColor — цвет атрибутов, ключ которых совпадает с Pattern. Pattern —
+// имя ключа или шаблон path.Match ("trace_*"), сравнивается с полным
+// ключом с группами (http.status) и с ключом без групп.
+type KeyColor struct {
+	Pattern string
+	Color   string
+}
+
+// keyColors — правила цвета ключей: точные имена проверяются по map,
+// шаблоны — по порядку объявления
+type keyColors struct {
+	exact map[string]string
+	globs []KeyColor
+}
+
+func newKeyColors(rules []KeyColor, mapCode func(string) string) *keyColors {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	kc := &keyColors{exact: make(map[string]string)}
+	for _, r := range rules {
+		r.Color = mapCode(r.Color)
+		if strings.ContainsAny(r.Pattern, `*?[\`) {
+			kc.globs = append(kc.globs, r)
+		} else if _, ok := kc.exact[r.Pattern]; !ok {
+			kc.exact[r.Pattern] = r.Color
+		}
+	}
+
+	return kc
+}
+
+func (kc *keyColors) match(fullKey, key string) (string, bool) {
+	if kc == nil {
+		return "", false
+	}
+
+	for _, k := range [...]string{fullKey, key} {
+		if c, ok := kc.exact[k]; ok {
+			return c, true
+		}
+	}
+
+	for _, r := range kc.globs {
+		if ok, _ := path.Match(r.Pattern, fullKey); ok {
+			return r.Color, true
+		}
+		if ok, _ := path.Match(r.Pattern, key); ok {
+			return r.Color, true
+		}
+	}
+
+	return "", false
+}
+
+// appendColoredAttr выводит ключ и значение атрибута цветом правила
+func (h *handlerTextColor) appendColoredAttr(buf *buffer, color, key string, v slog.Value) {
+	buf.WriteString(color)
+	appendString(buf, key, false, true)
+	buf.WriteByte('=')
+	h.appendValue(buf, v, true)
+	buf.WriteString(h.theme.reset)
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Переменная окружения с минимальным уровнем для InitLogger и InitDevLogger
+const LevelEnv = "LOG_LEVEL"
+
+// Вывод диагностики настройки, которую некуда вернуть ошибкой
+var stderr io.Writer = os.Stderr
+
+// levelInfo — название и цвет пользовательского уровня
+type levelInfo struct {
+	name  string
+	color string
+}
+
+var (
+	levelsMu sync.Mutex
+	// Копия при каждой регистрации: appendLevel читает без блокировки
+	levels atomic.Pointer[map[slog.Level]levelInfo]
+)
+
+// RegisterLevel задает название и цвет уровня для вывода вместо
+// "DEBUG-4" и "ERROR+4":
+//
+//	const (
+//		LevelTrace = slog.Level(-8)
+//		LevelFatal = slog.Level(12)
+//	)
+//
+//	logger.RegisterLevel(LevelTrace, "TRACE", logger.Style{Fg: logger.ColorBrightBlack}.String())
+//	logger.RegisterLevel(LevelFatal, "FATAL", logger.Style{Fg: logger.ColorBrightWhite, Bg: logger.ColorRed, Bold: true}.String())
+//
+// color — ANSI-код, пусто — цвет ближайшего стандартного уровня из Theme.
+// Название используется и в JSON. Регистрируйте уровни при старте
+// программы, до создания логеров.
+func RegisterLevel(level slog.Level, name string, color string) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+
+	m := make(map[slog.Level]levelInfo)
+	if old := levels.Load(); old != nil {
+		maps.Copy(m, *old)
+	}
+	m[level] = levelInfo{name: name, color: color}
+
+	levels.Store(&m)
+}
+
+// registeredLevel возвращает название и цвет уровня из RegisterLevel
+func registeredLevel(level slog.Level) (levelInfo, bool) {
+	m := levels.Load()
+	if m == nil {
+		return levelInfo{}, false
+	}
+
+	info, ok := (*m)[level]
+	return info, ok
+}
+
+// LevelName возвращает название уровня: зарегистрированное RegisterLevel
+// или level.String()
+func LevelName(level slog.Level) string {
+	if info, ok := registeredLevel(level); ok {
+		return info.name
+	}
+	return level.String()
+}
+
+// ParseLevel разбирает название уровня без учета регистра: зарегистрированное
+// RegisterLevel ("trace") или стандартное в формате slog ("warn", "DEBUG-4",
+// "info+2")
+func ParseLevel(s string) (slog.Level, error) {
+	if m := levels.Load(); m != nil {
+		for level, info := range *m {
+			if strings.EqualFold(info.name, s) {
+				return level, nil
+			}
+		}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("logger: unknown level %q", s)
+	}
+
+	return level, nil
+}
+
+// levelFromEnv задает Options.Level из переменной LevelEnv. Значение
+// переменной важнее уровня в коде; *slog.LevelVar сохраняется, ему
+// присваивается уровень из переменной. Некорректное значение не мешает
+// запуску: остается уровень из кода, в os.Stderr пишется одна строка
+func (o *Options) levelFromEnv() {
+	s := os.Getenv(LevelEnv)
+	if s == "" {
+		return
+	}
+
+	level, err := ParseLevel(s)
+	if err != nil {
+		current := slog.LevelDebug
+		if o.Level != nil {
+			current = o.Level.Level()
+		}
+		fmt.Fprintf(stderr, "logger: %s: %v, using %v\n", LevelEnv, strings.TrimPrefix(err.Error(), "logger: "), current)
+		return
+	}
+
+	if v, ok := o.Level.(*slog.LevelVar); ok {
+		v.Set(level)
+		return
+	}
+
+	o.Level = level
+}
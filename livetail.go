@@ -0,0 +1,247 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Размер очереди строк одного клиента; при отставании клиента строки отбрасываются,
+// чтобы медленный браузер не тормозил логирование
+const liveTailQueue = 256
+
+// LiveTail — обработчик, который передает записи в next и транслирует их
+// в отрисованном виде подключенным браузерам через Server-Sent Events.
+//
+//	tail := logger.NewLiveTail(logger.NewDevHandler(opt), opt)
+//	slog.SetDefault(slog.New(tail))
+//	http.Handle("/logs", tail)
+//
+// Страница /logs показывает записи в реальном времени, фильтры задаются
+// параметрами клиента: level=warn — минимальный уровень, q=text — подстрока,
+// format=html|ansi|plain — HTML с классами HTMLStyle, строки с ANSI-кодами
+// или простой текст (по умолчанию).
+type LiveTail struct {
+	hub  *tailHub
+	h    *handlerTextColor
+	next slog.Handler
+}
+
+type tailHub struct {
+	mu      sync.Mutex
+	clients map[*tailClient]struct{}
+}
+
+type tailClient struct {
+	level  slog.Level
+	filter string
+	format string
+	lines  chan string
+}
+
+// NewLiveTail создает обработчик. next может быть nil — тогда записи
+// только транслируются.
+func NewLiveTail(next slog.Handler, opt Options) *LiveTail {
+	// цвета нужны форматам html и ansi, plain их убирает
+	opt.ForceColor, opt.DisableColor = true, false
+	// записи транслируются без блокировки: время с предыдущей записи не считается
+	opt.TimeMode = TimeClock
+	// отчеты и управление с клавиатуры остаются за основным обработчиком
+	opt.Digest = nil
+	opt.KeyboardControl = false
+
+	return &LiveTail{
+		hub:  &tailHub{clients: make(map[*tailClient]struct{})},
+		h:    NewDevHandler(opt).(*handlerTextColor),
+		next: next,
+	}
+}
+
+func (t *LiveTail) Enabled(ctx context.Context, level slog.Level) bool {
+	return t.next == nil || t.next.Enabled(ctx, level) || t.hub.active()
+}
+
+func (t *LiveTail) Handle(ctx context.Context, r slog.Record) error {
+	if t.hub.active() {
+		buf := newBuffer()
+		t.h.render(ctx, r, buf)
+		t.hub.broadcast(r.Level, strings.TrimSuffix(string(*buf), "\n"))
+		buf.Free()
+	}
+
+	if t.next == nil || !t.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+
+	return t.next.Handle(ctx, r)
+}
+
+func (t *LiveTail) WithAttrs(attrs []slog.Attr) slog.Handler {
+	t2 := *t
+	t2.h = t.h.WithAttrs(attrs).(*handlerTextColor)
+	if t.next != nil {
+		t2.next = t.next.WithAttrs(attrs)
+	}
+	return &t2
+}
+
+func (t *LiveTail) WithGroup(name string) slog.Handler {
+	t2 := *t
+	t2.h = t.h.WithGroup(name).(*handlerTextColor)
+	if t.next != nil {
+		t2.next = t.next.WithGroup(name)
+	}
+	return &t2
+}
+
+func (hub *tailHub) active() bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.clients) > 0
+}
+
+func (hub *tailHub) broadcast(level slog.Level, line string) {
+	plain := stripANSI(line)
+	var html string
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for c := range hub.clients {
+		if level < c.level || c.filter != "" && !strings.Contains(plain, c.filter) {
+			continue
+		}
+
+		msg := plain
+		switch c.format {
+		case "ansi":
+			msg = line
+		case "html":
+			if html == "" {
+				html = ansiToHTML(line)
+			}
+			msg = html
+		}
+
+		select {
+		case c.lines <- msg:
+		default:
+		}
+	}
+}
+
+func (hub *tailHub) add(c *tailClient) {
+	hub.mu.Lock()
+	hub.clients[c] = struct{}{}
+	hub.mu.Unlock()
+}
+
+func (hub *tailHub) remove(c *tailClient) {
+	hub.mu.Lock()
+	delete(hub.clients, c)
+	hub.mu.Unlock()
+}
+
+// ServeHTTP отдает страницу просмотра, а запросам с Accept: text/event-stream
+// или параметром stream — поток записей
+func (t *LiveTail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if !q.Has("stream") && !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, strings.Replace(liveTailPage, "/*style*/", HTMLStyle, 1))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c := &tailClient{
+		level:  slog.LevelDebug,
+		filter: q.Get("q"),
+		format: q.Get("format"),
+		lines:  make(chan string, liveTailQueue),
+	}
+	if lvl := q.Get("level"); lvl != "" {
+		level, err := ParseLevel(lvl)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.level = level
+	}
+
+	t.hub.add(c)
+	defer t.hub.remove(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-c.lines:
+			// многострочная запись (SQL) передается несколькими полями data
+			for _, l := range strings.Split(line, "\n") {
+				fmt.Fprintf(w, "data: %s\n", l)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		}
+	}
+}
+
+const liveTailPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>live tail</title>
+<style>
+body { margin: 0; background: #1e1e1e; color: #d4d4d4; font: 13px monospace; }
+form { position: sticky; top: 0; padding: 6px; background: #333; }
+pre { margin: 0; padding: 6px; white-space: pre-wrap; }
+/*style*/
+</style>
+</head>
+<body>
+<form id="f">
+<select name="level">
+<option value="debug">debug</option>
+<option value="info">info</option>
+<option value="warn">warn</option>
+<option value="error">error</option>
+</select>
+<input name="q" placeholder="filter">
+<button>apply</button>
+</form>
+<pre id="out"></pre>
+<script>
+var out = document.getElementById("out"), form = document.getElementById("f"), es;
+function connect() {
+	if (es) es.close();
+	var p = new URLSearchParams(new FormData(form));
+	p.set("stream", "1");
+	p.set("format", "html");
+	out.textContent = "";
+	es = new EventSource(location.pathname + "?" + p);
+	es.onmessage = function (e) {
+		var atBottom = innerHeight + scrollY >= document.body.scrollHeight - 4;
+		out.insertAdjacentHTML("beforeend", e.data + "\n");
+		if (atBottom) scrollTo(0, document.body.scrollHeight);
+	};
+}
+form.onsubmit = function (e) { e.preventDefault(); connect(); };
+connect();
+</script>
+</body>
+</html>
+`
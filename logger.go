@@ -2,13 +2,14 @@ package logger
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
-	"path"
-	"path/filepath"
-	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -16,86 +17,400 @@ const (
 	Duration = "duration"
 	Rows     = "rows"
 	Sql      = "sql"
+	// Хэш полного SQL, если в записи он обрезан или замаскирован
+	SqlHash = "sql_hash"
+
+	// Путь preload и ассоциаций запроса, см. gormslog.Plugin
+	Preload     = "preload"
+	Association = "association"
+	// Время ожидания блокировок, см. gormslog.PluginOptions.LockWait
+	LockWait = "lock_wait_ms"
+	// Атрибут с условиями и предложениями запроса, см. gormslog.PluginOptions.Clauses
+	Clauses = "clauses"
+	// Число SQL запросов и их общее время, см. WithSQLCounter
+	SqlCount = "sql_count"
+	DbTime   = "db_time"
 )
 
 type HandlerMiddleware struct {
-	source     bool
-	addCxtAttr []string
-	next       slog.Handler
+	level          slog.Leveler
+	source         bool
+	sourceLevel    slog.Leveler
+	sourceResolver SourceResolver
+	sourcePath     SourcePathMode
+	largeRecord    int
+	runtimeStats   bool
+	canonical      int
+	flushLevel     slog.Leveler
+	w              io.Writer
+	addCxtAttr     []string
+	extractors     []CtxExtractor
+	maxSQLLength   int
+	invalidUTF8    UTF8Mode
+	pre            *preprocessor
+	next           slog.Handler
+	// Имя логера и группы WithGroup для LevelRoutes, см. LoggerKey
+	loggerName string
+	groups     []string
+	// Атрибуты WithAttrs после WithGroup: groupAttrs[i] — атрибуты группы
+	// groups[i]. next получает только атрибуты до первой группы, группы
+	// собираются в Handle, чтобы значения контекста не попали в них
+	groupAttrs    [][]slog.Attr
+	injectedGroup string
+
+	// Атрибуты WithAttrs до первой группы при DedupAttrs: next их не
+	// получает, повторы ключей удаляются в Handle
+	dedup bool
+	attrs []slog.Attr
+
+	disableSource bool
+
+	// Пакеты handleBatch пишутся в next по одной записи: блокировка на
+	// запись держится на весь пакет, Handle берет ее на чтение, чтобы
+	// записи других горутин не попали внутрь пакета
+	batchMu *sync.RWMutex
 }
 
 func NewHandlerMiddleware(next slog.Handler, opt Options) *HandlerMiddleware {
-	return &HandlerMiddleware{next: next, source: opt.Source, addCxtAttr: opt.AddCxtAttr}
+	h := &HandlerMiddleware{
+		next:           next,
+		level:          opt.Level,
+		source:         opt.Source && !opt.DisableSource,
+		sourceLevel:    opt.SourceLevel,
+		sourceResolver: opt.SourceResolver,
+		sourcePath:     opt.SourcePath,
+		largeRecord:    opt.LargeRecord,
+		runtimeStats:   opt.RuntimeStats,
+		canonical:      canonicalPrecision(opt),
+		flushLevel:     opt.FlushLevel,
+		w:              opt.W,
+		addCxtAttr:     opt.AddCxtAttr,
+		extractors:     opt.CtxExtractors,
+		maxSQLLength:   opt.MaxSQLLength,
+		invalidUTF8:    opt.InvalidUTF8,
+		injectedGroup:  opt.InjectedGroup,
+		dedup:          opt.DedupAttrs,
+		disableSource:  opt.DisableSource,
+		pre:            newPreprocessor(opt),
+		batchMu:        new(sync.RWMutex),
+	}
+	h.pre.level = opt.Level
+
+	return h
 }
 
 func (h *HandlerMiddleware) Enabled(ctx context.Context, rec slog.Level) bool {
-	return h.next.Enabled(ctx, rec)
+	// уровень next проверяется в Handle после правил LevelRules
+	if len(h.pre.rules) > 0 {
+		return true
+	}
+	return h.pre.enabled(ctx, rec, h.loggerName) && h.next.Enabled(ctx, rec)
 }
 
 func (h *HandlerMiddleware) Handle(ctx context.Context, rec slog.Record) error {
+	rec, ok := h.prepare(ctx, rec)
+
+	h.batchMu.RLock()
+	defer h.batchMu.RUnlock()
+
+	for _, n := range h.pre.notices() {
+		if err := h.next.Handle(context.Background(), n); err != nil {
+			return err
+		}
+	}
+
+	// Enabled пропускает все записи при LevelRules: уровень next
+	// проверяется после правил
+	if !ok || len(h.pre.rules) > 0 && !h.next.Enabled(ctx, rec.Level) {
+		return nil
+	}
+
+	err := h.next.Handle(ctx, rec)
+	if err != nil && h.pre.stats != nil {
+		h.pre.stats.writeError()
+	}
+	if err == nil && h.flushLevel != nil && rec.Level >= h.flushLevel.Level() {
+		err = h.flush()
+	}
+	return err
+}
+
+// prepare дополняет запись значениями из контекста и источником вызова
+func (h *HandlerMiddleware) prepare(ctx context.Context, rec slog.Record) (slog.Record, bool) {
+	if !h.pre.process(ctx, &rec, h.loggerName) {
+		return rec, false
+	}
+
+	rec = withScopeAttrs(ctx, rec)
+	rec = sanitizeRecord(rec, h.invalidUTF8)
+	rec = h.nestGroups(rec)
+
+	// значения контекста и источник — в корне записи или в InjectedGroup,
+	// независимо от групп WithGroup
+	var injected []slog.Attr
+
 	for _, v := range h.addCxtAttr {
 		if c := ctx.Value(v); c != nil {
-			rec.Add(v, c)
+			injected = append(injected, slog.Any(v, c))
+		}
+	}
+
+	for _, e := range h.extractors {
+		if attr, ok := e.Extract(ctx); ok {
+			injected = append(injected, attr)
 		}
 	}
-	
+
+	if h.runtimeStats && rec.Level >= slog.LevelError {
+		injected = append(injected, runtimeStatsAttr())
+	}
+
 	if c := ctx.Value(Sql); c != nil {
-		rec.Add(Sql, c)
+		if sql, ok := c.(string); ok {
+			shown := truncateSQL(sanitizeUTF8(sql, h.invalidUTF8), h.maxSQLLength)
+			c = shown
+			if hash, ok := fullSQLHash(ctx, sql, shown); ok {
+				injected = append(injected, slog.String(SqlHash, hash))
+			}
+		}
+		injected = append(injected, slog.Any(Sql, c))
 	}
 
 	if h.source {
-		if c := ctx.Value(Source); c == nil {
-			fs := runtime.CallersFrames([]uintptr{rec.PC})
-			f, _ := fs.Next()
-			if f.File != "" {
-				dir, file := filepath.Split(f.File)
-				pathFile := path.Join(filepath.Base(dir), file)
-
-				src := &slog.Source{
-					Function: getFuncNameSlog(f.Function),
-					File:     pathFile,
-					Line:     f.Line,
+		if c, ok := ctx.Value(Source).(slog.Source); ok {
+			if c.File != "" {
+				c.File = sourcePath(c.File, h.sourcePath)
+			}
+			injected = append(injected, slog.Any(string(Source), &c))
+		} else if sourceLevelEnabled(h.sourceLevel, rec.Level) {
+			if src, ok := resolveSource(h.sourceResolver, rec.PC); ok {
+				if h.sourceResolver == nil || h.sourcePath != SourcePathTrimmed {
+					src.File = sourcePath(src.File, h.sourcePath)
 				}
+				src.Function = getFuncNameSlog(src.Function)
 
-				rec.Add(string(Source), src)
+				injected = append(injected, slog.Any(string(Source), &src))
 			}
 		}
 	}
 
-	return h.next.Handle(ctx, rec)
+	if h.injectedGroup != "" {
+		rec.AddAttrs(slog.Attr{Key: h.injectedGroup, Value: slog.GroupValue(injected...)})
+	} else {
+		rec.AddAttrs(injected...)
+	}
+
+	if h.dedup {
+		rec = dedupRecord(h.attrs, rec)
+	}
+
+	if h.canonical >= 0 {
+		rec = canonicalRecord(rec, h.canonical)
+	}
+
+	if h.pre.sizes != nil {
+		h.measureJSON(&rec)
+	}
+
+	return rec, true
 }
 
 func (h *HandlerMiddleware) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &HandlerMiddleware{next: h.next.WithAttrs(attrs)}
+	if len(attrs) == 0 {
+		return h
+	}
+
+	var h2 *HandlerMiddleware
+	switch {
+	case len(h.groups) == 0 && h.dedup:
+		h2 = h.withNext(h.next)
+		h2.attrs = append(slices.Clip(h.attrs), attrs...)
+	case len(h.groups) == 0:
+		h2 = h.withNext(h.next.WithAttrs(attrs))
+	default:
+		h2 = h.withNext(h.next)
+		last := len(h.groupAttrs) - 1
+		h2.groupAttrs = slices.Clone(h.groupAttrs)
+		h2.groupAttrs[last] = append(slices.Clip(h.groupAttrs[last]), attrs...)
+	}
+	h2.loggerName = loggerName(attrs, h.groups, h.loggerName)
+	return h2
 }
 
 func (h *HandlerMiddleware) WithGroup(name string) slog.Handler {
-	return &HandlerMiddleware{next: h.next.WithGroup(name)}
+	if name == "" {
+		return h
+	}
+
+	h2 := h.withNext(h.next)
+	h2.groups = append(slices.Clip(h.groups), name)
+	h2.groupAttrs = append(slices.Clip(h.groupAttrs), nil)
+	return h2
 }
 
-func InitLogger(opts Options) {
+// nestGroups вкладывает атрибуты записи в группы WithGroup вместе с
+// атрибутами WithAttrs каждой группы, как это сделал бы next.WithGroup
+func (h *HandlerMiddleware) nestGroups(rec slog.Record) slog.Record {
+	if len(h.groups) == 0 {
+		return rec
+	}
+
+	attrs := make([]slog.Attr, 0, rec.NumAttrs())
+	rec.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		members := append(slices.Clip(h.groupAttrs[i]), attrs...)
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(members...)}}
+	}
+
+	r := slog.NewRecord(rec.Time, rec.Level, rec.Message, rec.PC)
+	r.AddAttrs(attrs...)
+	return r
+}
+
+func (h *HandlerMiddleware) withNext(next slog.Handler) *HandlerMiddleware {
+	h2 := *h
+	h2.next = next
+	return &h2
+}
+
+// NewLogger создает JSON логер, не изменяя slog.Default. Без Options.W
+// записи пишутся в os.Stderr.
+func NewLogger(opts Options) (*slog.Logger, error) {
+	if opts.W == nil {
+		opts.W = os.Stderr
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	opt := &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	}
 
-	handler := slog.Handler(slog.NewJSONHandler(os.Stdout, opt))
+	opt.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) > 0 {
+			return a
+		}
+
+		switch {
+		case a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime:
+			t := a.Value.Time()
+			if opts.TimeLocation != nil {
+				t = t.In(opts.TimeLocation)
+			}
+			if opts.TimeFormat != "" {
+				a.Value = slog.StringValue(t.Format(opts.TimeFormat))
+			} else {
+				a.Value = slog.TimeValue(t)
+			}
+		case a.Key == slog.LevelKey:
+			// названия уровней из RegisterLevel
+			if level, ok := a.Value.Any().(slog.Level); ok {
+				if info, ok := registeredLevel(level); ok {
+					a.Value = slog.StringValue(info.name)
+				}
+			}
+		}
+		return a
+	}
+
+	handler := slog.Handler(slog.NewJSONHandler(opts.W, opt))
 	handler = NewHandlerMiddleware(handler, opts)
 
-	logger := slog.New(handler)
+	return slog.New(handler), nil
+}
+
+// NewDevLogger создает цветной логер для разработки, не изменяя slog.Default.
+// Незаданные настройки заполняются ApplyDefaults, без Options.W записи
+// пишутся в os.Stderr.
+func NewDevLogger(opts Options) (*slog.Logger, error) {
+	opts.ApplyDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
 
-	slog.SetDefault(logger)
+	return slog.New(NewDevHandler(opts)), nil
+}
+
+// InitLogger устанавливает JSON логер по умолчанию. Повторный вызов
+// перенастраивает установленный логер, см. ResetLogger. Минимальный
+// уровень можно задать переменной окружения LOG_LEVEL, см. ParseLevel;
+// некорректное значение переменной игнорируется с сообщением в os.Stderr.
+// При некорректных настройках ошибки пишутся в os.Stderr, а логер
+// создается с настройками по умолчанию, см. MustInitLogger.
+func InitLogger(opts Options) {
+	opts.levelFromEnv()
+
+	logger, err := NewLogger(opts)
+	if err != nil {
+		logger, _ = NewLogger(fallbackOptions(opts, err))
+	}
+
+	install(logger.Handler())
+}
+
+// MustInitLogger — InitLogger, паникующий при некорректных настройках
+func MustInitLogger(opts Options) {
+	opts.levelFromEnv()
+
+	logger, err := NewLogger(opts)
+	if err != nil {
+		panic(err)
+	}
+
+	install(logger.Handler())
 }
 
 func GetLogger() *slog.Logger {
 	return slog.Default()
 }
 
+// InitDevLogger устанавливает цветной логер по умолчанию. Повторный вызов
+// перенастраивает установленный логер, см. ResetLogger. Минимальный
+// уровень можно задать переменной окружения LOG_LEVEL, см. ParseLevel;
+// некорректное значение переменной игнорируется с сообщением в os.Stderr.
+// При некорректных настройках ошибки пишутся в os.Stderr, а логер
+// создается с настройками по умолчанию, см. MustInitDevLogger.
 func InitDevLogger(opts Options) {
-	handler := NewDevHandler(opts)
+	opts.levelFromEnv()
 
-	logger := slog.New(handler)
+	logger, err := NewDevLogger(opts)
+	if err != nil {
+		logger, _ = NewDevLogger(fallbackOptions(opts, err))
+	}
+
+	install(logger.Handler())
+}
+
+// MustInitDevLogger — InitDevLogger, паникующий при некорректных настройках
+func MustInitDevLogger(opts Options) {
+	opts.levelFromEnv()
+
+	logger, err := NewDevLogger(opts)
+	if err != nil {
+		panic(err)
+	}
+
+	install(logger.Handler())
+}
+
+// fallbackOptions сообщает в os.Stderr об ошибке настроек и возвращает
+// настройки по умолчанию с writer и уровнем из opts
+func fallbackOptions(opts Options, err error) Options {
+	fmt.Fprintf(stderr, "%s; using default options\n", strings.ReplaceAll(err.Error(), "\n", "; "))
+
+	def := Options{W: opts.W, Level: opts.Level}
+	def.ApplyDefaults()
+	return def
+}
 
-	slog.SetDefault(logger)
+// sourceLevelEnabled сообщает, нужно ли определять источник записи по стеку
+func sourceLevelEnabled(minLevel slog.Leveler, level slog.Level) bool {
+	return minLevel == nil || level >= minLevel.Level()
 }
 
 func getFuncNameSlog(pathFunc string) string {
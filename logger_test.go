@@ -0,0 +1,1426 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// Запись через HandlerMiddleware поверх JSON обработчика, возвращает разобранный JSON
+func logJSON(t *testing.T, opt Options, fn func(log *slog.Logger)) map[string]any {
+	t.Helper()
+
+	var buf bytes.Buffer
+	h := NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), opt)
+	fn(slog.New(h))
+
+	var m map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", buf.String(), err)
+	}
+	return m
+}
+
+func TestPushAttrs(t *testing.T) {
+	ctx := PushAttrs(context.Background(), "request_id", "r1")
+	ctx = PushAttrs(ctx, "handler", "users")
+	inner := PushAttrs(ctx, "repo", "orders")
+
+	var keys []string
+	for _, a := range core.ScopeAttrs(inner) {
+		keys = append(keys, a.Key)
+	}
+	if len(keys) != 3 || keys[0] != "request_id" || keys[2] != "repo" {
+		t.Errorf("Expected attrs from outer to inner scope, got: %v", keys)
+	}
+
+	if n := len(core.ScopeAttrs(PopAttrs(inner))); n != 2 {
+		t.Errorf("Expected 2 attrs after pop, got: %d", n)
+	}
+
+	// снятие лишних уровней и снятие в контексте без уровней не паникует
+	root := PopAttrs(PopAttrs(PushAttrs(context.Background(), "a", 1)))
+	if n := len(core.ScopeAttrs(root)); n != 0 {
+		t.Errorf("Expected no attrs after popping past root, got: %d", n)
+	}
+	if n := len(core.ScopeAttrs(PopAttrs(context.Background()))); n != 0 {
+		t.Errorf("Expected no attrs after pop on bare context, got: %d", n)
+	}
+	if n := len(core.ScopeAttrs(PushAttrs(root, "b", 2))); n != 1 {
+		t.Errorf("Expected 1 attr after push on popped context, got: %d", n)
+	}
+
+	m := logJSON(t, Options{}, func(log *slog.Logger) {
+		log.InfoContext(inner, "msg", "status", 200)
+	})
+	if m["request_id"] != "r1" || m["repo"] != "orders" || m["status"] != float64(200) {
+		t.Errorf("Scope attrs missing in JSON output: %v", m)
+	}
+}
+
+func TestCardinalityGuard(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), Options{CardinalityLimit: 3})
+	log := slog.New(h)
+
+	for i := 0; i < 5; i++ {
+		log.Info("login", "email", fmt.Sprintf("user%d@example.com", i), "status", "ok")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected 5 records and 1 warning, got %d lines:\n%s", len(lines), buf.String())
+	}
+
+	if !strings.Contains(lines[3], `"msg":"high cardinality attribute"`) || !strings.Contains(lines[3], `"key":"email"`) {
+		t.Errorf("Expected warning before the first hashed record, got: %s", lines[3])
+	}
+	if !strings.Contains(lines[2], "user2@example.com") {
+		t.Errorf("Values under the limit must be kept, got: %s", lines[2])
+	}
+	if strings.Contains(lines[4], "user3@example.com") || !strings.Contains(lines[4], `"email":"h:`) {
+		t.Errorf("Expected hashed value past the limit, got: %s", lines[4])
+	}
+	if !strings.Contains(lines[5], `"status":"ok"`) {
+		t.Errorf("Low cardinality keys must be kept, got: %s", lines[5])
+	}
+
+	// без CardinalityKeyLimit число ключей не ограничено
+	buf.Reset()
+	for i := 0; i < 5; i++ {
+		log.Info("keys", fmt.Sprintf("k%d", i), 1)
+	}
+	if strings.Contains(buf.String(), CardinalityOverflowKey) || strings.Contains(buf.String(), "high cardinality attribute keys") {
+		t.Errorf("Keys must not be limited by CardinalityLimit, got:\n%s", buf.String())
+	}
+}
+
+func TestCardinalityGuardKeys(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), Options{CardinalityKeyLimit: 2, CardinalityTruncate: 8})
+	log := slog.New(h)
+
+	log.Info("a", "x", 1, "y", 2)
+	log.Info("b", "john@example.com", 1)
+
+	out := buf.String()
+	if !strings.Contains(out, `"msg":"high cardinality attribute keys"`) || !strings.Contains(out, `"limit":2`) {
+		t.Errorf("Expected keys warning, got:\n%s", out)
+	}
+	if !strings.Contains(out, `"`+CardinalityOverflowKey+`":"john@exa…"`) {
+		t.Errorf("Expected new key moved into truncated value, got:\n%s", out)
+	}
+
+	// без CardinalityLimit значения известных ключей не ограничены
+	buf.Reset()
+	for i := 0; i < 5; i++ {
+		log.Info("login", "x", fmt.Sprintf("user%d@example.com", i))
+	}
+	out = buf.String()
+	if !strings.Contains(out, "user4@example.com") || strings.Contains(out, "high cardinality") {
+		t.Errorf("Values must not be limited by CardinalityKeyLimit, got:\n%s", out)
+	}
+}
+
+func TestCardinalityGuardUncomparable(t *testing.T) {
+	// срезы и map в KindAny нельзя сравнивать через slog.Value.Equal
+	opt := Options{CardinalityLimit: 2, CardinalityKeyLimit: 4}
+
+	var jsonBuf, devBuf bytes.Buffer
+	opt.W = &jsonBuf
+	jsonLog, _ := NewLogger(opt)
+	opt.W = &devBuf
+	opt.DisableColor = true
+	devLog, _ := NewDevLogger(opt)
+
+	for _, l := range []*slog.Logger{jsonLog, devLog} {
+		for i := 0; i < 3; i++ {
+			l.Info("ids", slog.Any("ids", []int{i, 2}), slog.Any("tags", map[string]int{"a": i}),
+				slog.Group("req", slog.Any("path", []string{"a", "b"}), slog.Int("n", i)))
+		}
+		// переполнение ключей с несравнимым значением
+		l.Info("over", slog.Any("k1", []int{1}), slog.Any("k2", map[int]int{1: 1}))
+	}
+
+	for name, out := range map[string]string{"json": jsonBuf.String(), "dev": devBuf.String()} {
+		if strings.Count(out, "tags") != 3 || !strings.Contains(out, CardinalityOverflowKey) {
+			t.Errorf("%s: expected all records and key overflow, got:\n%s", name, out)
+		}
+	}
+}
+
+func TestCtxKey(t *testing.T) {
+	userID := NewCtxKey[int64]("user_id")
+	other := NewCtxKey[int64]("user_id")
+
+	ctx := userID.Set(context.Background(), 42)
+	if v, ok := userID.Get(ctx); !ok || v != 42 {
+		t.Errorf("Expected 42, got %v %v", v, ok)
+	}
+	if _, ok := other.Get(ctx); ok {
+		t.Error("Keys with the same name must not collide")
+	}
+
+	m := logJSON(t, Options{CtxExtractors: []CtxExtractor{userID}}, func(log *slog.Logger) {
+		log.InfoContext(ctx, "msg")
+	})
+	if m["user_id"] != float64(42) {
+		t.Errorf("Expected typed user_id in JSON output: %v", m)
+	}
+
+	var buf bytes.Buffer
+	slog.New(NewDevHandler(Options{W: &buf, CtxExtractors: []CtxExtractor{userID}})).InfoContext(ctx, "msg")
+	if !strings.Contains(stripANSI(buf.String()), "user_id=42") {
+		t.Errorf("Expected user_id in dev output, got: %q", buf.String())
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	prev := slog.Default()
+
+	if _, err := NewDevLogger(Options{W: io.Discard, SlowThreshold: -time.Second}); err == nil {
+		t.Error("Expected error for negative SlowThreshold")
+	}
+
+	// оба логера без W пишут в os.Stderr, как ApplyDefaults
+	if log, err := NewDevLogger(Options{}); err != nil || log.Handler().(*handlerTextColor).w != os.Stderr {
+		t.Errorf("Expected NewDevLogger to default to os.Stderr, got: %v", err)
+	}
+
+	// Init* не паникуют: ошибки в stderr, логер с настройками по умолчанию
+	var diag, out bytes.Buffer
+	stderr = &diag
+	defer func() { stderr = os.Stderr }()
+	InitLogger(Options{W: &out, MaxSQLLength: -1, TimeFormat: "hh:mm:ss"})
+	slog.Info("fallback")
+	if !strings.Contains(out.String(), `"msg":"fallback"`) {
+		t.Errorf("Expected fallback logger writing to Options.W, got %q", out.String())
+	}
+	if got := diag.String(); strings.Count(got, "\n") != 1 || !strings.Contains(got, "MaxSQLLength") || !strings.Contains(got, "TimeFormat") {
+		t.Errorf("Expected one diagnostic line with all errors, got %q", got)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected MustInitLogger to panic on invalid options")
+			}
+		}()
+		MustInitLogger(Options{W: &out, MaxSQLLength: -1})
+	}()
+	slog.SetDefault(prev)
+	if log, err := NewLogger(Options{}); err != nil || log.Handler().(*HandlerMiddleware).w != os.Stderr {
+		t.Errorf("Expected NewLogger to default to os.Stderr, got: %v", err)
+	}
+	if _, err := NewLogger(Options{TimeFormat: "hh:mm:ss"}); err == nil {
+		t.Error("Expected error for time format without layout elements")
+	}
+
+	var buf bytes.Buffer
+	log, err := NewLogger(Options{W: &buf, TimeFormat: time.DateOnly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Info("hello")
+
+	if slog.Default() != prev {
+		t.Error("NewLogger must not replace the default logger")
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse(time.DateOnly, m["time"].(string)); err != nil {
+		t.Errorf("Expected time in TimeFormat, got: %v", m["time"])
+	}
+
+	// время в поясе TimeLocation, в том числе без TimeFormat
+	loc := time.FixedZone("MSK", 3*60*60)
+	for _, format := range []string{"", time.RFC3339} {
+		buf.Reset()
+		log, err = NewLogger(Options{W: &buf, TimeFormat: format, TimeLocation: loc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.Info("hello")
+
+		m = nil
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		if s := m["time"].(string); !strings.HasSuffix(s, "+03:00") {
+			t.Errorf("Expected time in TimeLocation with format %q, got: %v", format, s)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	err := Options{
+		MaxSQLLength:        -1,
+		CardinalityTruncate: 10,
+		LevelRules:          []LevelRule{{After: time.Minute, Before: time.Second}},
+	}.Validate()
+
+	if !errors.Is(err, ErrNilWriter) {
+		t.Errorf("Expected ErrNilWriter, got: %v", err)
+	}
+	for _, want := range []string{"MaxSQLLength is negative", "CardinalityLimit and CardinalityKeyLimit are 0", "LevelRules[0]"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got: %v", want, err)
+		}
+	}
+
+	var opt Options
+	opt.ApplyDefaults()
+	if opt.W != os.Stderr || opt.SlowThreshold != time.Second {
+		t.Errorf("Unexpected defaults: %+v", opt)
+	}
+	if err := opt.Validate(); err != nil {
+		t.Errorf("Defaults must be valid, got: %v", err)
+	}
+}
+
+func TestShutdownSummaryJSON(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), Options{Summary: true}))
+
+	ctx := context.WithValue(context.Background(), Sql, "SELECT 1")
+	ctx = context.WithValue(ctx, Duration, 2*time.Second)
+	log.ErrorContext(ctx, "timeout")
+
+	buf.Reset()
+	if err := Shutdown(log); err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", buf.String(), err)
+	}
+
+	records, _ := m["records"].(map[string]any)
+	slowest, _ := m["slowest"].(map[string]any)
+	first, _ := slowest["1"].(map[string]any)
+
+	if m["msg"] != "log summary" || records["error"] != float64(1) || m["slow_queries"] != float64(1) || first[Sql] != "SELECT 1" {
+		t.Errorf("Unexpected JSON summary: %v", m)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		texts []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		texts = append(texts, body.Text)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	opt := Options{
+		SlowThreshold: 100 * time.Millisecond,
+		Digest:        &DigestConfig{URL: srv.URL, Interval: time.Hour, Title: "billing"},
+	}
+	log := slog.New(NewHandlerMiddleware(slog.NewJSONHandler(io.Discard, nil), opt))
+
+	query := func(sql string, d time.Duration) context.Context {
+		ctx := context.WithValue(context.Background(), Sql, sql)
+		return context.WithValue(ctx, Duration, d)
+	}
+	log.InfoContext(query("SELECT * FROM users WHERE id = 1", 10*time.Millisecond), "")
+	log.InfoContext(query("SELECT * FROM users WHERE id = 2", 200*time.Millisecond), "")
+	log.InfoContext(query("SELECT 1", time.Millisecond), "")
+	log.ErrorContext(query("INSERT INTO users VALUES (1)", time.Millisecond), "duplicate key",
+		slog.Group("db", slog.String("sqlstate", "23505")))
+	log.Error("failed", "error", fmt.Errorf("load: %w", os.ErrNotExist))
+	log.Error("decode failed", Err(fmt.Errorf("decode: %w", &json.SyntaxError{})))
+
+	if err := Shutdown(log); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(texts) != 1 {
+		t.Fatalf("Expected one digest, got %d: %q", len(texts), texts)
+	}
+	for _, want := range []string{
+		"*billing: query digest*",
+		"records: 0 debug, 3 info, 0 warn, 3 error; slow queries: 1",
+		"1. `SELECT * FROM users WHERE id = ?` ×2, total 210ms, avg 105ms, max 200ms, slow 1",
+		"• `sqlstate 23505` ×1",
+		"• `*errors.errorString` ×1",
+		"• `*json.SyntaxError` ×1",
+	} {
+		if !strings.Contains(texts[0], want) {
+			t.Errorf("Expected %q in digest:\n%s", want, texts[0])
+		}
+	}
+
+	// пустой отчет не отправляется, повторный Shutdown не отправляет отчет
+	if err := Shutdown(log); err != nil || len(texts) != 1 {
+		t.Errorf("Expected no second digest, got %d (err %v)", len(texts), err)
+	}
+
+	// обработчики RenderRecord не запускают отправку по интервалу
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		if _, err := RenderRecord(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0), opt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := runtime.NumGoroutine(); n > before+5 {
+		t.Errorf("Expected no digest goroutines from RenderRecord, got %d → %d", before, n)
+	}
+
+	// токен из адреса webhook не попадает в текст ошибки
+	var buf bytes.Buffer
+	failing := Options{Digest: &DigestConfig{URL: "http://127.0.0.1:1/hooks/secret-token"}}
+	log = slog.New(NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), failing))
+	log.Info("msg")
+	if err := Shutdown(log); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("Expected redacted webhook error, got %v", err)
+	}
+	// служебная запись выводится перед следующей
+	log.Info("after")
+	if out := buf.String(); !strings.Contains(out, "digest webhook failed") || strings.Contains(out, "secret-token") {
+		t.Errorf("Expected redacted webhook error, got: %s", out)
+	}
+}
+
+func TestSourceLevel(t *testing.T) {
+	opt := Options{Source: true, SourceLevel: slog.LevelWarn}
+
+	m := logJSON(t, opt, func(log *slog.Logger) { log.Info("msg") })
+	if _, ok := m[Source]; ok {
+		t.Errorf("Source must not be captured below SourceLevel: %v", m)
+	}
+
+	m = logJSON(t, opt, func(log *slog.Logger) { log.Warn("msg") })
+	if _, ok := m[Source]; !ok {
+		t.Errorf("Source must be captured at SourceLevel: %v", m)
+	}
+
+	ctx := context.WithValue(context.Background(), Source, slog.Source{Function: "Find", File: "repo/users.go", Line: 12})
+	m = logJSON(t, opt, func(log *slog.Logger) { log.InfoContext(ctx, "msg") })
+	if src, _ := m[Source].(map[string]any); src["file"] != "repo/users.go" {
+		t.Errorf("Context source must be kept at any level: %v", m)
+	}
+
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, Source: true, SourceLevel: slog.LevelWarn}))
+	log.Info("quiet")
+	log.Error("loud")
+	out := stripANSI(buf.String())
+	if strings.Count(out, "logger_test.go") != 1 || !strings.Contains(out, "logger_test.go") {
+		t.Errorf("Expected source only on error line, got: %q", out)
+	}
+}
+
+func TestSourceResolver(t *testing.T) {
+	resolver := RewriteSourcePath(func(file string) string {
+		return "https://git.example.com/repo/blob/main/" + filepath.Base(file)
+	})
+
+	m := logJSON(t, Options{Source: true, SourceResolver: resolver}, func(log *slog.Logger) { log.Info("msg") })
+	src, _ := m[Source].(map[string]any)
+	if src["file"] != "https://git.example.com/repo/blob/main/logger_test.go" || src["function"] != "TestSourceResolver.func2" {
+		t.Errorf("Expected rewritten source in JSON, got: %v", src)
+	}
+
+	var buf bytes.Buffer
+	fixed := SourceResolverFunc(func(pc uintptr) (slog.Source, bool) {
+		return slog.Source{Function: "pkg.Handler", File: "symbols/handler.go", Line: 7}, true
+	})
+	slog.New(NewDevHandler(Options{W: &buf, Source: true, SourceResolver: fixed})).Info("msg")
+	if out := stripANSI(buf.String()); !strings.Contains(out, "symbols/handler.go:7 Handler") {
+		t.Errorf("Expected resolver source in dev output, got: %q", out)
+	}
+
+	// источник из контекста (gorm логер) сокращается и с резолвером
+	ctx := context.WithValue(context.Background(), Source, slog.Source{Function: "repo.Find", File: "/tmp/rv/repo/user.go", Line: 18})
+	buf.Reset()
+	slog.New(NewDevHandler(Options{W: &buf, Source: true, SourceResolver: fixed})).InfoContext(ctx, "msg")
+	if out := stripANSI(buf.String()); !strings.Contains(out, " repo/user.go:18 Find") {
+		t.Errorf("Expected trimmed context source in dev output, got: %q", out)
+	}
+
+	m = logJSON(t, Options{Source: true, SourceResolver: fixed}, func(log *slog.Logger) { log.InfoContext(ctx, "msg") })
+	if src, _ := m[Source].(map[string]any); src["file"] != "repo/user.go" {
+		t.Errorf("Expected trimmed context source in JSON, got: %v", m[Source])
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	userID := NewCtxKey[int64]("user_id")
+	opt := Options{Source: true, AddCxtAttr: []string{"request_id"}, CtxExtractors: []CtxExtractor{userID}}
+
+	data, err := JSONSchema(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Required   []string                  `json:"required"`
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.Properties["user_id"]["type"] != "integer" {
+		t.Errorf("Expected typed CtxKey property, got: %v", schema.Properties["user_id"])
+	}
+
+	// каждое поле реальной записи описано схемой
+	ctx := userID.Set(context.WithValue(context.Background(), "request_id", "r1"), 7)
+	ctx = context.WithValue(ctx, Sql, "SELECT 1")
+	m := logJSON(t, opt, func(log *slog.Logger) { log.InfoContext(ctx, "msg") })
+	for k := range m {
+		if _, ok := schema.Properties[k]; !ok {
+			t.Errorf("Field %q missing in schema", k)
+		}
+	}
+	for _, k := range schema.Required {
+		if _, ok := m[k]; !ok {
+			t.Errorf("Required field %q missing in record", k)
+		}
+	}
+}
+
+func TestRecordSizeStatsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := NewLogger(Options{W: &buf, SizeStats: true, LargeRecord: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Info("small")
+	log.Info("big", "payload", strings.Repeat("x", 100))
+
+	sizes := RecordSizeStats(log)
+	if len(sizes) != 2 || sizes[0].Bytes <= sizes[1].Bytes {
+		t.Fatalf("Unexpected sizes: %+v", sizes)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if strings.Contains(lines[0], RecordBytes) || !strings.Contains(lines[1], `"record_bytes":`) {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+
+	if _, err := NewLogger(Options{W: &buf, LargeRecord: 100}); err == nil {
+		t.Error("Expected LargeRecord without SizeStats to fail validation")
+	}
+}
+
+func TestRuntimeStats(t *testing.T) {
+	opt := Options{RuntimeStats: true}
+
+	m := logJSON(t, opt, func(log *slog.Logger) { log.Warn("msg") })
+	if _, ok := m[RuntimeKey]; ok {
+		t.Errorf("Runtime stats must not be added below Error: %v", m)
+	}
+
+	m = logJSON(t, opt, func(log *slog.Logger) { log.Error("msg") })
+	stats, _ := m[RuntimeKey].(map[string]any)
+	if g, _ := stats["goroutines"].(float64); g < 1 {
+		t.Errorf("Expected goroutine count, got %v", m)
+	}
+	if heap, _ := stats["heap_inuse"].(float64); heap <= 0 {
+		t.Errorf("Expected heap size, got %v", m)
+	}
+
+	var buf bytes.Buffer
+	log := slog.New(NewDevHandler(Options{W: &buf, RuntimeStats: true}))
+	log.Info("quiet")
+	log.Error("loud")
+	out := stripANSI(buf.String())
+	if strings.Count(out, "runtime.goroutines=") != 1 || !strings.Contains(out, "runtime.heap_inuse=") {
+		t.Errorf("Expected runtime stats only on error line, got: %q", out)
+	}
+}
+
+func TestInitLoggerIdempotent(t *testing.T) {
+	defer ResetLogger()
+
+	prev := slog.Default()
+
+	var first, second bytes.Buffer
+	InitLogger(Options{W: &first})
+	installed := slog.Default()
+	child := installed.With("svc", "api").WithGroup("req")
+
+	InitLogger(Options{W: &second})
+	if slog.Default() != installed {
+		t.Fatal("Repeated InitLogger must reconfigure the installed logger")
+	}
+
+	child.Info("msg", "id", 1)
+	if first.Len() != 0 {
+		t.Errorf("Expected no output to the old writer, got %s", first.String())
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(second.Bytes(), &m); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", second.String(), err)
+	}
+	if req, _ := m["req"].(map[string]any); m["svc"] != "api" || req["id"] != float64(1) {
+		t.Errorf("Derived logger lost its attrs after reconfiguration: %v", m)
+	}
+
+	ResetLogger()
+	if slog.Default() != prev {
+		t.Error("ResetLogger must restore the previous default logger")
+	}
+}
+
+func TestResetLoggerRestoresLog(t *testing.T) {
+	defer ResetLogger()
+
+	var orig bytes.Buffer
+	prevWriter, prevFlags, prevPrefix := log.Writer(), log.Flags(), log.Prefix()
+	defer func() {
+		log.SetOutput(prevWriter)
+		log.SetFlags(prevFlags)
+		log.SetPrefix(prevPrefix)
+	}()
+	log.SetOutput(&orig)
+	log.SetFlags(log.Lmsgprefix)
+	log.SetPrefix("app: ")
+
+	var buf bytes.Buffer
+	InitLogger(Options{W: &buf})
+	log.Print("through slog")
+	if !strings.Contains(buf.String(), `"msg":"app: through slog"`) || orig.Len() != 0 {
+		t.Errorf("Expected log output in the installed handler, got %q and %q", buf.String(), orig.String())
+	}
+
+	ResetLogger()
+	buf.Reset()
+	log.Print("after reset")
+	if orig.String() != "app: after reset\n" || buf.Len() != 0 {
+		t.Errorf("Expected log output restored after ResetLogger, got %q and %q", orig.String(), buf.String())
+	}
+	if log.Flags() != log.Lmsgprefix || log.Prefix() != "app: " {
+		t.Errorf("Expected log flags and prefix restored, got %d %q", log.Flags(), log.Prefix())
+	}
+}
+
+// Обработчик с поддержкой блоков: запоминает размеры блоков
+type batchRecorder struct {
+	slog.Handler
+	batches []int
+}
+
+func (h *batchRecorder) handleBatch(entries []batchEntry) error {
+	h.batches = append(h.batches, len(entries))
+	return nil
+}
+
+func TestInitLoggerBatch(t *testing.T) {
+	defer ResetLogger()
+
+	rec := &batchRecorder{Handler: slog.NewJSONHandler(io.Discard, nil)}
+	install(rec)
+
+	// блок доходит до установленного обработчика одним вызовом
+	if err := Batch(context.Background()).Info("a").Info("b").Emit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.batches) != 1 || rec.batches[0] != 2 {
+		t.Errorf("Expected one batch of 2 records through swapHandler, got %v", rec.batches)
+	}
+}
+
+// Обработчик без поддержки блоков, который задерживается после первой
+// записи блока: без блокировки записи других горутин попадают внутрь блока
+type slowBatchHandler struct{ slog.Handler }
+
+func (h slowBatchHandler) Handle(ctx context.Context, rec slog.Record) error {
+	err := h.Handler.Handle(ctx, rec)
+	if rec.Message == "summary" {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return err
+}
+
+func TestMiddlewareBatchIsContiguous(t *testing.T) {
+	var buf bytes.Buffer
+	next := slowBatchHandler{slog.NewJSONHandler(&buf, nil)}
+	log := slog.New(NewHandlerMiddleware(next, Options{W: &buf}))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					log.Info("noise")
+				}
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond)
+	err := (&BatchBuilder{ctx: context.Background(), logger: log}).
+		Info("summary").
+		Warn("slow query 1").
+		Warn("slow query 2").
+		Emit()
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	start := strings.Index(out, "summary")
+	end := strings.Index(out, "slow query 2")
+	if start < 0 || end < 0 || strings.Contains(out[start:end], "noise") {
+		t.Errorf("Batch records through JSON middleware should be contiguous")
+	}
+}
+
+func TestInitStopsDigest(t *testing.T) {
+	defer ResetLogger()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	digestOpt := &DigestConfig{URL: srv.URL, Interval: time.Hour}
+
+	stopped := func(d *digest) bool {
+		select {
+		case <-d.done:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+
+	// перенастройка останавливает горутину отчетов прежнего обработчика
+	InitLogger(Options{W: io.Discard, Digest: digestOpt})
+	first := (*registry.installed.handler.Load()).(*HandlerMiddleware).pre.digest
+	InitDevLogger(Options{W: io.Discard, Digest: digestOpt})
+	if !stopped(first) {
+		t.Error("Expected digest goroutine stopped after reconfiguration")
+	}
+
+	second := (*registry.installed.handler.Load()).(*handlerTextColor).pre.digest
+	ResetLogger()
+	if !stopped(second) {
+		t.Error("Expected digest goroutine stopped after ResetLogger")
+	}
+}
+
+func TestInitDisableSource(t *testing.T) {
+	defer ResetLogger()
+
+	// Init* устанавливает swapHandler: отключение источника видно через него
+	InitLogger(Options{W: io.Discard, Source: true, DisableSource: true})
+	if sourceCapture(slog.Default().Handler()) || sourceCapture(slog.Default().With("a", 1).Handler()) {
+		t.Error("Expected source capture disabled through InitLogger")
+	}
+
+	InitDevLogger(Options{W: io.Discard, DisableSource: true})
+	if sourceCapture(slog.Default().Handler()) {
+		t.Error("Expected source capture disabled through InitDevLogger")
+	}
+
+	b := Batch(context.Background()).Info("a")
+	if b.entries[0].rec.PC != 0 {
+		t.Error("Expected batch record without PC when source is disabled")
+	}
+
+	InitDevLogger(Options{W: io.Discard, Source: true})
+	if !sourceCapture(slog.Default().Handler()) {
+		t.Error("Expected source capture after reconfiguration with Source")
+	}
+}
+
+func TestDiagnosticCodes(t *testing.T) {
+	_, err := NewLogger(Options{W: io.Discard, ForceColor: true, DisableColor: true})
+
+	var de *DiagnosticError
+	if !errors.As(err, &de) || de.Code != CodeColorConflict || !errors.Is(err, ErrColorConflict) {
+		t.Errorf("Expected %s error, got: %v", CodeColorConflict, err)
+	}
+	if !strings.Contains(err.Error(), "cfg002") {
+		t.Errorf("Expected code in error text, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	log := slog.New(NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), Options{}))
+
+	ctx := context.WithValue(context.Background(), Duration, time.Millisecond)
+	log.InfoContext(ctx, "first")
+	log.InfoContext(ctx, "second")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"code":"ctx001"`) {
+		t.Errorf("Expected a single ctx001 diagnostic before records, got:\n%s", buf.String())
+	}
+}
+
+type failWriter struct{ err error }
+
+func (w failWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestCompressWriter(t *testing.T) {
+	var sink bytes.Buffer
+	cw, err := NewCompressWriter(&sink, Gzip(gzip.BestSpeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := NewLogger(Options{W: cw, FlushLevel: slog.LevelError})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Info("buffered")
+	before := sink.Len()
+	log.Error("flushed")
+	if sink.Len() == before {
+		t.Fatal("Expected error record to flush compressed data")
+	}
+
+	// поток без Close читается до последнего сброшенного блока
+	zr, err := gzip.NewReader(bytes.NewReader(sink.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if !bytes.Contains(data, []byte("buffered")) || !bytes.Contains(data, []byte("flushed")) {
+		t.Errorf("Expected both records after flush, got %q", data)
+	}
+
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cw.Write([]byte("x")); !errors.Is(err, ErrCompressClosed) {
+		t.Errorf("Expected ErrCompressClosed, got %v", err)
+	}
+
+	// ошибка получателя сохраняется до Reset
+	boom := errors.New("broken pipe")
+	if err := cw.Reset(failWriter{boom}); err != nil {
+		t.Fatal(err)
+	}
+	cw.Write([]byte("lost"))
+	if err := cw.Flush(); !errors.Is(err, boom) {
+		t.Errorf("Expected sticky write error, got %v", err)
+	}
+	if _, err := cw.Write([]byte("x")); !errors.Is(err, boom) {
+		t.Errorf("Expected sticky write error, got %v", err)
+	}
+
+	sink.Reset()
+	if err := cw.Reset(&sink); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cw.Write([]byte("again\n")); err != nil || cw.Close() != nil {
+		t.Fatalf("Expected writer to recover after Reset, got %v", err)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.gz")
+	f, err := NewRotatingFile(path, RotateOptions{MaxSize: 100, MaxBackups: 2, Compressor: Gzip(gzip.BestSpeed)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 4 файла по 2 записи: самый старый удален
+	for i := range 8 {
+		if _, err := fmt.Fprintf(f, "record %d %s\n", i, strings.Repeat("x", 30)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, ErrRotatingFileClosed) {
+		t.Errorf("Expected ErrRotatingFileClosed, got %v", err)
+	}
+
+	// каждый файл — законченный поток gzip
+	read := func(name string) string {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("Expected complete gzip stream in %s, got %v", name, err)
+		}
+		return string(out)
+	}
+
+	for name, want := range map[string][]string{
+		path:        {"record 6", "record 7"},
+		path + ".1": {"record 4", "record 5"},
+		path + ".2": {"record 2", "record 3"},
+	} {
+		got := read(name)
+		if strings.Count(got, "\n") != 2 || !strings.Contains(got, want[0]) || !strings.Contains(got, want[1]) {
+			t.Errorf("Expected %v in %s, got %q", want, filepath.Base(name), got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected no more than MaxBackups copies, got %v", err)
+	}
+}
+
+func TestNetworkWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	// каждое соединение — отдельный поток gzip
+	conns := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				zr, err := gzip.NewReader(conn)
+				if err != nil {
+					conns <- "error: " + err.Error()
+					return
+				}
+				data, _ := io.ReadAll(zr)
+				conns <- string(data)
+			}()
+		}
+	}()
+
+	w := NewNetworkWriter("tcp", ln.Addr().String(), Gzip(gzip.BestSpeed))
+	log, err := NewLogger(Options{W: w, FlushLevel: slog.LevelError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Info("over network")
+	log.Error("flushed")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-conns:
+		if !strings.Contains(got, "over network") || !strings.Contains(got, "flushed") {
+			t.Errorf("Expected both records on the connection, got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for network records")
+	}
+
+	if _, err := w.Write([]byte("x")); !errors.Is(err, ErrNetworkWriterClosed) {
+		t.Errorf("Expected ErrNetworkWriterClosed, got %v", err)
+	}
+
+	// получатель недоступен: ошибка записи, без паники
+	ln.Close()
+	w = NewNetworkWriter("tcp", ln.Addr().String(), nil)
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Error("Expected dial error for closed listener")
+	}
+}
+
+func TestNetworkWriterTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	// получатель принимает соединения, но не читает из них
+	accepted := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			accepted <- conn
+		}
+	}()
+
+	w := NewNetworkWriter("tcp", ln.Addr().String(), nil)
+	w.WriteTimeout = 100 * time.Millisecond
+	defer w.Close()
+
+	chunk := make([]byte, 1<<20)
+	start := time.Now()
+	for err == nil && time.Since(start) < 5*time.Second {
+		_, err = w.Write(chunk)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected write deadline error, got %v", err)
+	}
+
+	// после таймаута следующая запись открывает новое соединение
+	w.Write([]byte("next\n"))
+	for n := 0; n < 2; n++ {
+		select {
+		case <-accepted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected reconnect after write timeout")
+		}
+	}
+}
+
+func TestClockAndMonotonicTime(t *testing.T) {
+	frozen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	m := logJSON(t, Options{Clock: func() time.Time { return frozen }}, func(log *slog.Logger) { log.Info("msg") })
+	if m["time"] != "2024-05-01T12:00:00Z" {
+		t.Errorf("Expected frozen time, got %v", m["time"])
+	}
+
+	// часы переводятся назад между записями
+	times := []time.Time{frozen, frozen.Add(-time.Minute), frozen.Add(time.Second)}
+	i := 0
+	clock := func() time.Time { i++; return times[i-1] }
+
+	var buf bytes.Buffer
+	log, err := NewLogger(Options{W: &buf, Clock: clock, MonotonicTime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range times {
+		log.Info("tick")
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rec["time"].(string))
+	}
+
+	want := []string{"2024-05-01T12:00:00Z", "2024-05-01T12:00:00Z", "2024-05-01T12:00:01Z"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected non-decreasing times %v, got %v", want, got)
+	}
+}
+
+func TestCanonicalOutput(t *testing.T) {
+	var buf bytes.Buffer
+	frozen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	log, err := NewLogger(Options{W: &buf, Canonical: true, CanonicalPrecision: 3, Clock: func() time.Time { return frozen }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Info("done", "z", 1, "ratio", 2.0/3, "elapsed", 1500*time.Millisecond,
+		slog.Group("b", slog.Int("y", 2), slog.Int("x", 1)))
+
+	want := `{"time":"2024-05-01T12:00:00Z","level":"INFO","msg":"done","b":{"x":1,"y":2},"elapsed":"1.5s","ratio":0.667,"z":1}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected canonical output:\n got %s\nwant %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	slog.New(NewDevHandler(Options{W: &buf, Canonical: true})).Info("done", "z", 1, "a", 0.1+0.2)
+	if out := buf.String(); !strings.Contains(out, "a=0.3 z=1") {
+		t.Errorf("Expected sorted rounded attrs in dev output, got %q", out)
+	}
+}
+
+func TestLevelVar(t *testing.T) {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+
+	var jsonBuf, devBuf bytes.Buffer
+	jsonLog, err := NewLogger(Options{W: &jsonBuf, Level: level})
+	if err != nil {
+		t.Fatal(err)
+	}
+	devLog, err := NewDevLogger(Options{W: &devBuf, DisableColor: true, Level: level})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range []*slog.Logger{jsonLog, devLog} {
+		l.Info("before")
+	}
+	level.Set(slog.LevelInfo)
+	for _, l := range []*slog.Logger{jsonLog, devLog} {
+		l.Info("after")
+	}
+
+	for name, out := range map[string]string{"json": jsonBuf.String(), "dev": devBuf.String()} {
+		if strings.Contains(out, "before") || !strings.Contains(out, "after") {
+			t.Errorf("%s: expected level change to apply at runtime, got %q", name, out)
+		}
+	}
+}
+
+func TestChecksumWriter(t *testing.T) {
+	var buf bytes.Buffer
+	cw, err := NewChecksumWriter(&buf, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, _ := NewLogger(Options{W: cw})
+	log.Info("one")
+	log.Info("two")
+	log.Info("three")
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(buf.String(), "#chain sha256:"); n != 2 {
+		t.Fatalf("Expected 2 checksum lines, got %d:\n%s", n, buf.String())
+	}
+
+	last, err := VerifyChecksums(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Expected valid chain, got %v", err)
+	}
+
+	// дописывание в файл продолжает цепочку
+	cw, _ = NewChecksumWriter(&buf, 1, last)
+	io.WriteString(cw, "four\n")
+	cw.Close()
+	if _, err := VerifyChecksums(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Errorf("Expected resumed chain to verify, got %v", err)
+	}
+
+	tampered := strings.Replace(buf.String(), "two", "tw0", 1)
+	if _, err := VerifyChecksums(strings.NewReader(tampered)); !errors.Is(err, ErrChecksumMismatch) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected mismatch at line 3, got %v", err)
+	}
+
+	if _, err := VerifyChecksums(strings.NewReader(buf.String() + "extra\n")); !errors.Is(err, ErrChecksumTail) {
+		t.Errorf("Expected tail error, got %v", err)
+	}
+}
+
+func TestLevelRoutes(t *testing.T) {
+	routes := []LevelRoute{
+		{Logger: LoggerGorm, Level: slog.LevelDebug},
+		{Logger: "billing", Level: slog.LevelError},
+		{Package: "github.com/bairto15/slog_gorm_color.TestLevelRoutes", Level: slog.LevelWarn},
+	}
+
+	var jsonBuf, devBuf bytes.Buffer
+	jsonLog, _ := NewLogger(Options{W: &jsonBuf, Level: slog.LevelInfo, LevelRoutes: routes})
+	devLog, _ := NewDevLogger(Options{W: &devBuf, DisableColor: true, Level: slog.LevelInfo, LevelRoutes: routes})
+
+	for _, l := range []*slog.Logger{jsonLog, devLog} {
+		// маршрут по пакету: место вызова в этом тесте
+		l.Info("pkg-info")
+		l.Warn("pkg-warn")
+		// имя логера важнее пакета
+		l.With(LoggerKey, "billing").Warn("billing-warn")
+		l.With(LoggerKey, "billing").Error("billing-error")
+		// имя из контекста, как у записей gorm логера
+		l.InfoContext(core.WithLoggerName(context.Background(), LoggerGorm), "gorm-info")
+	}
+
+	for name, out := range map[string]string{"json": jsonBuf.String(), "dev": devBuf.String()} {
+		for _, want := range []string{"pkg-warn", "billing-error", "gorm-info"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: expected %q in %q", name, want, out)
+			}
+		}
+		for _, unwanted := range []string{"pkg-info", "billing-warn"} {
+			if strings.Contains(out, unwanted) {
+				t.Errorf("%s: unexpected %q in %q", name, unwanted, out)
+			}
+		}
+	}
+}
+
+func TestLevelFromEnv(t *testing.T) {
+	defer ResetLogger()
+
+	const levelTrace = slog.Level(-8)
+	RegisterLevel(levelTrace, "TRACE", "")
+
+	for _, c := range []struct {
+		in   string
+		want slog.Level
+	}{
+		{"warn", slog.LevelWarn},
+		{"DEBUG-4", levelTrace},
+		{"trace", levelTrace},
+		{"Info+2", slog.LevelInfo + 2},
+	} {
+		if got, err := ParseLevel(c.in); err != nil || got != c.want {
+			t.Errorf("ParseLevel(%q): expected %v, got %v (%v)", c.in, c.want, got, err)
+		}
+	}
+
+	t.Setenv(LevelEnv, "warn")
+
+	var buf bytes.Buffer
+	InitLogger(Options{W: &buf, Level: slog.LevelDebug})
+	slog.Info("hidden")
+	slog.Warn("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("Expected LOG_LEVEL to override Options.Level, got %q", buf.String())
+	}
+
+	// LevelVar сохраняется для изменения уровня во время работы
+	level := new(slog.LevelVar)
+	InitDevLogger(Options{W: &buf, Level: level})
+	if level.Level() != slog.LevelWarn {
+		t.Errorf("Expected LevelVar to be set from LOG_LEVEL, got %v", level.Level())
+	}
+
+	// некорректная переменная: уровень из кода и одна строка в stderr
+	var diag bytes.Buffer
+	stderr = &diag
+	defer func() { stderr = os.Stderr }()
+	t.Setenv(LevelEnv, "loud")
+	buf.Reset()
+	InitLogger(Options{W: &buf, Level: slog.LevelInfo})
+	slog.Debug("hidden")
+	slog.Info("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("Expected fallback to Options.Level, got %q", buf.String())
+	}
+	if got := diag.String(); strings.Count(got, "\n") != 1 || !strings.Contains(got, `"loud"`) || !strings.Contains(got, "INFO") {
+		t.Errorf("Expected one diagnostic line, got %q", got)
+	}
+}
+
+func TestRequestBudget(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), Options{RequestBudget: 3}))
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), "request_id", "r1"))
+	for i := range 10 {
+		log.InfoContext(ctx, "retry", "attempt", i)
+	}
+	// записи без request ID бюджетом не ограничиваются
+	log.Info("other")
+
+	if n := strings.Count(buf.String(), `"msg":"retry"`); n != 3 {
+		t.Errorf("Expected 3 records within budget, got %d: %s", n, buf.String())
+	}
+
+	// отчет выводится после завершения запроса перед следующей записью
+	cancel()
+	for range 100 {
+		if strings.Contains(buf.String(), "log budget exceeded") {
+			break
+		}
+		time.Sleep(time.Millisecond)
+		log.Info("flush")
+	}
+	if !strings.Contains(buf.String(), `"msg":"log budget exceeded","request_id":"r1","suppressed":7`) {
+		t.Errorf("Expected budget report with 7 suppressed records, got %s", buf.String())
+	}
+}
+
+func TestDedupAttrsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewHandlerMiddleware(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}), Options{DedupAttrs: true}))
+
+	log.With("user", 1, slog.Group("req", "id", "a")).With("user", 2).
+		WithGroup("req").With("path", "/").Info("done", "id", "b", "path", "/x")
+
+	want := `{"level":"INFO","msg":"done","req":{"id":"b","path":"/x"},"user":2}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %s, got %s", want, buf.String())
+	}
+}
+
+func TestBridgeWriter(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), Options{Source: true, Level: slog.LevelInfo})
+	w := NewBridgeWriter(h, ZapFormat)
+
+	// строка zap приходит двумя частями
+	line := `{"level":"warn","ts":1704112200.5,"caller":"app/main.go:42","msg":"retry","attempt":3,"ratio":0.5,"req":{"id":"r1"}}` + "\n"
+	_, _ = w.Write([]byte(line[:30]))
+	if buf.Len() != 0 {
+		t.Fatalf("Expected partial line to be buffered, got %s", buf.String())
+	}
+	_, _ = w.Write([]byte(line[30:] + `{"level":"debug","msg":"hidden"}` + "\nplain text\n"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %s", len(lines), buf.String())
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatal(err)
+	}
+	src, _ := m["source"].(map[string]any)
+	req, _ := m["req"].(map[string]any)
+	ts, _ := time.Parse(time.RFC3339Nano, m["time"].(string))
+	if m["level"] != "WARN" || m["msg"] != "retry" || m["attempt"] != float64(3) || m["ratio"] != 0.5 ||
+		req["id"] != "r1" || src["file"] != "app/main.go" || src["line"] != float64(42) || !ts.Equal(time.Unix(1704112200, 5e8)) {
+		t.Errorf("Unexpected bridged record: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"level":"INFO","msg":"plain text"`) {
+		t.Errorf("Expected non-JSON line as Info message, got %s", lines[1])
+	}
+
+	// строка без перевода строки не копится больше maxBridgeLine
+	buf.Reset()
+	chunk := bytes.Repeat([]byte("x"), 1<<10)
+	for range maxBridgeLine/len(chunk) + 1 {
+		_, _ = w.Write(chunk)
+	}
+	if bw := w.(*bridgeWriter); len(bw.tail) > maxBridgeLine || buf.Len() == 0 {
+		t.Errorf("Expected long line flushed, tail %d bytes", len(bw.tail))
+	}
+
+	if FromLogrusLevel(3) != slog.LevelWarn || FromLogrusLevel(6) != slog.Level(-8) || FromLogrusLevel(1) != slog.Level(12) {
+		t.Error("Unexpected logrus level mapping")
+	}
+}
+
+func TestFromZapConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	var cfg ZapConfig
+	data := `{"level":"warn","encoding":"json","outputPaths":["` + path + `"],"initialFields":{"service":"billing"}}`
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	l, closeLog, err := FromZapConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("hidden")
+	l.Warn("disk almost full")
+
+	out, _ := os.ReadFile(path)
+	if strings.Contains(string(out), "hidden") || !strings.Contains(string(out), `"msg":"disk almost full","service":"billing"`) {
+		t.Errorf("Unexpected output: %s", out)
+	}
+
+	// функция закрытия закрывает открытый файл
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+	l.Warn("after close")
+	if out, _ := os.ReadFile(path); strings.Contains(string(out), "after close") {
+		t.Errorf("Expected output file closed, got: %s", out)
+	}
+
+	if _, _, err := FromZapConfig(ZapConfig{Level: "loud"}); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+
+	// без OutputPaths — stderr, как в zap, в том числе для dev логера
+	l, closeLog, err = FromZapConfig(ZapConfig{Development: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closeLog(); err != nil {
+		t.Errorf("Expected no-op close for stderr, got %v", err)
+	}
+	if w := l.Handler().(*handlerTextColor).w; w != os.Stderr {
+		t.Errorf("Expected stderr by default, got %v", w)
+	}
+}
+
+func TestMiddlewareGroups(t *testing.T) {
+	// цепочки With/WithGroup должны давать тот же JSON, что и сам JSONHandler
+	chains := map[string]func(l *slog.Logger) *slog.Logger{
+		"none":        func(l *slog.Logger) *slog.Logger { return l },
+		"group":       func(l *slog.Logger) *slog.Logger { return l.WithGroup("a") },
+		"attrs group": func(l *slog.Logger) *slog.Logger { return l.With("root", 1).WithGroup("a").With("x", 2) },
+		"nested": func(l *slog.Logger) *slog.Logger {
+			return l.WithGroup("a").With("x", 1).WithGroup("b").With("y", 2).WithGroup("")
+		},
+		"empty group": func(l *slog.Logger) *slog.Logger { return l.With("root", 1).WithGroup("a").WithGroup("b") },
+	}
+
+	decode := func(buf *bytes.Buffer) map[string]any {
+		var m map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatalf("Invalid JSON %q: %v", buf.String(), err)
+		}
+		delete(m, "time")
+		return m
+	}
+
+	for name, chain := range chains {
+		for _, attrs := range [][]any{nil, {"k", "v", slog.Group("g", "n", 1)}} {
+			var want, got bytes.Buffer
+			chain(slog.New(slog.NewJSONHandler(&want, nil))).Info("msg", attrs...)
+			chain(slog.New(NewHandlerMiddleware(slog.NewJSONHandler(&got, nil), Options{}))).Info("msg", attrs...)
+
+			if w, g := decode(&want), decode(&got); fmt.Sprint(w) != fmt.Sprint(g) {
+				t.Errorf("%s %v: expected %v, got %v", name, attrs, w, g)
+			}
+		}
+	}
+
+	// значения контекста добавляются в корень или в InjectedGroup, а не в группу логера
+	ctx := context.WithValue(context.Background(), "request_id", "r1")
+	for _, c := range []struct {
+		group string
+		want  string
+	}{
+		{"", `"a":{"x":2,"k":"v"},"request_id":"r1"}`},
+		{"ctx", `"a":{"x":2,"k":"v"},"ctx":{"request_id":"r1"}}`},
+	} {
+		var buf bytes.Buffer
+		h := NewHandlerMiddleware(slog.NewJSONHandler(&buf, nil), Options{AddCxtAttr: []string{"request_id"}, InjectedGroup: c.group})
+		slog.New(h).WithGroup("a").With("x", 2).InfoContext(ctx, "msg", "k", "v")
+
+		if !strings.HasSuffix(strings.TrimSpace(buf.String()), c.want) {
+			t.Errorf("InjectedGroup %q: expected suffix %s, got %s", c.group, c.want, buf.String())
+		}
+	}
+}
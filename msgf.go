@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// Logf пишет запись в стиле Printf, сохраняя шаблон сообщением записи:
+// записи с разными значениями группируются по одному сообщению, а значения
+// выводятся атрибутами arg0, arg1... с исходным типом. Имя атрибута можно
+// задать в плейсхолдере: %{user}d. Аргументы сверх плейсхолдеров
+// разбираются как пары ключ-значение slog:
+//
+//	logger.Infof(ctx, "user %{user}d logged in from %s", id, ip, "attempt", n)
+//	// JSON: {"msg":"user %{user}d logged in from %s","user":42,"arg1":"10.0.0.1","attempt":1}
+//	// dev:  INFO user 42 logged in from 10.0.0.1 attempt=1
+//
+// Dev обработчик выводит сообщение с подставленными значениями и не
+// повторяет их атрибутами.
+func Logf(ctx context.Context, level slog.Level, format string, args ...any) {
+	logf(ctx, level, format, args)
+}
+
+func Debugf(ctx context.Context, format string, args ...any) {
+	logf(ctx, slog.LevelDebug, format, args)
+}
+
+func Infof(ctx context.Context, format string, args ...any) {
+	logf(ctx, slog.LevelInfo, format, args)
+}
+
+func Warnf(ctx context.Context, format string, args ...any) {
+	logf(ctx, slog.LevelWarn, format, args)
+}
+
+func Errorf(ctx context.Context, format string, args ...any) {
+	logf(ctx, slog.LevelError, format, args)
+}
+
+type msgfKey struct{}
+
+// msgfTemplate — шаблон записи Logf для dev обработчика
+type msgfTemplate struct {
+	// шаблон fmt без имен плейсхолдеров
+	format string
+	args   []any
+	// имена атрибутов, уже выведенных в сообщении
+	names map[string]struct{}
+}
+
+// logf вызывается только из Logf и его вариантов: источник — их вызывающий
+func logf(ctx context.Context, level slog.Level, format string, args []any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	h := slog.Default().Handler()
+	if !h.Enabled(ctx, level) {
+		return
+	}
+
+	t, attrs := parseMsgf(format, args)
+	ctx = context.WithValue(ctx, msgfKey{}, t)
+
+	var pcs [1]uintptr
+	if sourceCapture(h) {
+		runtime.Callers(3, pcs[:]) // пропустить Callers, logf и Logf
+	}
+
+	r := slog.NewRecord(time.Now(), level, format, pcs[0])
+	r.AddAttrs(attrs...)
+	_ = h.Handle(ctx, r)
+}
+
+// parseMsgf разбирает плейсхолдеры format: каждый глагол fmt, кроме %%,
+// забирает один аргумент. Возвращает шаблон и атрибуты: значения
+// плейсхолдеров, затем оставшиеся аргументы как пары ключ-значение
+func parseMsgf(format string, args []any) (*msgfTemplate, []slog.Attr) {
+	t := &msgfTemplate{names: map[string]struct{}{}}
+
+	var (
+		b     strings.Builder
+		attrs []slog.Attr
+		n     int
+	)
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		b.WriteByte(c)
+		if c != '%' {
+			continue
+		}
+
+		if i+1 < len(format) && format[i+1] == '%' {
+			b.WriteByte('%')
+			i++
+			continue
+		}
+
+		name := ""
+		if i+1 < len(format) && format[i+1] == '{' {
+			if end := strings.IndexByte(format[i+1:], '}'); end > 0 {
+				name = format[i+2 : i+1+end]
+				i += end + 1
+			}
+		}
+
+		// флаги, ширина и точность до глагола
+		for i+1 < len(format) && strings.IndexByte("+-# 0123456789.", format[i+1]) >= 0 {
+			b.WriteByte(format[i+1])
+			i++
+		}
+		if i+1 >= len(format) {
+			break
+		}
+		b.WriteByte(format[i+1])
+		i++
+
+		if n < len(args) {
+			if name == "" {
+				name = "arg" + strconv.Itoa(n)
+			}
+			attrs = append(attrs, slog.Any(name, args[n]))
+			t.names[name] = struct{}{}
+			n++
+		}
+	}
+
+	t.format = b.String()
+	t.args = args[:n]
+
+	return t, append(attrs, core.ArgsToAttrs(args[n:])...)
+}
+
+// text возвращает сообщение с подставленными значениями
+func (t *msgfTemplate) text() string {
+	return fmt.Sprintf(t.format, t.args...)
+}
+
+// msgfFrom возвращает шаблон записи Logf
+func msgfFrom(ctx context.Context) (*msgfTemplate, bool) {
+	t, ok := ctx.Value(msgfKey{}).(*msgfTemplate)
+	return t, ok
+}
@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Время ожидания подключения NetworkWriter
+const dialTimeout = 5 * time.Second
+
+// Время ожидания записи NetworkWriter по умолчанию
+const DefaultNetworkWriteTimeout = 5 * time.Second
+
+var ErrNetworkWriterClosed = errors.New("logger: network writer is closed")
+
+// NetworkWriter пишет вывод логера в TCP или unix соединение. Соединение
+// устанавливается при первой записи. После ошибки записи соединение
+// закрывается: запись с ошибкой теряется, следующая подключается заново.
+// Так же обрабатывается зависший получатель: запись, не завершенная за
+// WriteTimeout, теряется, а соединение переустанавливается.
+// С Compressor каждое соединение — законченный поток сжатия, получатель
+// распаковывает соединения по отдельности.
+//
+//	w := logger.NewNetworkWriter("tcp", "collector:5170", zstd.New(1))
+//	defer w.Close()
+//	logger.InitLogger(logger.Options{W: w, FlushLevel: slog.LevelError})
+type NetworkWriter struct {
+	// Предельное время одной записи или Flush, 0 — без ограничения.
+	// Задается до первой записи, по умолчанию DefaultNetworkWriteTimeout
+	WriteTimeout time.Duration
+
+	mu      sync.Mutex
+	network string
+	addr    string
+	c       Compressor
+	conn    net.Conn
+	// поток сжатия поверх conn, nil без Compressor
+	cw     *CompressWriter
+	closed bool
+}
+
+func NewNetworkWriter(network, addr string, c Compressor) *NetworkWriter {
+	return &NetworkWriter{network: network, addr: addr, c: c, WriteTimeout: DefaultNetworkWriteTimeout}
+}
+
+// deadline ограничивает следующую запись в соединение WriteTimeout
+func (nw *NetworkWriter) deadline() {
+	if nw.WriteTimeout > 0 {
+		nw.conn.SetWriteDeadline(time.Now().Add(nw.WriteTimeout))
+	}
+}
+
+func (nw *NetworkWriter) connect() error {
+	conn, err := net.DialTimeout(nw.network, nw.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+
+	if nw.c != nil {
+		if nw.cw == nil {
+			nw.cw, err = NewCompressWriter(conn, nw.c)
+		} else {
+			// прежний поток уже поврежден, его хвост не записывается
+			err = nw.cw.Reset(conn)
+		}
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	nw.conn = conn
+	return nil
+}
+
+func (nw *NetworkWriter) Write(p []byte) (int, error) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	if nw.closed {
+		return 0, ErrNetworkWriterClosed
+	}
+	if nw.conn == nil {
+		if err := nw.connect(); err != nil {
+			return 0, err
+		}
+	}
+
+	var w io.Writer = nw.conn
+	if nw.cw != nil {
+		w = nw.cw
+	}
+
+	nw.deadline()
+	n, err := w.Write(p)
+	if err != nil {
+		nw.disconnect()
+	}
+	return n, err
+}
+
+// Flush сбрасывает сжатые данные в соединение
+func (nw *NetworkWriter) Flush() error {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	if nw.closed {
+		return ErrNetworkWriterClosed
+	}
+	if nw.conn == nil || nw.cw == nil {
+		return nil
+	}
+
+	nw.deadline()
+	err := nw.cw.Flush()
+	if err != nil {
+		nw.disconnect()
+	}
+	return err
+}
+
+// disconnect закрывает соединение после ошибки
+func (nw *NetworkWriter) disconnect() {
+	nw.conn.Close()
+	nw.conn = nil
+}
+
+// Close завершает поток сжатия и закрывает соединение
+func (nw *NetworkWriter) Close() error {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	if nw.closed {
+		return ErrNetworkWriterClosed
+	}
+	nw.closed = true
+
+	if nw.conn == nil {
+		return nil
+	}
+
+	var err error
+	if nw.cw != nil {
+		nw.deadline()
+		err = nw.cw.Close()
+	}
+	return errors.Join(err, nw.conn.Close())
+}
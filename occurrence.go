@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Максимальное количество запоминаемых отпечатков, после него набор сбрасывается
+const maxOccurrences = 10000
+
+// occurrences запоминает уже встречавшиеся отпечатки записей
+type occurrences struct {
+	mu     sync.Mutex
+	window time.Duration
+	start  time.Time
+	seen   map[string]struct{}
+}
+
+func newOccurrences(window time.Duration) *occurrences {
+	return &occurrences{
+		window: window,
+		start:  time.Now(),
+		seen:   make(map[string]struct{}),
+	}
+}
+
+// first сообщает, встретился ли отпечаток впервые в текущем окне
+func (o *occurrences) first(fp string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.window > 0 && time.Since(o.start) >= o.window || len(o.seen) >= maxOccurrences {
+		clear(o.seen)
+		o.start = time.Now()
+	}
+
+	if _, ok := o.seen[fp]; ok {
+		return false
+	}
+	o.seen[fp] = struct{}{}
+
+	return true
+}
+
+// recordFingerprint — отпечаток записи: уровень и сообщение,
+// а для SQL без сообщения — нормализованный запрос
+func recordFingerprint(ctx context.Context, r slog.Record) string {
+	msg := r.Message
+	if sql, ok := ctx.Value(Sql).(string); ok && msg == "" {
+		msg = sqlFingerprint(sql)
+	}
+
+	return r.Level.String() + " " + msg
+}
+
+func (h *handlerTextColor) appendNewBadge(buf *buffer) {
+	buf.WriteString(h.theme.Badge)
+	buf.WriteString(" NEW ")
+	buf.WriteString(h.theme.reset)
+	buf.WriteByte(' ')
+}
@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// ApplyDefaults заполняет незаданные настройки значениями по умолчанию:
+// W — os.Stderr, как у NewLogger и NewDevLogger, SlowThreshold — 1 секунда, TimeFormat — time.TimeOnly,
+// SampleReportInterval — 10 секунд при включенном сэмплировании.
+func (o *Options) ApplyDefaults() {
+	if o.W == nil {
+		o.W = os.Stderr
+	}
+	if o.SlowThreshold == 0 {
+		o.SlowThreshold = time.Second
+	}
+	if o.TimeFormat == "" {
+		o.TimeFormat = time.TimeOnly
+	}
+	if o.SampleRate > 0 && o.SampleReportInterval == 0 {
+		o.SampleReportInterval = 10 * time.Second
+	}
+}
+
+// Validate проверяет настройки и возвращает все найденные ошибки сразу
+func (o Options) Validate() error {
+	var errs []error
+
+	if o.W == nil {
+		errs = append(errs, ErrNilWriter)
+	}
+
+	for _, n := range []struct {
+		name  string
+		value int64
+	}{
+		{"SlowThreshold", int64(o.SlowThreshold)},
+		{"InListThreshold", int64(o.InListThreshold)},
+		{"MaxSQLLength", int64(o.MaxSQLLength)},
+		{"FirstOccurrenceWindow", int64(o.FirstOccurrenceWindow)},
+		{"SampleRate", int64(o.SampleRate)},
+		{"SampleReportInterval", int64(o.SampleReportInterval)},
+		{"CardinalityLimit", int64(o.CardinalityLimit)},
+		{"CardinalityTruncate", int64(o.CardinalityTruncate)},
+		{"CardinalityKeyLimit", int64(o.CardinalityKeyLimit)},
+		{"RequestBudget", int64(o.RequestBudget)},
+		{"RequestBudgetBytes", int64(o.RequestBudgetBytes)},
+		{"GroupCompactThreshold", int64(o.GroupCompactThreshold)},
+		{"FoldValues", int64(o.FoldValues)},
+		{"MaxValueLen", int64(o.MaxValueLen)},
+		{"LargeRecord", int64(o.LargeRecord)},
+		{"SliceItems", int64(o.SliceItems)},
+		{"CanonicalPrecision", int64(o.CanonicalPrecision)},
+		{"LevelWidth", int64(o.LevelWidth)},
+		{"SourceWidth", int64(o.SourceWidth)},
+	} {
+		if n.value < 0 {
+			errs = append(errs, fmt.Errorf("logger: Options.%s is negative", n.name))
+		}
+	}
+
+	if err := checkTimeFormat(o.TimeFormat); err != nil {
+		errs = append(errs, err)
+	}
+
+	if o.InvalidUTF8 > UTF8Raw {
+		errs = append(errs, fmt.Errorf("logger: unknown Options.InvalidUTF8 mode %d", o.InvalidUTF8))
+	}
+	if o.SourcePath < SourcePathTrimmed || o.SourcePath > SourcePathFileOnly {
+		errs = append(errs, fmt.Errorf("logger: unknown Options.SourcePath mode %d", o.SourcePath))
+	}
+	if o.Icons < IconsOff || o.Icons > IconsOnly {
+		errs = append(errs, fmt.Errorf("logger: unknown Options.Icons mode %d", o.Icons))
+	}
+	if o.TimeMode < TimeClock || o.TimeMode > TimeClockDelta {
+		errs = append(errs, fmt.Errorf("logger: unknown Options.TimeMode %d", o.TimeMode))
+	}
+	if o.GroupStyle < GroupDots || o.GroupStyle > GroupIndent {
+		errs = append(errs, fmt.Errorf("logger: unknown Options.GroupStyle %d", o.GroupStyle))
+	}
+	if o.Background < BackgroundAuto || o.Background > BackgroundQuery {
+		errs = append(errs, fmt.Errorf("logger: unknown Options.Background %d", o.Background))
+	}
+
+	// настройки, которые действуют только вместе с другими
+	if o.FirstOccurrenceWindow > 0 && !o.FirstOccurrence {
+		errs = append(errs, errors.New("logger: Options.FirstOccurrenceWindow is set but FirstOccurrence is disabled"))
+	}
+	if o.SampleReportInterval > 0 && o.SampleRate == 0 {
+		errs = append(errs, errors.New("logger: Options.SampleReportInterval is set but SampleRate is 0"))
+	}
+	for _, kc := range o.KeyColors {
+		if _, err := path.Match(kc.Pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("logger: Options.KeyColors pattern %q: %w", kc.Pattern, err))
+		}
+	}
+	if o.ForceColor && o.DisableColor {
+		errs = append(errs, ErrColorConflict)
+	}
+	if o.LargeRecord > 0 && !o.SizeStats {
+		errs = append(errs, errors.New("logger: Options.LargeRecord is set but SizeStats is disabled"))
+	}
+	if d := o.Digest; d != nil {
+		if d.URL == "" {
+			errs = append(errs, errors.New("logger: Options.Digest.URL is empty"))
+		}
+		if d.Interval < 0 || d.Top < 0 {
+			errs = append(errs, errors.New("logger: Options.Digest has negative Interval or Top"))
+		}
+	}
+	if o.CardinalityTruncate > 0 && o.CardinalityLimit == 0 && o.CardinalityKeyLimit == 0 {
+		errs = append(errs, errors.New("logger: Options.CardinalityTruncate is set but CardinalityLimit and CardinalityKeyLimit are 0"))
+	}
+
+	for i, rule := range o.LevelRules {
+		if rule.Before > 0 && rule.Before <= rule.After {
+			errs = append(errs, fmt.Errorf("logger: LevelRules[%d]: Before must be greater than After", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkTimeFormat проверяет, что в формате времени есть хотя бы один
+// элемент макета Go (15:04:05, 2006-01-02 ...)
+func checkTimeFormat(layout string) error {
+	if layout == "" {
+		return nil
+	}
+
+	// ни одно поле не совпадает с эталонным временем макета
+	t := time.Date(2011, 3, 9, 8, 7, 9, 8e8, time.FixedZone("X", 3*3600))
+	if t.Format(layout) == layout {
+		return fmt.Errorf("logger: TimeFormat %q contains no time layout elements", layout)
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+// Package otelslog переносит счетчики SQL запросов gorm логера пакета
+// github.com/bairto15/slog_gorm_color в span OpenTelemetry. Отдельный
+// модуль: приложения без трассировки не зависят от go.opentelemetry.io/otel.
+//
+// Span провайдера NewTracerProvider сами считают запросы, выполненные с
+// их контекстом, и при End получают атрибуты db.sql_count и db.time_ms.
+// Start дополнительно пишет записи Span логера: итоговая запись "end"
+// содержит те же sql_count и db_time.
+//
+//	otel.SetTracerProvider(otelslog.NewTracerProvider(sdkProvider))
+//	handler := otelhttp.NewHandler(mux, "api") // стоимость запроса в БД у каждого span
+package otelslog
@@ -0,0 +1,93 @@
+package otelslog
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+)
+
+// Атрибуты span с числом SQL запросов и их общим временем в миллисекундах
+const (
+	SQLCountKey = attribute.Key("db.sql_count")
+	DBTimeKey   = attribute.Key("db.time_ms")
+)
+
+// NewTracerProvider оборачивает tp: каждый span считает SQL запросы своего
+// контекста (вложенные span входят в счетчик внешних) и перед End
+// получает атрибуты SQLCountKey и DBTimeKey, если запросы были
+func NewTracerProvider(tp trace.TracerProvider) trace.TracerProvider {
+	return &tracerProvider{tp: tp}
+}
+
+type tracerProvider struct {
+	embedded.TracerProvider
+	tp trace.TracerProvider
+}
+
+func (p *tracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &tracer{t: p.tp.Tracer(name, opts...)}
+}
+
+type tracer struct {
+	embedded.Tracer
+	t trace.Tracer
+}
+
+func (t *tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	ctx, s := t.t.Start(ctx, name, opts...)
+	ctx = slogcolor.WithSQLCounter(ctx)
+
+	cs := &countingSpan{Span: s, ctx: ctx}
+	// trace.SpanFromContext(ctx).End() тоже переносит счетчики
+	return trace.ContextWithSpan(ctx, cs), cs
+}
+
+// countingSpan — span со счетчиком SQL в контексте
+type countingSpan struct {
+	trace.Span
+	ctx context.Context
+}
+
+func (s *countingSpan) End(opts ...trace.SpanEndOption) {
+	setSQLAttrs(s.ctx, s.Span)
+	s.Span.End(opts...)
+}
+
+// Start начинает span tracer и участок Span логера с тем же именем.
+// Функция завершения пишет запись "end" с sql_count и db_time, переносит
+// счетчики в span и завершает его, ошибка отмечается в обоих:
+//
+//	ctx, end := otelslog.Start(ctx, tracer, "checkout")
+//	defer func() { end(err) }()
+func Start(ctx context.Context, tracer trace.Tracer, name string, opts ...trace.SpanStartOption) (context.Context, func(err error)) {
+	ctx, span := tracer.Start(ctx, name, opts...)
+	ctx, endLog := slogcolor.Span(ctx, name)
+
+	return ctx, func(err error) {
+		endLog(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		// у span NewTracerProvider атрибуты уже заданы его счетчиком
+		if _, ok := span.(*countingSpan); !ok {
+			setSQLAttrs(ctx, span)
+		}
+		span.End()
+	}
+}
+
+func setSQLAttrs(ctx context.Context, span trace.Span) {
+	n, d, _ := slogcolor.SQLCount(ctx)
+	if n == 0 {
+		return
+	}
+	span.SetAttributes(SQLCountKey.Int(n), DBTimeKey.Float64(float64(d)/float64(time.Millisecond)))
+}
@@ -0,0 +1,105 @@
+package otelslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+func spanAttrs(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestTracerProvider(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tracer := NewTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))).Tracer("test")
+
+	ctx, req := tracer.Start(context.Background(), "request")
+	core.CountSQL(ctx, 2*time.Millisecond)
+
+	// запросы вложенного span входят и в счетчик внешнего
+	child, _ := tracer.Start(ctx, "load")
+	core.CountSQL(child, 3*time.Millisecond)
+	trace.SpanFromContext(child).End()
+
+	_, empty := tracer.Start(ctx, "cache")
+	empty.End()
+	req.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 ended spans, got %d", len(spans))
+	}
+	for _, c := range []struct {
+		span  sdktrace.ReadOnlySpan
+		count int64
+		ms    float64
+	}{
+		{spans[0], 1, 3},
+		{spans[2], 2, 5},
+	} {
+		attrs := spanAttrs(c.span)
+		if attrs[SQLCountKey].AsInt64() != c.count || attrs[DBTimeKey].AsFloat64() != c.ms {
+			t.Errorf("Expected %s with %d queries in %vms, got %v", c.span.Name(), c.count, c.ms, attrs)
+		}
+	}
+	if _, ok := spanAttrs(spans[1])[SQLCountKey]; ok {
+		t.Errorf("Expected no SQL attributes on span without queries, got %v", spans[1].Attributes())
+	}
+}
+
+func TestStart(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := slogcolor.NewLogger(slogcolor.Options{W: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(log)
+	defer slog.SetDefault(prev)
+
+	rec := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test")
+
+	ctx, end := Start(context.Background(), tracer, "checkout")
+	core.CountSQL(ctx, 4*time.Millisecond)
+	end(errors.New("declined"))
+
+	// итоговая запись логера и span получают одни и те же счетчики
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var m map[string]any
+	if err := json.Unmarshal(lines[len(lines)-1], &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["msg"] != "end checkout" || m[slogcolor.SqlCount] != float64(1) {
+		t.Errorf("Expected end record with sql_count, got %v", m)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 ended span, got %d", len(spans))
+	}
+	if attrs := spanAttrs(spans[0]); attrs[SQLCountKey].AsInt64() != 1 || attrs[DBTimeKey].AsFloat64() != 4 {
+		t.Errorf("Expected SQL attributes on span, got %v", attrs)
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("Expected error status, got %v", spans[0].Status())
+	}
+}
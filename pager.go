@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Pager накапливает вывод и при Close показывает его через $PAGER
+// (по умолчанию less -R) с сохранением цветов. Если out не терминал
+// или пейджер не запустился, накопленный вывод просто копируется в out.
+//
+//	p := logger.NewPager(os.Stdout)
+//	defer p.Close()
+//	logger.InitDevLogger(logger.Options{W: p})
+type Pager struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	out    io.Writer
+	closed bool
+}
+
+func NewPager(out io.Writer) *Pager {
+	return &Pager{out: out}
+}
+
+func (p *Pager) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return p.out.Write(b)
+	}
+
+	return p.buf.Write(b)
+}
+
+// Close показывает накопленный вывод. После Close запись идет напрямую в out.
+func (p *Pager) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	if p.buf.Len() == 0 {
+		return nil
+	}
+
+	f, ok := p.out.(*os.File)
+	if !ok || !isCharDevice(f) {
+		_, err := p.out.Write(p.buf.Bytes())
+		return err
+	}
+
+	pager := pagerCommand()
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = bytes.NewReader(p.buf.Bytes())
+	cmd.Stdout = f
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if os.Getenv("LESS") == "" {
+		// как в git: выйти, если вывод помещается на экран, и сохранить цвета
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+
+	if err := cmd.Run(); err != nil {
+		_, err = p.out.Write(p.buf.Bytes())
+		return err
+	}
+
+	return nil
+}
+
+// pagerCommand возвращает команду пейджера из $PAGER, по умолчанию less -R
+func pagerCommand() []string {
+	if pager := strings.Fields(os.Getenv("PAGER")); len(pager) > 0 {
+		return pager
+	}
+	return []string{"less", "-R"}
+}
+
+func isCharDevice(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// truecolorSupported — терминал сообщает о поддержке 24-битных цветов
+func truecolorSupported() bool {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return true
+	}
+	return false
+}
+
+var rgbCode = regexp.MustCompile(`([34])8;2;(\d{1,3});(\d{1,3});(\d{1,3})`)
+
+// downgradeRGB заменяет 24-битные цвета в коде на ближайшие из палитры 256 цветов
+func downgradeRGB(code string) string {
+	if !strings.Contains(code, "8;2;") {
+		return code
+	}
+
+	return rgbCode.ReplaceAllStringFunc(code, func(m string) string {
+		p := rgbCode.FindStringSubmatch(m)
+		r, _ := strconv.Atoi(p[2])
+		g, _ := strconv.Atoi(p[3])
+		b, _ := strconv.Atoi(p[4])
+		return p[1] + "8;5;" + strconv.Itoa(rgbTo256(r, g, b))
+	})
+}
+
+// rgbTo256 выбирает ближайший цвет из куба 6x6x6 или шкалы серого
+func rgbTo256(r, g, b int) int {
+	cube := func(v int) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (v - 35) / 40
+	}
+
+	ci := 16 + 36*cube(r) + 6*cube(g) + cube(b)
+
+	avg := (r + g + b) / 3
+	gi := 232 + min(max((avg-8)/10, 0), 23)
+
+	dist := func(n int) int {
+		cr, cg, cb := color256RGB(n)
+		return (cr-r)*(cr-r) + (cg-g)*(cg-g) + (cb-b)*(cb-b)
+	}
+
+	if dist(gi) < dist(ci) {
+		return gi
+	}
+	return ci
+}
+
+// Ключевые слова SQL, которые выделяются цветом Theme.SQLKeyword
+var sqlKeywords = map[string]struct{}{}
+
+func init() {
+	for _, kw := range strings.Fields(`SELECT FROM WHERE AND OR NOT IN IS NULL LIKE ILIKE BETWEEN EXISTS
+		INSERT INTO VALUES UPDATE SET DELETE RETURNING ON CONFLICT DO NOTHING
+		JOIN LEFT RIGHT INNER OUTER FULL CROSS AS USING DISTINCT
+		ORDER GROUP BY HAVING LIMIT OFFSET ASC DESC UNION ALL
+		CASE WHEN THEN ELSE END WITH FOR SHARE LOCK`) {
+		sqlKeywords[kw] = struct{}{}
+	}
+}
+
+// appendSQLKeywords выводит SQL цветом base, выделяя ключевые слова цветом kw
+// и сбрасывая его кодом reset. Строковые литералы и идентификаторы в кавычках
+// не разбираются.
+func appendSQLKeywords(buf *buffer, sql, base, kw, reset string) {
+	buf.WriteString(base)
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				buf.WriteString(sql[i:])
+				return
+			}
+			buf.WriteString(sql[i : i+end+2])
+			i += end + 2
+		case core.IsWordByte(c):
+			j := i
+			for j < len(sql) && core.IsWordByte(sql[j]) {
+				j++
+			}
+
+			word := sql[i:j]
+			if _, ok := sqlKeywords[strings.ToUpper(word)]; ok {
+				buf.WriteString(kw)
+				buf.WriteString(word)
+				buf.WriteString(reset)
+				buf.WriteString(base)
+			} else {
+				buf.WriteString(word)
+			}
+			i = j
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+type prefetchKey struct{}
+
+// prefetched — отрисованные значения контекста для каждого dev обработчика
+type prefetched struct {
+	mu      sync.Mutex
+	entries []prefetchEntry
+}
+
+type prefetchEntry struct {
+	// Обработчик и его копии WithAttrs/WithGroup
+	owner *sync.Mutex
+	text  []byte
+}
+
+// Prefetch помечает контекст запроса: значения AddCxtAttr и CtxExtractors
+// выводятся в терминал один раз при первой записи и переиспользуются во
+// всех записях с этим контекстом и производными от него. Вызывайте после
+// того, как в контекст добавлены все значения запроса:
+//
+//	ctx = context.WithValue(ctx, "request_id", id)
+//	ctx = logger.Prefetch(ctx)
+//
+// Значения, добавленные в производный контекст позже, не выводятся.
+// JSON вывод не меняется.
+func Prefetch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, prefetchKey{}, &prefetched{})
+}
+
+// text возвращает значения контекста, отрисованные обработчиком h
+func (p *prefetched) text(ctx context.Context, h *handlerTextColor) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range p.entries {
+		if e.owner == h.mu {
+			return e.text
+		}
+	}
+
+	buf := newBuffer()
+	defer buf.Free()
+	h.appendCtxValues(ctx, buf)
+
+	text := append([]byte(nil), *buf...)
+	p.entries = append(p.entries, prefetchEntry{owner: h.mu, text: text})
+
+	return text
+}
@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// preprocessor — общая для dev обработчика и HandlerMiddleware обработка
+// записи до вывода. Возвращает false, если запись выводить не нужно.
+type preprocessor struct {
+	rules []LevelRule
+	// Минимальный уровень после применения rules. nil — без ограничения
+	level       slog.Leveler
+	routes      *levelRoutes
+	sampler     *sampler
+	cardinality *cardinality
+	budget      *requestBudget
+	stats       *summaryStats
+	digest      *digest
+	strict      bool
+	sizes       *sizeStats
+	missingSQL  atomic.Bool
+
+	clock     func() time.Time
+	monotonic bool
+	lastTime  time.Time
+
+	// служебные записи (отчеты сэмплера и т.п.), которые обработчик
+	// выводит перед текущей записью
+	mu      sync.Mutex
+	pending []slog.Record
+}
+
+func newPreprocessor(opt Options) *preprocessor {
+	p := &preprocessor{
+		rules:     opt.LevelRules,
+		strict:    opt.Strict,
+		clock:     opt.Clock,
+		monotonic: opt.MonotonicTime,
+		routes:    newLevelRoutes(opt.LevelRoutes),
+	}
+
+	if opt.SampleRate > 0 {
+		p.sampler = newSampler(opt.SampleRate, opt.SampleReportInterval, opt.OnPressure)
+	}
+
+	if opt.SizeStats {
+		p.sizes = newSizeStats()
+	}
+
+	if opt.Summary {
+		p.stats = newSummaryStats(opt.slowConfig())
+	}
+
+	if opt.Digest != nil && opt.Digest.URL != "" {
+		p.digest = newDigest(*opt.Digest, opt.slowConfig(), p.notify)
+	}
+
+	if opt.CardinalityLimit > 0 || opt.CardinalityKeyLimit > 0 {
+		p.cardinality = newCardinality(opt.CardinalityLimit, opt.CardinalityKeyLimit, opt.CardinalityTruncate)
+	}
+
+	if opt.RequestBudget > 0 || opt.RequestBudgetBytes > 0 {
+		p.budget = newRequestBudget(opt, p.notify)
+	}
+
+	return p
+}
+
+// process обрабатывает запись логера name (см. LoggerKey)
+func (p *preprocessor) process(ctx context.Context, r *slog.Record, name string) bool {
+	if silenced(ctx, ctx.Value(Sql) != nil) {
+		return false
+	}
+
+	if len(p.rules) > 0 {
+		applyLevelRules(p.rules, r)
+	}
+
+	if r.Level < p.minLevel(ctx, r.PC, name) {
+		return false
+	}
+
+	if p.sampler != nil && !p.sample(r) {
+		return false
+	}
+
+	if p.budget != nil && !p.budget.allow(ctx, r) {
+		return false
+	}
+
+	p.stamp(r)
+
+	p.checkSQLContext(ctx)
+
+	if p.strict {
+		for _, w := range strictCheck(r) {
+			p.notify(w)
+		}
+	}
+
+	if p.cardinality != nil {
+		for _, w := range p.cardinality.guard(r) {
+			p.notify(w)
+		}
+	}
+
+	if p.stats != nil {
+		p.stats.record(ctx, r.Level)
+	}
+
+	if p.digest != nil {
+		p.digest.record(ctx, *r)
+	}
+
+	return true
+}
+
+// minLevel возвращает минимальный уровень записи с учетом LevelRoutes
+func (p *preprocessor) minLevel(ctx context.Context, pc uintptr, name string) slog.Level {
+	def := levelAll
+	if p.level != nil {
+		def = p.level.Level()
+	}
+
+	if p.routes != nil {
+		return p.routes.level(ctx, name, pc, def)
+	}
+
+	return def
+}
+
+// enabled проверяет уровень в Enabled обработчика до создания записи
+func (p *preprocessor) enabled(ctx context.Context, level slog.Level, name string) bool {
+	if silenced(ctx, ctx.Value(Sql) != nil) {
+		return false
+	}
+
+	// правила LevelRules могут поднять уровень записи
+	if len(p.rules) > 0 {
+		return true
+	}
+
+	def := levelAll
+	if p.level != nil {
+		def = p.level.Level()
+	}
+
+	if p.routes != nil {
+		return p.routes.enabled(ctx, name, level, def)
+	}
+
+	return level >= def
+}
+
+// notify ставит служебную запись в очередь на вывод
+func (p *preprocessor) notify(r slog.Record) {
+	p.mu.Lock()
+	p.pending = append(p.pending, r)
+	p.mu.Unlock()
+}
+
+// notices забирает накопленные служебные записи
+func (p *preprocessor) notices() []slog.Record {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	n := p.pending
+	p.pending = nil
+
+	for i := range n {
+		p.stampLocked(&n[i])
+	}
+
+	return n
+}
+
+// stamp заменяет время записи на время Options.Clock и не дает ему
+// уменьшаться при Options.MonotonicTime. Записи без времени не меняются.
+func (p *preprocessor) stamp(r *slog.Record) {
+	if p.clock == nil && !p.monotonic {
+		return
+	}
+
+	p.mu.Lock()
+	p.stampLocked(r)
+	p.mu.Unlock()
+}
+
+func (p *preprocessor) stampLocked(r *slog.Record) {
+	if r.Time.IsZero() {
+		return
+	}
+
+	if p.clock != nil {
+		r.Time = p.clock()
+	}
+
+	if p.monotonic {
+		// сравнение по настенным часам: монотонные показания скрывают
+		// перевод часов назад
+		t := r.Time.Round(0)
+		if t.Before(p.lastTime) {
+			t = p.lastTime
+		}
+		p.lastTime = t
+		r.Time = t
+	}
+}
@@ -0,0 +1,15 @@
+package logger
+
+import "github.com/bairto15/slog_gorm_color/internal/core"
+
+// QueryInfo — выполненный SQL запрос из истории запроса приложения
+type QueryInfo = core.QueryInfo
+
+// GetRecentQueries возвращает последние SQL запросы, выполненные с request ID
+// в контексте, от старых к новым. История ведется gorm логером при
+// gormslog.GormOptions.RecentQueries > 0. Удобно прикладывать к отчету об ошибке:
+//
+//	for _, q := range logger.GetRecentQueries(requestID) { ... }
+func GetRecentQueries(requestID string) []QueryInfo {
+	return core.RecentQueries(requestID)
+}
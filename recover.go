@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// Настройки Recoverer
+type RecoverOptions struct {
+	// Логер для записи о панике, по умолчанию slog.Default()
+	Logger *slog.Logger
+	// Ключ контекста с request ID, по умолчанию "request_id"
+	RequestIDKey string
+	// Заголовок с request ID, если в контексте его нет, по умолчанию "X-Request-ID"
+	RequestIDHeader string
+}
+
+// Recoverer возвращает HTTP middleware, которое при панике в обработчике
+// пишет ошибку со стеком и историей SQL запроса (GetRecentQueries),
+// сбрасывает буферы логера и отвечает 500.
+//
+//	http.ListenAndServe(":8080", logger.Recoverer(logger.RecoverOptions{})(mux))
+func Recoverer(opt RecoverOptions) func(http.Handler) http.Handler {
+	opt.RequestIDKey = cmp.Or(opt.RequestIDKey, "request_id")
+	opt.RequestIDHeader = cmp.Or(opt.RequestIDHeader, "X-Request-ID")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &recoverWriter{ResponseWriter: w}
+
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// соглашение net/http: прерывание ответа без записи в лог
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				l := cmp.Or(opt.Logger, slog.Default())
+				logPanic(r.Context(), l, opt, r, p, debug.Stack())
+				_ = Flush(l)
+
+				if !rw.wroteHeader {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func logPanic(ctx context.Context, l *slog.Logger, opt RecoverOptions, r *http.Request, p any, stack []byte) {
+	attrs := []slog.Attr{
+		slog.String("panic", fmt.Sprint(p)),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+	}
+
+	id := core.RequestID(ctx.Value(opt.RequestIDKey))
+	if id == "" {
+		id = r.Header.Get(opt.RequestIDHeader)
+	}
+
+	if id != "" {
+		attrs = append(attrs, slog.String(opt.RequestIDKey, id))
+
+		if queries := GetRecentQueries(id); len(queries) > 0 {
+			group := make([]any, len(queries))
+			for i, q := range queries {
+				group[i] = slog.Group(strconv.Itoa(i+1),
+					slog.String(Sql, q.SQL),
+					slog.Duration(Duration, q.Duration),
+					slog.Int64(Rows, q.Rows),
+				)
+			}
+			attrs = append(attrs, slog.Group("queries", group...))
+		}
+	}
+
+	attrs = append(attrs, slog.String("stack", string(stack)))
+
+	l.LogAttrs(ctx, slog.LevelError, "panic recovered", attrs...)
+}
+
+// recoverWriter запоминает, начат ли ответ
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoverWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recoverWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recoverWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flusher — обработчик, который умеет сбросить буферы вывода
+type flusher interface {
+	flush() error
+}
+
+// Flush сбрасывает буферы вывода логера l (slog.Default при nil): вызывает
+// Sync или Flush у writer'а, если он их поддерживает (os.File, bufio.Writer)
+func Flush(l *slog.Logger) error {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	if f, ok := l.Handler().(flusher); ok {
+		return f.flush()
+	}
+
+	return nil
+}
+
+func (h *handlerTextColor) flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return flushWriter(h.w)
+}
+
+// flush сбрасывает следующий обработчик, а если он этого не умеет
+// (slog.JSONHandler) — Options.W, в который он пишет
+func (h *HandlerMiddleware) flush() error {
+	if f, ok := h.next.(flusher); ok {
+		return f.flush()
+	}
+
+	if h.w != nil {
+		return flushWriter(h.w)
+	}
+
+	return nil
+}
+
+func flushWriter(w any) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Sync() error }:
+		return f.Sync()
+	}
+
+	return nil
+}
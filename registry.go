@@ -0,0 +1,210 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Установленный через Init* обработчик. Повторные вызовы Init* заменяют
+// обработчик внутри installed, а не устанавливают новый: логеры, полученные
+// до повторной настройки (в том числе через With), начинают писать по-новому.
+var registry struct {
+	mu        sync.Mutex
+	installed *installed
+	// slog.Default и настройки стандартного log до первого вызова Init*:
+	// slog.SetDefault перенаправляет log в установленный обработчик
+	prev       *slog.Logger
+	prevWriter io.Writer
+	prevFlags  int
+	prevPrefix string
+}
+
+type installed struct {
+	handler atomic.Pointer[slog.Handler]
+	gen     atomic.Uint64
+}
+
+// install устанавливает h обработчиком slog.Default или перенастраивает
+// ранее установленный
+func install(h slog.Handler) {
+	// фоновые горутины замененного обработчика останавливаются после
+	// снятия блокировки: последний отчет Digest отправляется по сети
+	if old := swap(h); old != nil {
+		releaseHandler(old)
+	}
+}
+
+// swap устанавливает h и возвращает замененный обработчик Init*
+func swap(h slog.Handler) slog.Handler {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	var old slog.Handler
+	if registry.installed != nil {
+		old = *registry.installed.handler.Load()
+		if sw, ok := slog.Default().Handler().(*swapHandler); ok && sw.root == registry.installed {
+			registry.installed.set(h)
+			return old
+		}
+	} else {
+		registry.prev = slog.Default()
+		registry.prevWriter = log.Writer()
+		registry.prevFlags = log.Flags()
+		registry.prevPrefix = log.Prefix()
+	}
+
+	root := &installed{}
+	root.set(h)
+	registry.installed = root
+
+	slog.SetDefault(slog.New(&swapHandler{root: root}))
+	return old
+}
+
+// releaser — обработчик с фоновыми горутинами: отчеты Options.Digest,
+// чтение клавиш StartKeyboard
+type releaser interface {
+	release() error
+}
+
+func releaseHandler(h slog.Handler) {
+	if r, ok := h.(releaser); ok {
+		_ = r.release()
+	}
+}
+
+// ResetLogger возвращает slog.Default и вывод стандартного log, которые были
+// установлены до первого вызова InitLogger или InitDevLogger, и останавливает
+// фоновые горутины установленного обработчика. Нужен в тестах между разными
+// настройками.
+func ResetLogger() {
+	if old := reset(); old != nil {
+		releaseHandler(old)
+	}
+}
+
+func reset() slog.Handler {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.installed == nil {
+		return nil
+	}
+	old := *registry.installed.handler.Load()
+
+	slog.SetDefault(registry.prev)
+	log.SetOutput(registry.prevWriter)
+	log.SetFlags(registry.prevFlags)
+	log.SetPrefix(registry.prevPrefix)
+
+	registry.installed = nil
+	registry.prev = nil
+	registry.prevWriter = nil
+	return old
+}
+
+func (r *installed) set(h slog.Handler) {
+	r.handler.Store(&h)
+	r.gen.Add(1)
+}
+
+// swapHandler передает записи текущему обработчику installed, применяя к нему
+// цепочку WithAttrs/WithGroup. Результат кэшируется до следующей перенастройки.
+type swapHandler struct {
+	root   *installed
+	parent *swapHandler
+	attrs  []slog.Attr
+	group  string
+
+	cache atomic.Pointer[swapCache]
+}
+
+type swapCache struct {
+	gen uint64
+	h   slog.Handler
+}
+
+func (s *swapHandler) current() slog.Handler {
+	gen := s.root.gen.Load()
+	if c := s.cache.Load(); c != nil && c.gen == gen {
+		return c.h
+	}
+
+	var h slog.Handler
+	switch {
+	case s.parent == nil:
+		h = *s.root.handler.Load()
+	case s.group != "":
+		h = s.parent.current().WithGroup(s.group)
+	default:
+		h = s.parent.current().WithAttrs(s.attrs)
+	}
+
+	s.cache.Store(&swapCache{gen: gen, h: h})
+	return h
+}
+
+func (s *swapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.current().Enabled(ctx, level)
+}
+
+func (s *swapHandler) Handle(ctx context.Context, r slog.Record) error {
+	return s.current().Handle(ctx, r)
+}
+
+func (s *swapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return s
+	}
+	return &swapHandler{root: s.root, parent: s, attrs: attrs}
+}
+
+func (s *swapHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return s
+	}
+	return &swapHandler{root: s.root, parent: s, group: name}
+}
+
+// Shutdown, Pressure, Flush, RecordSizeStats, Batch и DisableSource работают
+// и через swapHandler
+
+func (s *swapHandler) handleBatch(entries []batchEntry) error {
+	return handleEntries(s.current(), entries)
+}
+
+func (s *swapHandler) summary() error {
+	if h, ok := s.current().(summarizer); ok {
+		return h.summary()
+	}
+	return nil
+}
+
+func (s *swapHandler) pressure() float64 {
+	if h, ok := s.current().(pressurer); ok {
+		return h.pressure()
+	}
+	return 0
+}
+
+func (s *swapHandler) flush() error {
+	if h, ok := s.current().(flusher); ok {
+		return h.flush()
+	}
+	return nil
+}
+
+func (s *swapHandler) recordSizes() []RecordSizes {
+	if h, ok := s.current().(sizer); ok {
+		return h.recordSizes()
+	}
+	return nil
+}
+
+func (s *swapHandler) sourceDisabled() bool {
+	return !sourceCapture(s.current())
+}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// RenderRecord возвращает запись в том же цветном виде, что выводит
+// dev обработчик, без записи в Options.W и без завершающего перевода строки.
+// Подходит для встраивания в отчеты об ошибках, TUI и веб-консоли.
+// Состояние между вызовами не хранится: дельты и пометки NEW не выводятся.
+func RenderRecord(ctx context.Context, r slog.Record, opts Options) (string, error) {
+	opts.ApplyDefaults()
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+
+	// сохраняющие состояние режимы не имеют смысла для одной записи
+	opts.DeltaKeys = nil
+	opts.FirstOccurrence = false
+	opts.TimeMode = TimeClock
+	opts.assumeTerminal = true
+	// обработчик одной записи не отправляет отчеты
+	opts.Digest = nil
+
+	h := NewDevHandler(opts).(*handlerTextColor)
+
+	buf := newBuffer()
+	defer buf.Free()
+
+	h.render(ctx, r, buf)
+
+	return strings.TrimSuffix(string(*buf), "\n"), nil
+}
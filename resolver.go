@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"log/slog"
+	"path/filepath"
+	"runtime"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// SourcePathMode — вид пути к файлу источника записи
+type SourcePathMode int
+
+const (
+	// Последний каталог и файл: "repo/user.go"
+	SourcePathTrimmed SourcePathMode = iota
+	// Полный путь: для vendor и сгенерированного кода с одинаковыми
+	// именами каталогов
+	SourcePathFull
+	// Только имя файла
+	SourcePathFileOnly
+)
+
+// sourcePath сокращает путь к файлу согласно mode
+func sourcePath(file string, mode SourcePathMode) string {
+	switch mode {
+	case SourcePathFull:
+		return file
+	case SourcePathFileOnly:
+		return filepath.Base(file)
+	}
+
+	return core.TrimPath(file)
+}
+
+// SourceResolver определяет источник записи по PC. Заменяет стандартное
+// определение через runtime.CallersFrames в обоих обработчиках: таблицы
+// символов, пути после -trimpath, ссылки на файлы в репозитории.
+// Путь к файлу от своего резолвера выводится без сокращения, если
+// Options.SourcePath не задан явно.
+type SourceResolver interface {
+	Resolve(pc uintptr) (slog.Source, bool)
+}
+
+// SourceResolverFunc — функция как SourceResolver
+type SourceResolverFunc func(pc uintptr) (slog.Source, bool)
+
+func (f SourceResolverFunc) Resolve(pc uintptr) (slog.Source, bool) {
+	return f(pc)
+}
+
+// RewriteSourcePath возвращает резолвер, который определяет источник
+// стандартно и заменяет путь к файлу результатом rewrite:
+//
+//	logger.RewriteSourcePath(func(file string) string {
+//		rel, _ := strings.CutPrefix(file, "/src/monorepo/")
+//		return "https://git.example.com/monorepo/blob/main/" + rel
+//	})
+func RewriteSourcePath(rewrite func(file string) string) SourceResolver {
+	return SourceResolverFunc(func(pc uintptr) (slog.Source, bool) {
+		src, ok := resolveSource(nil, pc)
+		if ok {
+			src.File = rewrite(src.File)
+		}
+		return src, ok
+	})
+}
+
+// resolveSource определяет источник резолвером r или через runtime.CallersFrames
+func resolveSource(r SourceResolver, pc uintptr) (slog.Source, bool) {
+	if r != nil {
+		return r.Resolve(pc)
+	}
+
+	if pc == 0 {
+		return slog.Source{}, false
+	}
+
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	if f.File == "" {
+		return slog.Source{}, false
+	}
+
+	return slog.Source{Function: f.Function, File: f.File, Line: f.Line}, true
+}
+
+// sourceDisabler — обработчик, которому не нужен источник записей, см.
+// Options.DisableSource
+type sourceDisabler interface {
+	sourceDisabled() bool
+}
+
+func (h *handlerTextColor) sourceDisabled() bool {
+	return h.disableSource
+}
+
+func (h *HandlerMiddleware) sourceDisabled() bool {
+	return h.disableSource
+}
+
+func init() {
+	core.SourceCapture = sourceCapture
+}
+
+// sourceCapture сообщает, нужен ли обработчику h источник записей:
+// без него не нужно вызывать runtime.Callers
+func sourceCapture(h slog.Handler) bool {
+	d, ok := h.(sourceDisabler)
+	return !ok || !d.sourceDisabled()
+}
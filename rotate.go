@@ -0,0 +1,197 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// RotateOptions — настройки RotatingFile
+type RotateOptions struct {
+	// Размер файла до сжатия, после которого файл ротируется. 0 — 100MB
+	MaxSize int64
+	// Число хранимых копий Path.1 … Path.N, 0 — только текущий файл
+	MaxBackups int
+	// Сжатие файлов, например Gzip(gzip.BestSpeed) или zstd.New(1) из модуля
+	// github.com/bairto15/slog_gorm_color/zstd
+	Compressor Compressor
+}
+
+// Размер файла по умолчанию для ротации
+const defaultRotateSize = 100 << 20
+
+var ErrRotatingFileClosed = errors.New("logger: rotating file is closed")
+
+// RotatingFile — файл лога с ротацией по размеру. При превышении MaxSize
+// файл переименовывается в Path.1, старые копии сдвигаются (.1 → .2),
+// лишние удаляются. С Compressor каждый файл — законченный поток сжатия:
+// ротация завершает поток в старом файле и начинает новый.
+//
+//	f, _ := logger.NewRotatingFile("app.log.gz", logger.RotateOptions{
+//		MaxSize: 50 << 20, MaxBackups: 5, Compressor: logger.Gzip(gzip.BestSpeed),
+//	})
+//	defer f.Close()
+//	logger.InitLogger(logger.Options{W: f, FlushLevel: slog.LevelError})
+type RotatingFile struct {
+	mu   sync.Mutex
+	path string
+	opt  RotateOptions
+	f    *os.File
+	// поток сжатия поверх f, nil без Compressor
+	cw     *CompressWriter
+	size   int64
+	closed bool
+	// поток сжатия поврежден ошибкой записи: следующая запись ротирует файл,
+	// чтобы новый поток не продолжал поврежденный
+	broken bool
+}
+
+// NewRotatingFile открывает файл path для дозаписи. Сжатые форматы
+// допускают дозапись: новый поток продолжает существующий файл.
+func NewRotatingFile(path string, opt RotateOptions) (*RotatingFile, error) {
+	if opt.MaxSize <= 0 {
+		opt.MaxSize = defaultRotateSize
+	}
+
+	r := &RotatingFile{path: path, opt: opt}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	r.size, r.broken = 0, false
+	if fi, err := f.Stat(); err == nil {
+		r.size = fi.Size()
+	}
+
+	if r.opt.Compressor != nil {
+		if r.cw == nil {
+			r.cw, err = NewCompressWriter(f, r.opt.Compressor)
+		} else {
+			err = r.cw.Reset(f)
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	r.f = f
+	return nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, ErrRotatingFileClosed
+	}
+	if r.f == nil {
+		// файл не открылся после ротации: пробуем снова
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.broken || r.size > 0 && r.size+int64(len(p)) > r.opt.MaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	var w io.Writer = r.f
+	if r.cw != nil {
+		w = r.cw
+	}
+
+	n, err := w.Write(p)
+	r.size += int64(n)
+	if err != nil && r.cw != nil {
+		r.broken = true
+	}
+	return n, err
+}
+
+// rotate завершает текущий файл, сдвигает копии и открывает новый файл
+func (r *RotatingFile) rotate() error {
+	// ошибка записи хвоста не мешает ротации: поток в старом файле уже
+	// поврежден, новый файл начинается с чистого потока
+	r.closeFile()
+	r.f = nil
+
+	if r.opt.MaxBackups == 0 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(r.backup(r.opt.MaxBackups))
+		for i := r.opt.MaxBackups - 1; i >= 1; i-- {
+			os.Rename(r.backup(i), r.backup(i+1))
+		}
+		if err := os.Rename(r.path, r.backup(1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return r.open()
+}
+
+func (r *RotatingFile) backup(i int) string {
+	return r.path + "." + strconv.Itoa(i)
+}
+
+// closeFile завершает поток сжатия и закрывает файл
+func (r *RotatingFile) closeFile() error {
+	var err error
+	if r.cw != nil {
+		err = r.cw.Close()
+	}
+	return errors.Join(err, r.f.Close())
+}
+
+// Flush сбрасывает сжатые данные в файл и вызывает Sync
+func (r *RotatingFile) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrRotatingFileClosed
+	}
+	if r.f == nil {
+		return nil
+	}
+	if r.cw != nil {
+		err := r.cw.Flush()
+		if err != nil {
+			r.broken = true
+		}
+		return err
+	}
+	return r.f.Sync()
+}
+
+// Close завершает поток сжатия и закрывает файл
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrRotatingFileClosed
+	}
+	r.closed = true
+
+	if r.f == nil {
+		return nil
+	}
+	err := r.closeFile()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+const (
+	// Атрибут WithAttrs с именем логера для LevelRoute.Logger:
+	//
+	//	billing := slog.With(logger.LoggerKey, "billing")
+	LoggerKey = "logger"
+	// Имя логера записей gorm логера
+	LoggerGorm = "gorm"
+)
+
+// LevelRoute задает минимальный уровень для части записей: записей
+// логера с именем Logger или записей из пакетов с префиксом Package.
+// Маршрут по имени логера важнее маршрута по пакету, из пакетов
+// применяется самый длинный совпавший префикс. Остальные записи
+// фильтруются по Options.Level:
+//
+//	Level: slog.LevelInfo,
+//	LevelRoutes: []logger.LevelRoute{
+//		{Logger: logger.LoggerGorm, Level: slog.LevelDebug},
+//		{Package: "github.com/acme/app/billing", Level: slog.LevelWarn},
+//	},
+type LevelRoute struct {
+	// Значение атрибута LoggerKey из WithAttrs или LoggerGorm
+	Logger string
+	// Префикс пути пакета места вызова
+	Package string
+
+	Level slog.Level
+}
+
+// Уровень, пропускающий все записи
+const levelAll = slog.Level(math.MinInt)
+
+// levelRoutes — таблица LevelRoute
+type levelRoutes struct {
+	names map[string]slog.Level
+	// Маршруты по пакетам от длинного префикса к короткому
+	pkgs   []LevelRoute
+	minPkg slog.Level
+	// PC места вызова → индекс в pkgs или -1
+	pcs sync.Map
+}
+
+func newLevelRoutes(routes []LevelRoute) *levelRoutes {
+	if len(routes) == 0 {
+		return nil
+	}
+
+	rt := &levelRoutes{names: make(map[string]slog.Level), minPkg: slog.Level(math.MaxInt)}
+	for _, r := range routes {
+		if r.Logger != "" {
+			rt.names[r.Logger] = r.Level
+			continue
+		}
+		rt.pkgs = append(rt.pkgs, r)
+		rt.minPkg = min(rt.minPkg, r.Level)
+	}
+
+	slices.SortStableFunc(rt.pkgs, func(a, b LevelRoute) int {
+		return cmp.Compare(len(b.Package), len(a.Package))
+	})
+
+	return rt
+}
+
+// enabled проверяет уровень до создания записи. Если маршрут зависит
+// от места вызова, запись пропускается и проверяется в level
+func (rt *levelRoutes) enabled(ctx context.Context, name string, level, def slog.Level) bool {
+	if l, ok := rt.names[routeName(ctx, name)]; ok {
+		return level >= l
+	}
+
+	if len(rt.pkgs) > 0 && level >= rt.minPkg {
+		return true
+	}
+
+	return level >= def
+}
+
+// level возвращает минимальный уровень для записи логера name с местом вызова pc
+func (rt *levelRoutes) level(ctx context.Context, name string, pc uintptr, def slog.Level) slog.Level {
+	if l, ok := rt.names[routeName(ctx, name)]; ok {
+		return l
+	}
+
+	if len(rt.pkgs) == 0 || pc == 0 {
+		return def
+	}
+
+	if i := rt.pkgRoute(pc); i >= 0 {
+		return rt.pkgs[i].Level
+	}
+
+	return def
+}
+
+func (rt *levelRoutes) pkgRoute(pc uintptr) int {
+	if i, ok := rt.pcs.Load(pc); ok {
+		return i.(int)
+	}
+
+	route := -1
+	if src, ok := resolveSource(nil, pc); ok {
+		for i, r := range rt.pkgs {
+			if strings.HasPrefix(src.Function, r.Package) {
+				route = i
+				break
+			}
+		}
+	}
+
+	rt.pcs.Store(pc, route)
+	return route
+}
+
+// routeName возвращает имя логера из контекста (записи gorm) или name
+func routeName(ctx context.Context, name string) string {
+	if n, ok := core.LoggerName(ctx); ok {
+		return n
+	}
+	return name
+}
+
+// loggerName возвращает имя логера из атрибутов WithAttrs или prev
+func loggerName(attrs []slog.Attr, groups []string, prev string) string {
+	if len(groups) > 0 {
+		return prev
+	}
+
+	for _, a := range attrs {
+		if a.Key == LoggerKey && a.Value.Kind() == slog.KindString {
+			prev = a.Value.String()
+		}
+	}
+
+	return prev
+}
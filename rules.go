@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"errors"
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+var processStart = time.Now()
+
+// LevelRule повышает или понижает уровень записи перед выводом.
+// Все заданные условия должны выполниться; пустые условия не проверяются.
+// Правила проверяются по порядку, применяется первое подходящее.
+//
+//	// отказ соединения в первые 30 секунд после старта — WARN, позже — ERROR
+//	[]LevelRule{
+//		{ErrMessage: regexp.MustCompile("connection refused"), Before: 30 * time.Second, Level: slog.LevelWarn},
+//		{ErrMessage: regexp.MustCompile("connection refused"), Level: slog.LevelError},
+//	}
+type LevelRule struct {
+	// Сообщение записи
+	Message *regexp.Regexp
+	// Ошибка в атрибутах записи (errors.Is)
+	Err error
+	// Текст ошибки в атрибутах записи или сообщение записи уровня Error
+	ErrMessage *regexp.Regexp
+	// Атрибут с ключом Attr и строковым значением AttrValue.
+	// Пустой AttrValue — достаточно наличия атрибута
+	Attr      string
+	AttrValue string
+	// Окно действия правила относительно старта процесса
+	After  time.Duration
+	Before time.Duration
+
+	Level slog.Level
+}
+
+func (rule *LevelRule) match(r *slog.Record, since time.Duration) bool {
+	if since < rule.After || rule.Before > 0 && since >= rule.Before {
+		return false
+	}
+
+	if rule.Message != nil && !rule.Message.MatchString(r.Message) {
+		return false
+	}
+
+	if rule.Err == nil && rule.ErrMessage == nil && rule.Attr == "" {
+		return true
+	}
+
+	errMatched := rule.Err == nil && rule.ErrMessage == nil
+	if !errMatched && rule.Err == nil && r.Level >= slog.LevelError {
+		errMatched = rule.ErrMessage.MatchString(r.Message)
+	}
+	attrMatched := rule.Attr == ""
+
+	r.Attrs(func(a slog.Attr) bool {
+		if !attrMatched && a.Key == rule.Attr {
+			attrMatched = rule.AttrValue == "" || a.Value.Resolve().String() == rule.AttrValue
+		}
+
+		if !errMatched {
+			if err, ok := attrError(a.Value); ok {
+				errMatched = (rule.Err == nil || errors.Is(err, rule.Err)) &&
+					(rule.ErrMessage == nil || rule.ErrMessage.MatchString(err.Error()))
+			}
+		}
+
+		return !(errMatched && attrMatched)
+	})
+
+	return errMatched && attrMatched
+}
+
+func applyLevelRules(rules []LevelRule, r *slog.Record) {
+	since := time.Since(processStart)
+
+	for i := range rules {
+		if rules[i].match(r, since) {
+			r.Level = rules[i].Level
+			return
+		}
+	}
+}
+
+func attrError(v slog.Value) (error, bool) {
+	if v.Kind() != slog.KindAny {
+		return nil, false
+	}
+
+	switch err := v.Any().(type) {
+	case logError:
+		return err.error, true
+	case error:
+		return err, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"log/slog"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+)
+
+// RuntimeKey — группа со статистикой рантайма у записей Error и выше
+const RuntimeKey = "runtime"
+
+// Байты, занятые объектами кучи; чтение runtime/metrics не останавливает мир,
+// в отличие от runtime.ReadMemStats
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// readRuntimeStats возвращает число горутин и объем кучи в байтах
+func readRuntimeStats() (goroutines int, heap uint64) {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+
+	if sample[0].Value.Kind() == metrics.KindUint64 {
+		heap = sample[0].Value.Uint64()
+	}
+
+	return runtime.NumGoroutine(), heap
+}
+
+func runtimeStatsAttr() slog.Attr {
+	goroutines, heap := readRuntimeStats()
+
+	return slog.Group(RuntimeKey,
+		slog.Int("goroutines", goroutines),
+		slog.Uint64("heap_inuse", heap),
+	)
+}
+
+// appendRuntimeStats выводит статистику рантайма бледным цветом:
+// runtime.goroutines=12 runtime.heap_inuse=4.2MB
+func (h *handlerTextColor) appendRuntimeStats(buf *buffer) {
+	goroutines, heap := readRuntimeStats()
+
+	buf.WriteString(h.theme.Faint)
+	buf.WriteString(RuntimeKey + ".goroutines=")
+	*buf = strconv.AppendInt(*buf, int64(goroutines), 10)
+	buf.WriteString(" " + RuntimeKey + ".heap_inuse=")
+	buf.WriteString(formatSize(int(heap)))
+	buf.WriteString(h.theme.reset)
+	buf.WriteByte(' ')
+}
@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Минимальная доля записей, которую пропускает сэмплер
+const minSampleRatio = 0.001
+
+// sampler ограничивает поток записей целевой скоростью. Раз в секунду доля
+// пропускаемых записей пересчитывается по числу записей за прошедшую секунду,
+// записи уровня Error и выше пропускаются всегда.
+type sampler struct {
+	mu     sync.Mutex
+	rate   int
+	report time.Duration
+
+	now func() time.Time
+
+	windowStart time.Time
+	seen        int
+	kept        int
+	ratio       float64
+	credit      float64
+
+	lastReport time.Time
+	dropped    int
+
+	onPressure   func(float64)
+	lastPressure float64
+}
+
+func newSampler(rate int, report time.Duration, onPressure func(float64)) *sampler {
+	if report <= 0 {
+		report = 10 * time.Second
+	}
+
+	return &sampler{
+		rate:       rate,
+		report:     report,
+		now:        time.Now,
+		ratio:      1,
+		onPressure: onPressure,
+	}
+}
+
+// pressure — загрузка сэмплера от 0 до 1: 1 — предел окна исчерпан,
+// иначе доля отбрасываемых записей
+func (s *sampler) pressure() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pressureLocked()
+}
+
+func (s *sampler) pressureLocked() float64 {
+	if s.kept >= s.rate {
+		return 1
+	}
+
+	return 1 - s.ratio
+}
+
+// allow решает, выводить ли запись. Если пора сообщить о текущей доле,
+// возвращает запись-отчет.
+func (s *sampler) allow(level slog.Level) (bool, *slog.Record) {
+	// колбэк вызывается после снятия блокировки: он может писать в лог
+	pressure := -1.0
+	defer func() {
+		if pressure >= 0 {
+			s.onPressure(pressure)
+		}
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.windowStart.IsZero() {
+		s.windowStart = now
+		s.lastReport = now
+	}
+
+	if elapsed := now.Sub(s.windowStart); elapsed >= time.Second {
+		// скорость за прошедшее окно в записях в секунду
+		perSec := float64(s.seen) / elapsed.Seconds()
+
+		ratio := 1.0
+		if perSec > float64(s.rate) {
+			ratio = float64(s.rate) / perSec
+		}
+		// сглаживание, чтобы доля не прыгала между окнами
+		s.ratio = max(minSampleRatio, min(1, (s.ratio+ratio)/2))
+		if ratio == 1 && s.ratio > 0.9 {
+			s.ratio = 1
+		}
+
+		s.windowStart = now
+		s.seen, s.kept = 0, 0
+	}
+
+	s.seen++
+
+	keep := true
+	if level < slog.LevelError {
+		s.credit += s.ratio
+		switch {
+		case s.kept >= s.rate:
+			// всплеск внутри окна: жесткий предел до пересчета доли
+			keep = false
+		case s.credit >= 1:
+			s.credit--
+		default:
+			keep = false
+		}
+	}
+
+	if keep {
+		s.kept++
+	} else {
+		s.dropped++
+	}
+
+	if s.onPressure != nil {
+		if p := s.pressureLocked(); p != s.lastPressure {
+			s.lastPressure = p
+			pressure = p
+		}
+	}
+
+	var report *slog.Record
+	if s.dropped > 0 && now.Sub(s.lastReport) >= s.report {
+		r := slog.NewRecord(now, slog.LevelWarn, "log sampling", 0)
+		r.AddAttrs(
+			slog.Float64("sample_ratio", s.ratio),
+			slog.Int("dropped", s.dropped),
+			slog.Int("target_rate", s.rate),
+		)
+		report = &r
+
+		s.lastReport = now
+		s.dropped = 0
+	}
+
+	return keep, report
+}
+
+func (p *preprocessor) sample(r *slog.Record) bool {
+	keep, report := p.sampler.allow(r.Level)
+	if report != nil {
+		p.notify(*report)
+	}
+
+	return keep
+}
+
+// pressurer — обработчик, который сообщает о своей загрузке
+type pressurer interface {
+	pressure() float64
+}
+
+// Pressure возвращает загрузку логера l (slog.Default при nil) от 0 до 1:
+// при сэмплировании (Options.SampleRate) — долю отбрасываемых записей,
+// 1 — целевая скорость исчерпана. Приложение может по ней отключать
+// необязательные отладочные записи. Без сэмплирования — всегда 0.
+func Pressure(l *slog.Logger) float64 {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	if p, ok := l.Handler().(pressurer); ok {
+		return p.pressure()
+	}
+
+	return 0
+}
+
+func (p *preprocessor) pressure() float64 {
+	if p.sampler == nil {
+		return 0
+	}
+
+	return p.sampler.pressure()
+}
+
+func (h *handlerTextColor) pressure() float64 {
+	return h.pre.pressure()
+}
+
+func (h *HandlerMiddleware) pressure() float64 {
+	return h.pre.pressure()
+}
@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jsonTyped — экстрактор, который знает JSON тип своего атрибута
+type jsonTyped interface {
+	jsonType() string
+}
+
+func (k *CtxKey[T]) jsonType() string {
+	return jsonTypeOf(reflect.TypeFor[T]())
+}
+
+// jsonTypeOf возвращает тип JSON schema, в который slog.JSONHandler кодирует значения типа t
+func jsonTypeOf(t reflect.Type) string {
+	if t == reflect.TypeFor[time.Duration]() {
+		return "integer"
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+
+	return ""
+}
+
+// levelPattern возвращает шаблон уровня: стандартные и из RegisterLevel
+func levelPattern() string {
+	pattern := `^(DEBUG|INFO|WARN|ERROR)([+-]\d+)?$`
+
+	m := levels.Load()
+	if m == nil {
+		return pattern
+	}
+
+	names := make([]string, 0, len(*m))
+	for _, info := range *m {
+		names = append(names, regexp.QuoteMeta(info.name))
+	}
+	slices.Sort(names)
+
+	return pattern + "|^(" + strings.Join(names, "|") + ")$"
+}
+
+// JSONSchema возвращает JSON schema записей, которые выводит JSON логер
+// (NewLogger, InitLogger) с настройками opts: обязательные поля, источник,
+// SQL и атрибуты gorm логера, значения контекста. Атрибуты записей
+// приложения схемой не описываются и разрешены как additionalProperties.
+func JSONSchema(opts Options) ([]byte, error) {
+	str := map[string]any{"type": "string"}
+
+	timeField := map[string]any{"type": "string", "format": "date-time"}
+	if opts.TimeFormat != "" {
+		timeField = map[string]any{"type": "string", "description": "time in Go layout " + opts.TimeFormat}
+	}
+
+	props := map[string]any{
+		"time":  timeField,
+		"level": map[string]any{"type": "string", "pattern": levelPattern()},
+		"msg":   str,
+
+		// атрибуты gorm логера
+		Sql:              str,
+		SqlHash:          str,
+		SecretMasked:     map[string]any{"type": "boolean"},
+		Preload:          str,
+		Association:      str,
+		LockWait:         map[string]any{"type": "number", "description": "milliseconds"},
+		Clauses:          str,
+		"slow_threshold": map[string]any{"type": "integer", "description": "nanoseconds"},
+		SqlCount:         map[string]any{"type": "integer"},
+		DbTime:           map[string]any{"type": "integer", "description": "nanoseconds"},
+		"db": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"sqlstate":   str,
+				"errno":      str,
+				"constraint": str,
+				"table":      str,
+				"column":     str,
+				"detail":     str,
+				"hint":       str,
+			},
+		},
+	}
+
+	if opts.Source {
+		props[Source] = map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"function": str,
+				"file":     str,
+				"line":     map[string]any{"type": "integer"},
+			},
+		}
+	}
+
+	for _, k := range opts.AddCxtAttr {
+		props[k] = map[string]any{}
+	}
+
+	for _, e := range opts.CtxExtractors {
+		k, ok := e.(interface{ Name() string })
+		if !ok {
+			continue
+		}
+
+		field := map[string]any{}
+		if t, ok := e.(jsonTyped); ok && t.jsonType() != "" {
+			field["type"] = t.jsonType()
+		}
+		props[k.Name()] = field
+	}
+
+	if opts.CardinalityKeyLimit > 0 {
+		props[CardinalityOverflowKey] = str
+	}
+
+	if opts.RuntimeStats {
+		props[RuntimeKey] = map[string]any{
+			"type":        "object",
+			"description": "runtime stats on Error and above",
+			"properties": map[string]any{
+				"goroutines": map[string]any{"type": "integer"},
+				"heap_inuse": map[string]any{"type": "integer"},
+			},
+		}
+	}
+
+	if opts.SizeStats && opts.LargeRecord > 0 {
+		props[RecordBytes] = map[string]any{"type": "integer", "description": "record size in bytes when above LargeRecord"}
+	}
+
+	schema := map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "slog_gorm_color record",
+		"type":                 "object",
+		"required":             []string{"time", "level", "msg"},
+		"properties":           props,
+		"additionalProperties": true,
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
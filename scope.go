@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// PushAttrs добавляет в контекст новый уровень атрибутов. Атрибуты всех
+// активных уровней выводятся перед атрибутами записи, от внешнего к внутреннему.
+// Аргументы задаются так же, как в slog.Info: пары ключ-значение или slog.Attr.
+func PushAttrs(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+
+	return core.PushAttrs(ctx, core.ArgsToAttrs(args))
+}
+
+// PopAttrs возвращает контекст без последнего уровня атрибутов
+func PopAttrs(ctx context.Context) context.Context {
+	return core.PopAttrs(ctx)
+}
+
+// withScopeAttrs возвращает запись, в которой атрибуты контекста стоят перед атрибутами записи
+func withScopeAttrs(ctx context.Context, rec slog.Record) slog.Record {
+	attrs := core.ScopeAttrs(ctx)
+	if len(attrs) == 0 {
+		return rec
+	}
+
+	r := slog.NewRecord(rec.Time, rec.Level, rec.Message, rec.PC)
+	r.AddAttrs(attrs...)
+	rec.Attrs(func(a slog.Attr) bool {
+		r.AddAttrs(a)
+		return true
+	})
+
+	return r
+}
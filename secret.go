@@ -0,0 +1,16 @@
+package logger
+
+import "regexp"
+
+// Атрибут, которым помечается запись с замаскированными секретами
+const SecretMasked = "secret_masked"
+
+// DefaultSecretPatterns возвращает шаблоны распространенных секретов:
+// JWT и ключи доступа AWS.
+func DefaultSecretPatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+		regexp.MustCompile(`(?i)aws.{0,20}?\b[A-Za-z0-9/+=]{40}\b`),
+	}
+}
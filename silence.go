@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// Silence возвращает контекст, записи с которым (и производными от него)
+// не выводятся ни dev обработчиком, ни HandlerMiddleware — для циклов
+// опроса, health эндпоинтов и т.п.:
+//
+//	func health(w http.ResponseWriter, r *http.Request) {
+//		ctx := logger.Silence(r.Context())
+//		...
+//	}
+func Silence(ctx context.Context) context.Context {
+	return core.WithSilence(ctx, core.SilenceAll)
+}
+
+// SilenceSQL подавляет только записи SQL запросов gorm логера,
+// остальные записи с контекстом выводятся. Подавленные запросы не
+// попадают и в GetRecentQueries
+func SilenceSQL(ctx context.Context) context.Context {
+	if silenced(ctx, true) {
+		return ctx
+	}
+	return core.WithSilence(ctx, core.SilenceSQL)
+}
+
+// silenced сообщает, что записи с контекстом подавлены. sql — запись SQL запроса
+func silenced(ctx context.Context, sql bool) bool {
+	return core.Silenced(ctx, sql)
+}
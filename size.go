@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Верхние границы корзин гистограммы размеров записей в байтах;
+// последняя корзина — записи больше 64KB
+var SizeBuckets = []int{64, 128, 256, 512, 1 << 10, 2 << 10, 4 << 10, 8 << 10, 16 << 10, 32 << 10, 64 << 10}
+
+// RecordSizes — объем записей одного места вызова
+type RecordSizes struct {
+	// Файл и строка вызова
+	Site  string
+	Count int
+	Bytes int64
+	Max   int
+	// Количество записей по корзинам SizeBuckets, последний элемент — больше 64KB
+	Buckets []int
+}
+
+type sizeStats struct {
+	mu    sync.Mutex
+	sites map[uintptr]*RecordSizes
+}
+
+func newSizeStats() *sizeStats {
+	return &sizeStats{sites: make(map[uintptr]*RecordSizes)}
+}
+
+func (s *sizeStats) add(pc uintptr, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	site, ok := s.sites[pc]
+	if !ok {
+		site = &RecordSizes{Buckets: make([]int, len(SizeBuckets)+1)}
+		s.sites[pc] = site
+	}
+
+	site.Count++
+	site.Bytes += int64(n)
+	site.Max = max(site.Max, n)
+
+	i, _ := slices.BinarySearch(SizeBuckets, n)
+	site.Buckets[i]++
+}
+
+func (s *sizeStats) list() []RecordSizes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]RecordSizes, 0, len(s.sites))
+	for pc, site := range s.sites {
+		rs := *site
+		rs.Buckets = slices.Clone(site.Buckets)
+		rs.Site = "unknown"
+		if src, ok := resolveSource(nil, pc); ok {
+			rs.Site = src.File + ":" + strconv.Itoa(src.Line)
+		}
+		out = append(out, rs)
+	}
+
+	slices.SortFunc(out, func(a, b RecordSizes) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(a.Site, b.Site))
+	})
+
+	return out
+}
+
+// sizer — обработчик, который считает объем записей
+type sizer interface {
+	recordSizes() []RecordSizes
+}
+
+// RecordSizeStats возвращает объем записей логера l (slog.Default при nil)
+// по местам вызова, от самых объемных. Статистика собирается при
+// Options.SizeStats: в терминале — по выведенным байтам, в JSON — по
+// размеру записи в JSON до передачи следующему обработчику.
+func RecordSizeStats(l *slog.Logger) []RecordSizes {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	if s, ok := l.Handler().(sizer); ok {
+		return s.recordSizes()
+	}
+
+	return nil
+}
+
+func (p *preprocessor) recordSizes() []RecordSizes {
+	if p.sizes == nil {
+		return nil
+	}
+
+	return p.sizes.list()
+}
+
+func (h *handlerTextColor) recordSizes() []RecordSizes {
+	return h.pre.recordSizes()
+}
+
+func (h *HandlerMiddleware) recordSizes() []RecordSizes {
+	return h.pre.recordSizes()
+}
+
+// RecordBytes — атрибут с размером записи больше Options.LargeRecord
+const RecordBytes = "record_bytes"
+
+// measureDev учитывает размер отрисованной записи buf[start:] и дописывает
+// атрибут record_bytes перед переводом строки, если запись больше порога
+func (h *handlerTextColor) measureDev(buf *buffer, start int, pc uintptr) {
+	n := len(*buf) - start
+	if n == 0 {
+		return
+	}
+
+	h.pre.sizes.add(pc, n)
+
+	if h.largeRecord > 0 && n > h.largeRecord && (*buf)[len(*buf)-1] == '\n' {
+		*buf = (*buf)[:len(*buf)-1]
+		buf.WriteString(h.theme.Faint)
+		buf.WriteString(RecordBytes + "=" + strconv.Itoa(n))
+		buf.WriteString(h.theme.reset)
+		buf.WriteByte('\n')
+	}
+}
+
+// measureJSON учитывает размер записи в JSON и добавляет атрибут
+// record_bytes, если запись больше порога
+func (h *HandlerMiddleware) measureJSON(rec *slog.Record) {
+	buf := newBuffer()
+	defer buf.Free()
+
+	_ = slog.NewJSONHandler(buf, nil).Handle(context.Background(), *rec)
+	n := len(*buf)
+
+	h.pre.sizes.add(rec.PC, n)
+
+	if h.largeRecord > 0 && n > h.largeRecord {
+		rec.AddAttrs(slog.Int(RecordBytes, n))
+	}
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Число элементов числовых срезов в терминале по умолчанию
+const defaultSliceItems = 10
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// appendNumericSlice выводит срез чисел или time.Duration компактно:
+// [1.2ms 3.4ms 9ms], [1 2 3 …+97]. Возвращает false для других значений.
+func (h *handlerTextColor) appendNumericSlice(buf *buffer, v any) bool {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false
+	}
+
+	elem := rv.Type().Elem()
+	if !isNumericElem(elem) {
+		return false
+	}
+
+	limit := h.sliceItems
+	if limit <= 0 {
+		limit = defaultSliceItems
+	}
+
+	buf.WriteByte('[')
+	n := rv.Len()
+	for i := range min(n, limit) {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+
+		e := rv.Index(i)
+		switch {
+		case elem == durationType:
+			buf.WriteString(compactDuration(time.Duration(e.Int())))
+		case e.CanInt():
+			*buf = strconv.AppendInt(*buf, e.Int(), 10)
+		case e.CanUint():
+			*buf = strconv.AppendUint(*buf, e.Uint(), 10)
+		default:
+			*buf = strconv.AppendFloat(*buf, e.Float(), 'g', -1, elem.Bits())
+		}
+	}
+
+	if n > limit {
+		buf.WriteString(h.theme.Faint)
+		buf.WriteString(" …+")
+		buf.WriteString(strconv.Itoa(n - limit))
+		buf.WriteString(h.theme.reset)
+	}
+	buf.WriteByte(']')
+
+	return true
+}
+
+// isNumericElem — числа без собственного форматирования и time.Duration.
+// []byte выводится как прежде.
+func isNumericElem(t reflect.Type) bool {
+	if t == durationType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return false
+	}
+
+	_, stringer := reflect.Zero(t).Interface().(fmt.Stringer)
+	return !stringer
+}
+
+// compactDuration округляет длительность до трех значащих цифр: 1.23ms, 2.5s
+func compactDuration(d time.Duration) string {
+	a := d.Abs()
+
+	var unit time.Duration
+	switch {
+	case a >= 100*time.Second:
+		unit = time.Second
+	case a >= time.Second:
+		unit = 10 * time.Millisecond
+	case a >= 100*time.Millisecond:
+		unit = time.Millisecond
+	case a >= time.Millisecond:
+		unit = 10 * time.Microsecond
+	case a >= 100*time.Microsecond:
+		unit = time.Microsecond
+	case a >= time.Microsecond:
+		unit = 10 * time.Nanosecond
+	default:
+		return d.String()
+	}
+
+	return d.Round(unit).String()
+}
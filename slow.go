@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"regexp"
+	"time"
+)
+
+// SlowRule задает порог медленного запроса для класса запросов.
+// Правила проверяются по порядку, применяется первое подходящее:
+//
+//	[]SlowRule{
+//		{Pattern: regexp.MustCompile(`(?i)^SELECT .* FROM reports_`), Threshold: 5 * time.Second},
+//		{Pattern: regexp.MustCompile(`(?i)^(INSERT|UPDATE|DELETE)`), Threshold: 100 * time.Millisecond},
+//	}
+type SlowRule struct {
+	// Регулярное выражение по тексту SQL
+	Pattern *regexp.Regexp
+	// Отпечаток запроса (SQLFingerprint), сравнивается целиком
+	Fingerprint string
+
+	Threshold time.Duration
+}
+
+// SlowConfig — пороги медленных запросов, общие для gorm логера (Warn
+// "slow query"), подсветки длительности в терминале и итоговой сводки:
+//
+//	slow := &logger.SlowConfig{Threshold: 200 * time.Millisecond, Rules: rules}
+//	logger.InitDevLogger(logger.Options{Slow: slow})
+//	db.Logger = gormslog.NewGormLoggerOptions(gormslog.GormOptions{Slow: slow})
+//
+// Не изменяйте SlowConfig после передачи в логеры.
+type SlowConfig struct {
+	// Порог по умолчанию. 0 — запросы не считаются медленными
+	Threshold time.Duration
+	// Пороги для отдельных классов запросов, см. SlowRule
+	Rules []SlowRule
+}
+
+// For возвращает порог для запроса
+func (c SlowConfig) For(sql string) time.Duration {
+	if len(c.Rules) == 0 {
+		return c.Threshold
+	}
+	return slowThreshold(c.Rules, sql, c.Threshold)
+}
+
+// IsSlow сообщает, что запрос длительностью d медленный
+func (c SlowConfig) IsSlow(sql string, d time.Duration) bool {
+	threshold := c.For(sql)
+	return threshold > 0 && d > threshold
+}
+
+// slowConfig возвращает Options.Slow или пороги из SlowThreshold и SlowRules
+func (o Options) slowConfig() SlowConfig {
+	if o.Slow != nil {
+		return *o.Slow
+	}
+	return SlowConfig{Threshold: o.SlowThreshold, Rules: o.SlowRules}
+}
+
+// SQLFingerprint возвращает нормализованный запрос: литералы и числа
+// заменены на ?, списки IN свернуты, пробелы схлопнуты
+func SQLFingerprint(sql string) string {
+	return sqlFingerprint(sql)
+}
+
+// slowThreshold возвращает порог для запроса по первому подходящему правилу
+// или def, если ни одно правило не подошло
+func slowThreshold(rules []SlowRule, sql string, def time.Duration) time.Duration {
+	var fp string
+
+	for _, rule := range rules {
+		if rule.Pattern != nil && !rule.Pattern.MatchString(sql) {
+			continue
+		}
+
+		if rule.Fingerprint != "" {
+			if fp == "" {
+				fp = sqlFingerprint(sql)
+			}
+			if fp != rule.Fingerprint {
+				continue
+			}
+		}
+
+		return rule.Threshold
+	}
+
+	return def
+}
@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKey — атрибут с именем участка Span. Имена вложенных участков
+// разделяются "/": "import/parse"
+const SpanKey = "span"
+
+type spanKey struct{}
+
+// Span пишет "start name" с уровнем Debug и возвращает функцию завершения,
+// которая пишет "end name" с длительностью участка: с уровнем Info или
+// Error, если передана ошибка. Источником записей считается место вызова
+// Span. Облегченная замена трассировки для локальной разработки:
+//
+//	ctx, end := logger.Span(ctx, "import")
+//	defer func() { end(err) }()
+//
+// Длительность в терминале подсвечивается как медленная при превышении
+// Options.SlowThreshold. Если на участке выполнялись запросы gorm логера,
+// запись "end" содержит их число sql_count и общее время db_time: участок
+// вокруг обработки запроса дает итоговую запись со стоимостью запроса в БД
+// без отдельного счетчика. Те же атрибуты получают функции OnSpanEnd.
+func Span(ctx context.Context, name string) (context.Context, func(err error)) {
+	if parent, ok := ctx.Value(spanKey{}).(string); ok {
+		name = parent + "/" + name
+	}
+	ctx = context.WithValue(ctx, spanKey{}, name)
+	ctx = WithSQLCounter(ctx)
+
+	var pcs [1]uintptr
+	if sourceCapture(slog.Default().Handler()) {
+		runtime.Callers(2, pcs[:]) // пропустить Callers и Span
+	}
+
+	logSpan(ctx, slog.LevelDebug, "start "+name, pcs[0], slog.String(SpanKey, name))
+
+	start := time.Now()
+	return ctx, func(err error) {
+		attrs := []slog.Attr{slog.String(SpanKey, name), slog.Duration(Duration, time.Since(start))}
+		if n, _, _ := SQLCount(ctx); n > 0 {
+			attrs = append(attrs, SQLCountAttrs(ctx)...)
+		}
+
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelError
+			attrs = append(attrs, slog.Any("error", err))
+		}
+
+		if hooks := spanEndHooks.Load(); hooks != nil {
+			for _, fn := range *hooks {
+				fn(ctx, name, attrs)
+			}
+		}
+
+		logSpan(ctx, level, "end "+name, pcs[0], attrs...)
+	}
+}
+
+// SpanEndFunc получает атрибуты записи "end" участка Span: span, duration,
+// sql_count и db_time, если были запросы, и error. attrs нельзя изменять
+type SpanEndFunc func(ctx context.Context, name string, attrs []slog.Attr)
+
+var (
+	spanEndMu sync.Mutex
+	// Копия при каждой регистрации: Span читает без блокировки
+	spanEndHooks atomic.Pointer[[]SpanEndFunc]
+)
+
+// OnSpanEnd добавляет функцию, которая вызывается при завершении каждого
+// Span, даже если запись "end" отключена уровнем. Например, перенос
+// счетчиков SQL в span OpenTelemetry:
+//
+//	logger.OnSpanEnd(func(ctx context.Context, name string, attrs []slog.Attr) {
+//		span := trace.SpanFromContext(ctx)
+//		for _, a := range attrs {
+//			if a.Key == logger.SqlCount || a.Key == logger.DbTime {
+//				span.SetAttributes(attribute.String("db."+a.Key, a.Value.String()))
+//			}
+//		}
+//	})
+//
+// Регистрируйте функции при старте приложения. Счетчики в span без ручного
+// переноса дает модуль github.com/bairto15/slog_gorm_color/otelslog.
+func OnSpanEnd(fn SpanEndFunc) {
+	spanEndMu.Lock()
+	defer spanEndMu.Unlock()
+
+	var hooks []SpanEndFunc
+	if old := spanEndHooks.Load(); old != nil {
+		hooks = slices.Clone(*old)
+	}
+	hooks = append(hooks, fn)
+	spanEndHooks.Store(&hooks)
+}
+
+func logSpan(ctx context.Context, level slog.Level, msg string, pc uintptr, attrs ...slog.Attr) {
+	h := slog.Default().Handler()
+	if !h.Enabled(ctx, level) {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), level, msg, pc)
+	r.AddAttrs(attrs...)
+	_ = h.Handle(ctx, r)
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// WithSQLCounter добавляет в контекст счетчик SQL запросов, которые gorm
+// логер выполнит с этим контекстом. Span добавляет счетчик сам и выводит
+// sql_count и db_time в записи "end" и функциям OnSpanEnd — обычно этого
+// достаточно. Отдельный счетчик нужен, чтобы прочитать итог без Span:
+//
+//	ctx = logger.WithSQLCounter(r.Context())
+//	next.ServeHTTP(w, r.WithContext(ctx))
+//	slog.InfoContext(ctx, "request done", logger.SQLCountAttrs(ctx)...)
+func WithSQLCounter(ctx context.Context) context.Context {
+	return core.WithSQLCounter(ctx)
+}
+
+// SQLCount возвращает число запросов и их общее время для счетчика
+// WithSQLCounter, false — если счетчика в контексте нет
+func SQLCount(ctx context.Context) (int, time.Duration, bool) {
+	return core.SQLCount(ctx)
+}
+
+// SQLCountAttrs возвращает атрибуты sql_count и db_time счетчика
+// WithSQLCounter, nil — если счетчика в контексте нет
+func SQLCountAttrs(ctx context.Context) []slog.Attr {
+	n, d, ok := SQLCount(ctx)
+	if !ok {
+		return nil
+	}
+	return []slog.Attr{slog.Int(SqlCount, n), slog.Duration(DbTime, d)}
+}
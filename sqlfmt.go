@@ -0,0 +1,178 @@
+package logger
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bairto15/slog_gorm_color/internal/core"
+)
+
+// collapseInLists заменяет списки IN длиннее threshold на "IN (… N values …)".
+// Подзапросы и строковые литералы не затрагиваются.
+func collapseInLists(sql string, threshold int) string {
+	if threshold <= 0 {
+		return sql
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'':
+			i = core.LiteralEnd(sql, i+1)
+		case (c == 'I' || c == 'i') && i+1 < len(sql) && (sql[i+1] == 'N' || sql[i+1] == 'n'):
+			if i > 0 && core.IsWordByte(sql[i-1]) {
+				continue
+			}
+
+			open := i + 2
+			for open < len(sql) && sql[open] == ' ' {
+				open++
+			}
+			if open >= len(sql) || sql[open] != '(' {
+				continue
+			}
+
+			end, values, ok := inListEnd(sql, open+1)
+			if !ok || values <= threshold {
+				continue
+			}
+
+			if b.Len() == 0 {
+				b.Grow(len(sql))
+			}
+			b.WriteString(sql[last : open+1])
+			b.WriteString("… ")
+			b.WriteString(strconv.Itoa(values))
+			b.WriteString(" values …")
+			last = end
+			i = end
+		}
+	}
+
+	if last == 0 {
+		return sql
+	}
+
+	b.WriteString(sql[last:])
+	return b.String()
+}
+
+// inListEnd возвращает индекс закрывающей скобки списка и количество значений в нем.
+// Для подзапросов возвращает ok=false.
+func inListEnd(sql string, i int) (end, values int, ok bool) {
+	depth := 0
+	values = 1
+
+	for ; i < len(sql); i++ {
+		switch sql[i] {
+		case '\'':
+			i = core.LiteralEnd(sql, i+1)
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i, values, true
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				values++
+			}
+		case 'S', 's':
+			if depth == 0 && len(sql) >= i+6 && strings.EqualFold(sql[i:i+6], "select") {
+				return 0, 0, false
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// fullSQLHash возвращает хэш исходного SQL, если показанный shown
+// отличается от него: обрезан или замаскирован в Trace
+func fullSQLHash(ctx context.Context, sql, shown string) (string, bool) {
+	if h, ok := ctx.Value(SqlHash).(string); ok {
+		return h, true
+	}
+	if shown != sql {
+		return core.SQLHash(sql), true
+	}
+	return "", false
+}
+
+// truncateSQL обрезает SQL до max байт, не разрезая UTF-8 символы
+func truncateSQL(sql string, max int) string {
+	if max <= 0 || len(sql) <= max {
+		return sql
+	}
+
+	cut := max
+	for cut > 0 && !utf8RuneStart(sql[cut]) {
+		cut--
+	}
+
+	return sql[:cut] + "…"
+}
+
+func utf8RuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// sqlFingerprint нормализует запрос: литералы и числа заменяются на ?,
+// списки IN сворачиваются, пробелы схлопываются. Комментарии (/* request_id=... */,
+// теги SQLCommenter) удаляются: иначе отпечаток каждого запроса уникален
+func sqlFingerprint(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+
+	space := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += 2 + end + 1
+			}
+			space = true
+			continue
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end
+			}
+			space = true
+			continue
+		case c == '\'':
+			i = core.LiteralEnd(sql, i+1)
+			c = '?'
+		case c >= '0' && c <= '9' && (i == 0 || !core.IsWordByte(sql[i-1])):
+			for i+1 < len(sql) && (core.IsWordByte(sql[i+1]) || sql[i+1] == '.') {
+				i++
+			}
+			c = '?'
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteByte(c)
+	}
+
+	return inPlaceholders.ReplaceAllString(b.String(), "IN (?)")
+}
+
+var inPlaceholders = regexp.MustCompile(`(?i)\bIN \(\?(?: ?, ?\?)*\)`)
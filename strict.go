@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+)
+
+// unloggable возвращает причину, по которой значение нельзя осмысленно
+// вывести: каналы, функции, unsafe.Pointer, структуры только с неэкспортируемыми
+// полями (JSON выводит их как {}). Пустая строка — значение выводится нормально.
+func unloggable(v any) string {
+	switch v.(type) {
+	case nil, error, fmt.Stringer, encoding.TextMarshaler, json.Marshaler, slog.LogValuer:
+		return ""
+	}
+
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Chan:
+		return "channel"
+	case reflect.Func:
+		return "func"
+	case reflect.UnsafePointer:
+		return "unsafe.Pointer"
+	case reflect.Struct:
+		if t.NumField() == 0 {
+			return ""
+		}
+		for i := range t.NumField() {
+			if t.Field(i).IsExported() {
+				return ""
+			}
+		}
+		return "struct without exported fields"
+	}
+
+	return ""
+}
+
+// strictCheck возвращает предупреждения о значениях атрибутов записи,
+// которые нельзя осмысленно вывести, с местом вызова
+func strictCheck(r *slog.Record) []slog.Record {
+	var warnings []slog.Record
+
+	var check func(prefix string, a slog.Attr)
+	check = func(prefix string, a slog.Attr) {
+		switch a.Value.Kind() {
+		case slog.KindGroup:
+			for _, ga := range a.Value.Group() {
+				check(prefix+a.Key+".", ga)
+			}
+		case slog.KindAny:
+			v := a.Value.Any()
+			reason := unloggable(v)
+			if reason == "" {
+				return
+			}
+
+			w := slog.NewRecord(r.Time, slog.LevelWarn, "strict: attribute value cannot be logged", 0)
+			w.AddAttrs(
+				slog.String("key", prefix+a.Key),
+				slog.String("type", fmt.Sprintf("%T", v)),
+				slog.String("reason", reason),
+			)
+			if src, ok := resolveSource(nil, r.PC); ok {
+				w.AddAttrs(slog.String("call_site", src.File+":"+strconv.Itoa(src.Line)))
+			}
+			warnings = append(warnings, w)
+		}
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		check("", a)
+		return true
+	})
+
+	return warnings
+}
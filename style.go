@@ -0,0 +1,122 @@
+package logger
+
+import "strconv"
+
+// Color — цвет текста или фона Style. Нулевое значение — цвет терминала
+// по умолчанию
+type Color uint32
+
+// 16 цветов терминала, выглядят согласно его настройкам
+const (
+	ColorBlack Color = iota + 1
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+	ColorBrightBlack
+	ColorBrightRed
+	ColorBrightGreen
+	ColorBrightYellow
+	ColorBrightBlue
+	ColorBrightMagenta
+	ColorBrightCyan
+	ColorBrightWhite
+)
+
+// Признак 24-битного цвета в Color
+const colorRGB Color = 1 << 24
+
+// Color256 возвращает цвет из палитры 256 цветов
+func Color256(n uint8) Color {
+	return Color(n) + 1
+}
+
+// RGB возвращает 24-битный цвет. На терминалах без truecolor (COLORTERM
+// не truecolor/24bit) цвет заменяется ближайшим из палитры 256 цветов
+func RGB(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// String возвращает ANSI-код цвета текста для полей Theme, цвет фона
+// задается через Style{Bg: c}:
+//
+//	theme.SQLKeyword = logger.Color256(33).String()
+func (c Color) String() string {
+	return Style{Fg: c}.String()
+}
+
+// Style — оформление текста: цвета и начертание. Style{...}.String()
+// дает ANSI-код для полей Theme:
+//
+//	theme := logger.Theme{
+//		LevelError: logger.Style{Fg: logger.ColorRed, Bg: logger.ColorYellow, Bold: true}.String(),
+//	}
+type Style struct {
+	Fg Color
+	Bg Color
+
+	Bold      bool
+	Faint     bool
+	Underline bool
+}
+
+// String возвращает ANSI-код стиля. Для пустого стиля — пустую строку
+func (s Style) String() string {
+	return string(s.AppendANSI(nil))
+}
+
+// AppendANSI дописывает в buf ANSI-код стиля и возвращает расширенный
+// буфер. Пустой стиль ничего не дописывает
+func (s Style) AppendANSI(buf []byte) []byte {
+	if s == (Style{}) {
+		return buf
+	}
+
+	buf = append(buf, "\u001b["...)
+	n := len(buf)
+
+	param := func(p string) {
+		if len(buf) > n {
+			buf = append(buf, ';')
+		}
+		buf = append(buf, p...)
+	}
+
+	if s.Bold {
+		param("1")
+	}
+	if s.Faint {
+		param("2")
+	}
+	if s.Underline {
+		param("4")
+	}
+	if s.Fg != 0 {
+		param(s.Fg.params(30, 90, "38"))
+	}
+	if s.Bg != 0 {
+		param(s.Bg.params(40, 100, "48"))
+	}
+
+	return append(buf, 'm')
+}
+
+// params возвращает параметры SGR цвета: base и bright — начало кодов
+// 8 основных и 8 ярких цветов, ext — префикс расширенных цветов
+func (c Color) params(base, bright int, ext string) string {
+	if c&colorRGB != 0 {
+		return ext + ";2;" + strconv.Itoa(int(c>>16&0xff)) + ";" + strconv.Itoa(int(c>>8&0xff)) + ";" + strconv.Itoa(int(c&0xff))
+	}
+
+	switch n := int(c) - 1; {
+	case n < 8:
+		return strconv.Itoa(base + n)
+	case n < 16:
+		return strconv.Itoa(bright + n - 8)
+	default:
+		return ext + ";5;" + strconv.Itoa(n)
+	}
+}
@@ -0,0 +1,212 @@
+package logger
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Количество самых медленных запросов в сводке
+const summaryTopQueries = 5
+
+// summaryStats накапливает данные для итоговой сводки логера
+type summaryStats struct {
+	mu   sync.Mutex
+	slow SlowConfig
+
+	debug, info, warn, error int
+
+	slowQueries int
+	slowest     []slowQuery
+	writeErrors int
+}
+
+type slowQuery struct {
+	sql      string
+	duration time.Duration
+}
+
+func newSummaryStats(slow SlowConfig) *summaryStats {
+	slow.Threshold = cmp.Or(slow.Threshold, time.Second)
+	return &summaryStats{slow: slow}
+}
+
+func (s *summaryStats) record(ctx context.Context, level slog.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case level < slog.LevelInfo:
+		s.debug++
+	case level < slog.LevelWarn:
+		s.info++
+	case level < slog.LevelError:
+		s.warn++
+	default:
+		s.error++
+	}
+
+	d, ok := ctx.Value(Duration).(time.Duration)
+	if !ok {
+		return
+	}
+	sql, _ := ctx.Value(Sql).(string)
+
+	if s.slow.IsSlow(sql, d) {
+		s.slowQueries++
+	}
+
+	if len(s.slowest) == summaryTopQueries && d <= s.slowest[len(s.slowest)-1].duration {
+		return
+	}
+
+	i, _ := slices.BinarySearchFunc(s.slowest, d, func(q slowQuery, d time.Duration) int {
+		return cmp.Compare(d, q.duration)
+	})
+	s.slowest = slices.Insert(s.slowest, i, slowQuery{sql: sql, duration: d})
+	if len(s.slowest) > summaryTopQueries {
+		s.slowest = s.slowest[:summaryTopQueries]
+	}
+}
+
+func (s *summaryStats) writeError() {
+	s.mu.Lock()
+	s.writeErrors++
+	s.mu.Unlock()
+}
+
+// summary возвращает сводку одной записью
+func (s *summaryStats) summary() slog.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "log summary", 0)
+	r.AddAttrs(
+		slog.Group("records",
+			slog.Int("debug", s.debug),
+			slog.Int("info", s.info),
+			slog.Int("warn", s.warn),
+			slog.Int("error", s.error),
+		),
+		slog.Int("slow_queries", s.slowQueries),
+		slog.Int("write_errors", s.writeErrors),
+	)
+
+	if len(s.slowest) > 0 {
+		queries := make([]any, len(s.slowest))
+		for i, q := range s.slowest {
+			queries[i] = slog.Group(strconv.Itoa(i+1), slog.Duration(Duration, q.duration), slog.String(Sql, q.sql))
+		}
+		r.AddAttrs(slog.Group("slowest", queries...))
+	}
+
+	return r
+}
+
+// summarizer — обработчик, который умеет вывести итоговую сводку
+type summarizer interface {
+	summary() error
+}
+
+// Shutdown выводит итоговую сводку логера l (slog.Default при nil): количество
+// записей по уровням, число медленных запросов, 5 самых медленных запросов и
+// ошибки записи. Сводка собирается при Options.Summary, вызывается в конце main
+// (также останавливает StartKeyboard, возвращая режим терминала, и
+// отправляет последний отчет Options.Digest):
+//
+//	defer logger.Shutdown(nil)
+func Shutdown(l *slog.Logger) error {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	if s, ok := l.Handler().(summarizer); ok {
+		return s.summary()
+	}
+
+	return nil
+}
+
+// release останавливает отчеты Digest без итоговой сводки
+func (h *HandlerMiddleware) release() error {
+	return h.pre.stopDigest()
+}
+
+func (h *handlerTextColor) release() error {
+	h.stopKeyboard()
+	return h.pre.stopDigest()
+}
+
+func (h *HandlerMiddleware) summary() error {
+	err := h.pre.stopDigest()
+
+	if h.pre.stats == nil {
+		return err
+	}
+
+	return errors.Join(err, h.next.Handle(context.Background(), h.pre.stats.summary()))
+}
+
+// summary выводит сводку блоком: строка со счетчиками и по строке на каждый медленный запрос
+func (h *handlerTextColor) summary() error {
+	h.stopKeyboard()
+	digestErr := h.pre.stopDigest()
+
+	s := h.pre.stats
+	if s == nil {
+		return digestErr
+	}
+
+	r := s.summary()
+
+	buf := newBuffer()
+	defer buf.Free()
+
+	// appendTimeDelta требует h.mu
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var slowest []slog.Attr
+	head := slog.NewRecord(r.Time, r.Level, r.Message, 0)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "slowest" {
+			slowest = a.Value.Group()
+		} else {
+			head.AddAttrs(a)
+		}
+		return true
+	})
+
+	h.render(context.Background(), head, buf)
+
+	for _, q := range slowest {
+		g := q.Value.Group()
+		d, sql := g[0].Value.Duration(), g[1].Value.String()
+
+		buf.WriteString("  ")
+		buf.WriteString(h.theme.Key)
+		buf.WriteString(q.Key)
+		buf.WriteString(".")
+		buf.WriteString(h.theme.reset)
+		buf.WriteByte(' ')
+
+		color := h.theme.Duration
+		if s.slow.IsSlow(sql, d) {
+			color = h.theme.SlowDuration
+		}
+		buf.WriteString(color)
+		buf.WriteString(d.String())
+		buf.WriteString(h.theme.reset)
+		buf.WriteByte(' ')
+		buf.WriteString(TruncateWidth(sanitizeUTF8(sql, h.invalidUTF8), 120))
+		buf.WriteByte('\n')
+	}
+
+	_, err := h.w.Write(*buf)
+	return errors.Join(digestErr, err)
+}
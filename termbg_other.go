@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package logger
+
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func queryBackground() (Background, bool) {
+	return BackgroundAuto, false
+}
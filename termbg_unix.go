@@ -0,0 +1,98 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package logger
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func isTerminal(fd uintptr) bool {
+	var t syscall.Termios
+	return tcget(fd, &t) == nil
+}
+
+func tcget(fd uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func tcset(fd uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// foreground сообщает, что процесс в группе переднего плана терминала fd.
+// tcsetattr из фонового задания (app &) останавливает процесс сигналом SIGTTOU
+func foreground(fd uintptr) bool {
+	var pgrp int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp)))
+	return errno == 0 && int(pgrp) == syscall.Getpgrp()
+}
+
+// Время ожидания ответа на OSC 11: терминалы без поддержки запроса не отвечают
+const oscTimeout = 200 * time.Millisecond
+
+// queryBackground запрашивает цвет фона у управляющего терминала (OSC 11).
+// На время запроса терминал переводится в неканонический режим без эха,
+// поэтому фоновое задание терминал не запрашивает.
+func queryBackground() (Background, bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return BackgroundAuto, false
+	}
+	defer tty.Close()
+
+	fd := tty.Fd()
+	if !foreground(fd) {
+		return BackgroundAuto, false
+	}
+
+	var old syscall.Termios
+	if err := tcget(fd, &old); err != nil {
+		return BackgroundAuto, false
+	}
+
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = 1 // десятые доли секунды
+	if err := tcset(fd, &raw); err != nil {
+		return BackgroundAuto, false
+	}
+	defer tcset(fd, &old)
+
+	if _, err := tty.WriteString("\u001b]11;?\u001b\\"); err != nil {
+		return BackgroundAuto, false
+	}
+
+	var resp []byte
+	buf := make([]byte, 64)
+	deadline := time.Now().Add(oscTimeout)
+
+	for time.Now().Before(deadline) && len(resp) < 256 {
+		n, err := syscall.Read(int(fd), buf)
+		if err != nil && err != syscall.EINTR {
+			break
+		}
+		if n > 0 {
+			resp = append(resp, buf[:n]...)
+		}
+
+		s := string(resp)
+		if strings.HasSuffix(s, "\a") || strings.HasSuffix(s, "\u001b\\") {
+			return parseOSC11(s)
+		}
+	}
+
+	return BackgroundAuto, false
+}
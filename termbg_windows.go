@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"io"
+	"syscall"
+)
+
+// ENABLE_VIRTUAL_TERMINAL_PROCESSING: консоль интерпретирует ANSI-последовательности.
+// Поддерживается с Windows 10; cmd.exe и старый PowerShell без нее выводят коды как текст
+const enableVirtualTerminalProcessing = 0x0004
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+func isTerminal(fd uintptr) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(fd), &mode) == nil
+}
+
+// enableVirtualTerminal включает обработку ANSI-последовательностей в консоли w.
+// Возвращает false, если консоль этого не поддерживает — тогда цвета отключаются.
+func enableVirtualTerminal(w io.Writer) bool {
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+
+	h := syscall.Handle(f.Fd())
+
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+
+	if err := procSetConsoleMode.Find(); err != nil {
+		return false
+	}
+
+	r, _, _ := procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+	return r != 0
+}
+
+// Консоль Windows не отвечает на OSC 11
+func queryBackground() (Background, bool) {
+	return BackgroundAuto, false
+}
@@ -0,0 +1,277 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Background — цвет фона терминала, под который подбирается палитра
+type Background int
+
+const (
+	// Определить по COLORFGBG, если W — терминал. Если переменная не
+	// задана — темный фон
+	BackgroundAuto Background = iota
+	BackgroundDark
+	BackgroundLight
+	// Как BackgroundAuto, но без COLORFGBG цвет фона запрашивается у
+	// терминала (OSC 11). Запрос на время переводит терминал в
+	// неканонический режим и выполняется только из группы процессов
+	// переднего плана
+	BackgroundQuery
+)
+
+func (b Background) String() string {
+	switch b {
+	case BackgroundDark:
+		return "dark"
+	case BackgroundLight:
+		return "light"
+	case BackgroundQuery:
+		return "query"
+	}
+	return "auto"
+}
+
+// Theme — ANSI-коды элементов вывода в терминал. Значение поля
+// записывается перед элементом, после элемента выводится Reset.
+type Theme struct {
+	Time       string
+	LevelDebug string
+	LevelInfo  string
+	LevelWarn  string
+	LevelError string
+	// Фон меток уровня при Options.LevelBadges
+	LevelDebugBadge string
+	LevelInfoBadge  string
+	LevelWarnBadge  string
+	LevelErrorBadge string
+	// Файл и строка источника
+	Source   string
+	Function string
+	Message  string
+	// Сообщения и SQL записей уровня Error
+	ErrorMessage string
+	// Ключи атрибутов и значений контекста
+	Key      string
+	ErrorKey string
+	// Длительность SQL запроса до порога медленного запроса и после
+	Duration     string
+	SlowDuration string
+	Rows         string
+	SQL          string
+	// Ключевые слова SQL (SELECT, WHERE...). Пусто — без выделения
+	SQLKeyword string
+	// Метка NEW у первого появления записи
+	Badge string
+	// Повторы записей и служебные пометки: разница значений, свернутые значения
+	Faint string
+}
+
+// DefaultTheme возвращает палитру по умолчанию для темного фона
+func DefaultTheme() Theme {
+	var (
+		faint = Style{Fg: ColorBrightBlack}.String()
+		red   = Style{Fg: ColorRed}.String()
+		blue  = Style{Fg: ColorBlue}.String()
+	)
+
+	return Theme{
+		Time:       faint,
+		LevelDebug: red,
+		LevelInfo:  Style{Fg: ColorBrightGreen}.String(),
+		LevelWarn:  Style{Fg: ColorBrightYellow}.String(),
+		LevelError: red,
+		Source:     faint,
+
+		LevelDebugBadge: Style{Fg: ColorBlack, Bg: ColorWhite}.String(),
+		LevelInfoBadge:  Style{Fg: ColorBlack, Bg: ColorGreen}.String(),
+		LevelWarnBadge:  Style{Fg: ColorBlack, Bg: ColorYellow}.String(),
+		LevelErrorBadge: Style{Fg: ColorBrightWhite, Bg: ColorRed}.String(),
+
+		Function:     blue,
+		Message:      Style{Fg: ColorCyan}.String(),
+		ErrorMessage: red,
+		Key:          faint,
+		ErrorKey:     blue,
+		Duration:     Style{Fg: ColorGreen}.String(),
+		SlowDuration: red,
+		Rows:         Style{Fg: ColorYellow}.String(),
+		SQL:          Style{Fg: ColorMagenta}.String(),
+		Badge:        Style{Bg: ColorYellow}.String(),
+		Faint:        faint,
+	}
+}
+
+// LightTheme возвращает палитру для светлого фона. Faint и желтые цвета
+// на белом почти не видны: вместо них темно-серый и коричневый из палитры
+// 256 цветов, сообщения и SQL — более темные оттенки:
+//
+//	theme := logger.LightTheme()
+//	logger.InitDevLogger(logger.Options{Theme: &theme})
+func LightTheme() Theme {
+	const (
+		gray  = "\u001b[38;5;241m"
+		brown = "\u001b[38;5;130m"
+	)
+
+	return Theme{
+		Time:       gray,
+		LevelDebug: "\u001b[38;5;124m",
+		LevelInfo:  "\u001b[38;5;28m",
+		LevelWarn:  brown,
+		LevelError: "\u001b[38;5;160m",
+		Source:     gray,
+
+		LevelDebugBadge: "\u001b[97;48;5;241m",
+		LevelInfoBadge:  "\u001b[97;48;5;28m",
+		LevelWarnBadge:  "\u001b[97;48;5;130m",
+		LevelErrorBadge: "\u001b[97;48;5;160m",
+
+		Function:     "\u001b[38;5;25m",
+		Message:      "\u001b[38;5;24m",
+		ErrorMessage: "\u001b[38;5;160m",
+		Key:          gray,
+		ErrorKey:     "\u001b[38;5;25m",
+		Duration:     "\u001b[38;5;28m",
+		SlowDuration: "\u001b[38;5;160m",
+		Rows:         brown,
+		SQL:          "\u001b[38;5;90m",
+		Badge:        "\u001b[30;103m",
+		Faint:        gray,
+	}
+}
+
+// resolveTheme выбирает палитру по фону и дополняет ей Options.Theme
+func resolveTheme(opt Options) Theme {
+	base := DefaultTheme()
+	if resolveBackground(opt.Background, opt.W) == BackgroundLight {
+		base = LightTheme()
+	}
+
+	if opt.Theme == nil {
+		return base
+	}
+
+	t := *opt.Theme
+	codes, defaults := t.codes(), base.codes()
+	for i, c := range codes {
+		if *c == "" {
+			*c = *defaults[i]
+		}
+	}
+
+	return t
+}
+
+// codes возвращает указатели на все поля палитры
+func (t *Theme) codes() []*string {
+	return []*string{
+		&t.Time, &t.LevelDebug, &t.LevelInfo, &t.LevelWarn, &t.LevelError,
+		&t.LevelDebugBadge, &t.LevelInfoBadge, &t.LevelWarnBadge, &t.LevelErrorBadge,
+		&t.Source, &t.Function, &t.Message, &t.ErrorMessage, &t.Key, &t.ErrorKey,
+		&t.Duration, &t.SlowDuration, &t.Rows, &t.SQL, &t.SQLKeyword, &t.Badge, &t.Faint,
+	}
+}
+
+// mapCodes возвращает палитру с кодами, преобразованными f
+func (t Theme) mapCodes(f func(string) string) Theme {
+	for _, c := range t.codes() {
+		*c = f(*c)
+	}
+	return t
+}
+
+// resolveBackground определяет фон терминала для BackgroundAuto и
+// BackgroundQuery. Запрос к терминалу выполняется один раз за время работы
+// процесса.
+func resolveBackground(bg Background, w io.Writer) Background {
+	if bg != BackgroundAuto && bg != BackgroundQuery {
+		return bg
+	}
+
+	if !writerIsTerminal(w) {
+		return BackgroundDark
+	}
+
+	if fgbg, ok := parseColorFGBG(os.Getenv("COLORFGBG")); ok {
+		return fgbg
+	}
+	if bg == BackgroundAuto {
+		return BackgroundDark
+	}
+
+	queryOnce.Do(func() {
+		var ok bool
+		if queried, ok = queryBackground(); !ok {
+			queried = BackgroundDark
+		}
+	})
+
+	return queried
+}
+
+var (
+	queryOnce sync.Once
+	queried   Background
+)
+
+// parseColorFGBG разбирает переменную COLORFGBG ("15;0", "0;default;15"):
+// последнее число — номер цвета фона
+func parseColorFGBG(s string) (Background, bool) {
+	i := strings.LastIndexByte(s, ';')
+	if i < 0 {
+		return BackgroundAuto, false
+	}
+
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return BackgroundAuto, false
+	}
+
+	// 7 — светло-серый, 9-15 — яркие цвета, кроме 8 (темно-серый)
+	if n == 7 || n > 8 && n <= 15 {
+		return BackgroundLight, true
+	}
+
+	return BackgroundDark, true
+}
+
+// parseOSC11 разбирает ответ терминала на запрос OSC 11:
+// ESC ] 11 ; rgb:RRRR/GGGG/BBBB, завершенный BEL или ESC \
+func parseOSC11(resp string) (Background, bool) {
+	_, spec, ok := strings.Cut(resp, "rgb:")
+	if !ok {
+		return BackgroundAuto, false
+	}
+
+	spec = strings.TrimRight(spec, "\a\u001b\\")
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 {
+		return BackgroundAuto, false
+	}
+
+	var rgb [3]float64
+	for i, p := range parts {
+		if len(p) == 0 || len(p) > 4 {
+			return BackgroundAuto, false
+		}
+
+		v, err := strconv.ParseUint(p, 16, 16)
+		if err != nil {
+			return BackgroundAuto, false
+		}
+
+		rgb[i] = float64(v) / float64(uint64(1)<<(4*len(p))-1)
+	}
+
+	// относительная яркость по Rec. 709
+	if 0.2126*rgb[0]+0.7152*rgb[1]+0.0722*rgb[2] > 0.5 {
+		return BackgroundLight, true
+	}
+
+	return BackgroundDark, true
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"strings"
+	"time"
+)
+
+// TimeMode — вид времени записи в терминале
+type TimeMode int
+
+const (
+	// Время по часам в формате Options.TimeFormat
+	TimeClock TimeMode = iota
+	// Время с предыдущей записи обработчика: "+12ms"
+	TimeDelta
+	// Время по часам и время с предыдущей записи
+	TimeClockDelta
+)
+
+// Ширина колонки времени с предыдущей записи: "+12.3ms", "+1m23.4s"
+const timeDeltaWidth = 8
+
+// appendTimeDelta выводит время с предыдущей записи. Вызывается под h.mu:
+// время предыдущей записи общее для всех копий обработчика. Промежуток
+// больше SlowThreshold подсвечивается как медленный
+func (h *handlerTextColor) appendTimeDelta(buf *buffer, t time.Time) {
+	var d time.Duration
+	if !h.lastTime.IsZero() {
+		d = t.Sub(*h.lastTime)
+	}
+	*h.lastTime = t
+
+	s := roundDelta(d).String()
+	if d >= 0 {
+		s = "+" + s
+	}
+
+	color := h.theme.Time
+	if h.slow.Threshold > 0 && d > h.slow.Threshold {
+		color = h.theme.SlowDuration
+	}
+
+	buf.WriteString(strings.Repeat(" ", max(timeDeltaWidth-DisplayWidth(s), 0)))
+	buf.WriteString(color)
+	buf.WriteString(s)
+	buf.WriteString(h.theme.reset)
+}
+
+// roundDelta оставляет три значащие цифры: 12.345678ms -> 12.3ms
+func roundDelta(d time.Duration) time.Duration {
+	abs := max(d, -d)
+	for p := time.Duration(1); p < time.Second; p *= 10 {
+		if abs < 1000*p {
+			return d.Round(p)
+		}
+	}
+	return d.Round(time.Second)
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// UTF8Mode задает обработку некорректных последовательностей UTF-8
+// в сообщениях, значениях атрибутов, ошибках и SQL
+type UTF8Mode int
+
+const (
+	// Замена на U+FFFD
+	UTF8Replace UTF8Mode = iota
+	// Замена на \xNN
+	UTF8Escape
+	// Вывод как есть
+	UTF8Raw
+)
+
+func sanitizeUTF8(s string, mode UTF8Mode) string {
+	if mode == UTF8Raw || utf8.ValidString(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			if mode == UTF8Escape {
+				b.WriteString(`\x`)
+				if s[i] < 0x10 {
+					b.WriteByte('0')
+				}
+				b.WriteString(strconv.FormatUint(uint64(s[i]), 16))
+			} else {
+				b.WriteRune(utf8.RuneError)
+			}
+			i++
+			continue
+		}
+
+		b.WriteString(s[i : i+size])
+		i += size
+	}
+
+	return b.String()
+}
+
+// sanitizeRecord экранирует некорректный UTF-8 в сообщении и строковых атрибутах.
+// JSON обработчик сам заменяет такие последовательности на U+FFFD,
+// поэтому для него запись меняется только в режиме UTF8Escape.
+func sanitizeRecord(rec slog.Record, mode UTF8Mode) slog.Record {
+	if mode != UTF8Escape {
+		return rec
+	}
+
+	r := slog.NewRecord(rec.Time, rec.Level, sanitizeUTF8(rec.Message, mode), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		r.AddAttrs(sanitizeAttr(a, mode))
+		return true
+	})
+
+	return r
+}
+
+func sanitizeAttr(a slog.Attr, mode UTF8Mode) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(sanitizeUTF8(a.Value.String(), mode))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			attrs[i] = sanitizeAttr(ga, mode)
+		}
+		a.Value = slog.GroupValue(attrs...)
+	}
+	return a
+}
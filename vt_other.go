@@ -0,0 +1,10 @@
+//go:build !windows
+
+package logger
+
+import "io"
+
+// Терминалы вне Windows понимают ANSI-последовательности без настройки
+func enableVirtualTerminal(w io.Writer) bool {
+	return true
+}
@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DisplayWidth возвращает ширину строки в колонках терминала: ANSI-последовательности
+// не учитываются, широкие символы (CJK, эмодзи) занимают две колонки,
+// комбинируемые знаки — ноль. Используется для выравнивания и обрезки и
+// подходит для собственных сегментов вывода.
+func DisplayWidth(s string) int {
+	w := 0
+	inEscape := false
+
+	for _, r := range s {
+		switch {
+		case r == ansiEsc:
+			inEscape = true
+		case inEscape:
+			if unicode.IsLetter(r) {
+				inEscape = false
+			}
+		default:
+			w += RuneWidth(r)
+		}
+	}
+
+	return w
+}
+
+// stripANSI убирает из строки ANSI-последовательности
+func stripANSI(s string) string {
+	var b strings.Builder
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == ansiEsc:
+			inEscape = true
+		case inEscape:
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+				inEscape = false
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// RuneWidth возвращает ширину символа в колонках терминала
+func RuneWidth(r rune) int {
+	switch {
+	case r == 0 || r == '\u200d' || r >= '\ufe00' && r <= '\ufe0f':
+		return 0
+	case r < 0x20 || r >= 0x7f && r < 0xa0:
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case isWide(r):
+		return 2
+	}
+	return 1
+}
+
+// TruncateWidth обрезает строку до width колонок, добавляя "…" при обрезке.
+// ANSI-последовательности сохраняются.
+func TruncateWidth(s string, width int) string {
+	if width <= 0 || DisplayWidth(s) <= width {
+		return s
+	}
+
+	return cutWidth(s, width-1) + "…"
+}
+
+// cutWidth возвращает начало s шириной не больше width колонок без
+// многоточия, не разрезая символы и ANSI-последовательности
+func cutWidth(s string, width int) string {
+	w := 0
+	inEscape := false
+
+	for i, r := range s {
+		switch {
+		case r == ansiEsc:
+			inEscape = true
+		case inEscape:
+			if unicode.IsLetter(r) {
+				inEscape = false
+			}
+		default:
+			rw := RuneWidth(r)
+			if w+rw > width {
+				return s[:i]
+			}
+			w += rw
+		}
+	}
+
+	return s
+}
+
+// Диапазоны East Asian Wide/Fullwidth и эмодзи
+var wideRanges = [...][2]rune{
+	{0x1100, 0x115f},
+	{0x231a, 0x231b},
+	{0x2329, 0x232a},
+	{0x23e9, 0x23ec},
+	{0x23f0, 0x23f0},
+	{0x23f3, 0x23f3},
+	{0x25fd, 0x25fe},
+	{0x2614, 0x2615},
+	{0x2648, 0x2653},
+	{0x267f, 0x267f},
+	{0x2693, 0x2693},
+	{0x26a1, 0x26a1},
+	{0x26aa, 0x26ab},
+	{0x26bd, 0x26be},
+	{0x26c4, 0x26c5},
+	{0x26ce, 0x26ce},
+	{0x26d4, 0x26d4},
+	{0x26ea, 0x26ea},
+	{0x26f2, 0x26f3},
+	{0x26f5, 0x26f5},
+	{0x26fa, 0x26fa},
+	{0x26fd, 0x26fd},
+	{0x2705, 0x2705},
+	{0x270a, 0x270b},
+	{0x2728, 0x2728},
+	{0x274c, 0x274c},
+	{0x274e, 0x274e},
+	{0x2753, 0x2755},
+	{0x2757, 0x2757},
+	{0x2795, 0x2797},
+	{0x27b0, 0x27b0},
+	{0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c},
+	{0x2b50, 0x2b50},
+	{0x2b55, 0x2b55},
+	{0x2e80, 0x303e},
+	{0x3041, 0x33ff},
+	{0x3400, 0x4dbf},
+	{0x4e00, 0x9fff},
+	{0xa000, 0xa4cf},
+	{0xa960, 0xa97f},
+	{0xac00, 0xd7a3},
+	{0xf900, 0xfaff},
+	{0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f},
+	{0xff00, 0xff60},
+	{0xffe0, 0xffe6},
+	{0x16fe0, 0x16fe4},
+	{0x17000, 0x18cff},
+	{0x1b000, 0x1b2ff},
+	{0x1f004, 0x1f004},
+	{0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e},
+	{0x1f191, 0x1f19a},
+	{0x1f200, 0x1f251},
+	{0x1f300, 0x1f64f},
+	{0x1f680, 0x1f6ff},
+	{0x1f7e0, 0x1f7eb},
+	{0x1f90c, 0x1f9ff},
+	{0x1fa70, 0x1faff},
+	{0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+func isWide(r rune) bool {
+	if r < wideRanges[0][0] {
+		return false
+	}
+
+	lo, hi := 0, len(wideRanges)-1
+	for lo <= hi {
+		m := (lo + hi) / 2
+		switch {
+		case r < wideRanges[m][0]:
+			hi = m - 1
+		case r > wideRanges[m][1]:
+			lo = m + 1
+		default:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,8 @@
+module github.com/bairto15/slog_gorm_color/zstd
+
+go 1.24.0
+
+require (
+	github.com/bairto15/slog_gorm_color v0.0.0-20261017051127-18db24c6ebf6
+	github.com/klauspost/compress v1.18.0
+)
@@ -0,0 +1,24 @@
+// Package zstd — Compressor zstd для CompressWriter, RotatingFile и
+// NetworkWriter пакета github.com/bairto15/slog_gorm_color. Отдельный
+// модуль: приложения без zstd не зависят от github.com/klauspost/compress.
+//
+//	w := logger.NewNetworkWriter("tcp", "collector:5170", zstd.New(1))
+package zstd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+)
+
+// New возвращает Compressor zstd с уровнем сжатия zstd (1-22, обычно 1-3
+// для логов): сжимает лучше gzip при той же скорости
+func New(level int) slogcolor.Compressor {
+	return func(w io.Writer) (slogcolor.CompressStream, error) {
+		return zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+			zstd.WithEncoderConcurrency(1))
+	}
+}
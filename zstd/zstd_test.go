@@ -0,0 +1,36 @@
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	slogcolor "github.com/bairto15/slog_gorm_color"
+)
+
+func TestCompressor(t *testing.T) {
+	var sink bytes.Buffer
+	cw, err := slogcolor.NewCompressWriter(&sink, New(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cw.Write([]byte("first\n"))
+	if err := cw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// сброшенный блок читается без Close
+	zr, err := zstd.NewReader(bytes.NewReader(sink.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	buf := make([]byte, 64)
+	n, _ := io.ReadAtLeast(zr, buf, len("first\n"))
+	if string(buf[:n]) != "first\n" {
+		t.Errorf("Expected flushed zstd block, got %q", buf[:n])
+	}
+}